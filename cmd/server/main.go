@@ -1,15 +1,26 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/gemini/go-service-communicator/internal/agent"
 	"github.com/gemini/go-service-communicator/internal/config"
+	"github.com/gemini/go-service-communicator/internal/dedup"
+	"github.com/gemini/go-service-communicator/internal/digest"
 	"github.com/gemini/go-service-communicator/internal/handlers"
+	"github.com/gemini/go-service-communicator/internal/llm"
+	"github.com/gemini/go-service-communicator/internal/queue"
 	"github.com/gemini/go-service-communicator/internal/services"
 	"github.com/gemini/go-service-communicator/internal/services/jira"
 	"github.com/gemini/go-service-communicator/internal/services/slack"
+	"github.com/gemini/go-service-communicator/internal/storage"
 	"github.com/gorilla/mux"
 )
 
@@ -22,8 +33,121 @@ func main() {
 
 	// Initialize services
 	slackClient := slack.New(cfg.Slack.Token)
-	jiraClient := jira.New()
-	agentProcessor := agent.New(cfg.Gemini.APIKey, slackClient)
+	slackClient.SetMaxThreadReplies(cfg.Slack.MaxThreadReplies)
+	slackClient.SetMaxHistoryMessages(cfg.Slack.MaxHistoryMessages)
+	slackClient.SetThreadLookback(time.Duration(cfg.Slack.ThreadLookbackHours) * time.Hour)
+	slackClient.SetAPIURL(cfg.Slack.APIURL)
+	if cfg.Slack.MaxRetries > 0 {
+		slackClient.SetMaxRetries(cfg.Slack.MaxRetries)
+	}
+	for method, perMinute := range cfg.Slack.RateLimits {
+		slackClient.SetRateLimit(method, perMinute)
+	}
+	slackClient.SetCacheOptions(cfg.Slack.Cache.Size, time.Duration(cfg.Slack.Cache.TTLSeconds)*time.Second, time.Duration(cfg.Slack.Cache.NegativeTTLSeconds)*time.Second)
+	if cfg.Slack.Cache.Warmup {
+		go func() {
+			if err := slackClient.WarmCaches(context.Background()); err != nil {
+				log.Printf("could not warm Slack caches: %v", err)
+			}
+		}()
+	}
+	jiraClient := jira.New(jira.Config{
+		BaseURL:     cfg.Jira.BaseURL,
+		Deployment:  cfg.Jira.Deployment,
+		Username:    cfg.Jira.Username,
+		APIToken:    cfg.Jira.APIToken,
+		BearerToken: cfg.Jira.BearerToken,
+		Timeout:     time.Duration(cfg.Jira.TimeoutSeconds) * time.Second,
+		MaxIssues:   cfg.Jira.MaxIssues,
+	})
+
+	jiraQueries := jira.QueryMapping{Default: cfg.Jira.DefaultJQL, Channels: make(map[string]jira.ChannelQuery)}
+	for channel, mapping := range cfg.Jira.Channels {
+		jiraQueries.Channels[channel] = jira.ChannelQuery{Projects: mapping.Projects, JQL: mapping.JQL}
+	}
+
+	webhookRules := make([]jira.WebhookRule, len(cfg.Jira.WebhookRules))
+	for i, rule := range cfg.Jira.WebhookRules {
+		webhookRules[i] = jira.WebhookRule{
+			Channel:    rule.Channel,
+			Events:     rule.Events,
+			Projects:   rule.Projects,
+			IssueTypes: rule.IssueTypes,
+			Priorities: rule.Priorities,
+			Filter:     rule.Filter,
+		}
+	}
+	webhookRouter, err := jira.NewWebhookRouter(webhookRules)
+	if err != nil {
+		log.Fatalf("invalid jira webhook rules: %v", err)
+	}
+
+	var provider llm.Provider
+	var featureModels map[string]string
+	switch cfg.LLMProvider {
+	case "openai":
+		provider = llm.NewOpenAI(cfg.OpenAI.BaseURL, cfg.OpenAI.APIKey, cfg.OpenAI.Model, time.Duration(cfg.OpenAI.TimeoutSeconds)*time.Second)
+		featureModels = cfg.OpenAI.FeatureModels
+	case "", "gemini":
+		gemini, err := llm.NewGemini(context.Background(), cfg.Gemini.APIKey, cfg.Gemini.Model)
+		if err != nil {
+			log.Fatalf("could not create Gemini client: %v", err)
+		}
+		defer gemini.Close()
+		provider = gemini
+		featureModels = cfg.Gemini.FeatureModels
+	default:
+		log.Fatalf("unknown llm_provider %q", cfg.LLMProvider)
+	}
+
+	var store storage.Store
+	switch cfg.Storage.Backend {
+	case "", "memory":
+		store = storage.NewMemoryStore()
+	case "bolt":
+		path := cfg.Storage.Path
+		if path == "" {
+			path = "communicator.db"
+		}
+		bolt, err := storage.OpenBolt(path)
+		if err != nil {
+			log.Fatalf("could not open storage: %v", err)
+		}
+		defer bolt.Close()
+		store = bolt
+	case "redis":
+		redis, err := storage.OpenRedis(context.Background(), storage.RedisConfig{
+			Address:   cfg.Storage.Redis.Address,
+			Username:  cfg.Storage.Redis.Username,
+			Password:  cfg.Storage.Redis.Password,
+			DB:        cfg.Storage.Redis.DB,
+			KeyPrefix: cfg.Storage.Redis.KeyPrefix,
+		})
+		if err != nil {
+			log.Fatalf("could not open storage: %v", err)
+		}
+		defer redis.Close()
+		store = redis
+	default:
+		log.Fatalf("unknown storage.backend %q", cfg.Storage.Backend)
+	}
+
+	agentProcessor := agent.New(provider, slackClient, jiraClient)
+	agentProcessor.SetStorage(store, time.Duration(cfg.Storage.SessionTTLSeconds)*time.Second)
+	agentProcessor.SetContextTokens(cfg.LLMContextTokens)
+	agentProcessor.SetToolLimits(cfg.Agent.MaxToolSteps, time.Duration(cfg.Agent.ToolTimeoutSeconds)*time.Second)
+	agentProcessor.SetCatchUpLookback(time.Duration(cfg.Agent.CatchUpLookbackHours) * time.Hour)
+	agentProcessor.SetChannelScope(slack.ChannelScope{
+		Include:      cfg.Agent.SummaryChannels.Include,
+		Exclude:      cfg.Agent.SummaryChannels.Exclude,
+		Types:        cfg.Agent.SummaryChannels.Types,
+		NamePatterns: cfg.Agent.SummaryChannels.NamePatterns,
+		MemberOnly:   cfg.Agent.SummaryChannels.MemberOnly,
+		MaxChannels:  cfg.Agent.SummaryChannels.MaxChannels,
+	})
+	for feature, model := range featureModels {
+		agentProcessor.SetFeatureOptions(agent.Feature(feature), llm.WithModel(model))
+	}
 
 	// Get bot's own user ID to prevent loops
 	authTest, err := slackClient.AuthTest()
@@ -38,22 +162,87 @@ func main() {
 		"jira":  jiraClient,
 	}
 
+	// ctx is cancelled on SIGINT or SIGTERM, which stops the background work.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	jobs := queue.New(ctx, queue.Config{
+		Workers:      cfg.Queue.Workers,
+		QueueSize:    cfg.Queue.Size,
+		PerUser:      cfg.Queue.PerUser,
+		PerWorkspace: cfg.Queue.PerWorkspace,
+	})
+
 	// Initialize handlers
 	multiServiceHandler := handlers.NewMultiServiceHandler(communicators)
-	slackEventHandler := handlers.NewSlackEventHandler(slackClient, agentProcessor, botUserID)
-	slashCommandHandler := handlers.NewSlashCommandHandler(slackClient, jiraClient, agentProcessor, cfg.Slack.SigningSecret)
+	slackEventHandler := handlers.NewSlackEventHandler(slackClient, agentProcessor, botUserID, jobs)
+	slackEventHandler.SetStorage(store, time.Duration(cfg.Storage.HistoryTTLSeconds)*time.Second)
+	switch cfg.Slack.Dedup.Backend {
+	case "", "memory":
+		slackEventHandler.SetDedupStore(dedup.NewMemoryStore(), time.Duration(cfg.Slack.Dedup.TTLSeconds)*time.Second)
+	case "storage":
+		slackEventHandler.SetDedupStore(dedup.NewPersistentStore(store), time.Duration(cfg.Slack.Dedup.TTLSeconds)*time.Second)
+	default:
+		log.Fatalf("unknown slack.dedup.backend %q", cfg.Slack.Dedup.Backend)
+	}
+	slashCommandHandler := handlers.NewSlashCommandHandler(slackClient, jiraClient, agentProcessor, jiraQueries, jobs)
+	if cfg.Storage.Backend == "" || cfg.Storage.Backend == "memory" {
+		log.Println("Warning: digest subscriptions are kept in memory and lost on restart; set storage.backend to keep them")
+	}
+	digests := digest.New(store, slashCommandHandler.DeliverDigest)
+	digests.SetInterval(time.Duration(cfg.Digests.CheckIntervalSeconds) * time.Second)
+	digests.SetJobs(jobs)
+	slashCommandHandler.SetDigests(digests)
+	go digests.Run(ctx)
+	jiraWebhookHandler := handlers.NewJiraWebhookHandler(slackClient, jiraClient, webhookRouter, cfg.Jira.WebhookSecret, jobs)
+	queueStatsHandler := handlers.NewQueueStatsHandler(jobs, cfg.Queue.MetricsToken)
+	interactionHandler := handlers.NewInteractionHandler(slackClient, agentProcessor, jobs, cfg.Jira.DefaultProject, cfg.Jira.DefaultIssueType)
 
 	// Create router
 	r := mux.NewRouter()
 
 	// Register routes
 	r.HandleFunc("/send", multiServiceHandler.SendMessageHandler).Methods("POST")
-	r.HandleFunc("/slack/events", slackEventHandler.HandleEvent).Methods("POST")
-	r.HandleFunc("/slack/command", slashCommandHandler.HandleCommand).Methods("POST")
+	if cfg.Queue.MetricsToken != "" {
+		r.HandleFunc("/metrics/queue", queueStatsHandler.HandleStats).Methods("GET")
+	}
+
+	r.HandleFunc("/jira/webhook", jiraWebhookHandler.HandleWebhook).Methods("POST")
+
+	switch cfg.Slack.Mode {
+	case "", "http":
+		// Every request from Slack must carry a valid signature.
+		slackRoutes := r.PathPrefix("/slack").Subrouter()
+		slackRoutes.Use(handlers.VerifySlackSignature(cfg.Slack.SigningSecret, time.Duration(cfg.Slack.SignatureMaxSkewSeconds)*time.Second))
+		slackRoutes.HandleFunc("/events", slackEventHandler.HandleEvent).Methods("POST")
+		slackRoutes.HandleFunc("/command", slashCommandHandler.HandleCommand).Methods("POST")
+		slackRoutes.HandleFunc("/interactive", interactionHandler.HandleInteraction).Methods("POST")
+	case "socket":
+		// Slack requests arrive over an outbound WebSocket, so no public URL is needed.
+		runner := handlers.NewSocketModeRunner(slack.NewSocketMode(cfg.Slack.Token, cfg.Slack.AppToken), slackEventHandler, slashCommandHandler, interactionHandler)
+		go func() {
+			if err := runner.Run(ctx); err != nil && ctx.Err() == nil {
+				log.Fatalf("socket mode connection failed: %v", err)
+			}
+		}()
+	default:
+		log.Fatalf("unknown slack.mode %q", cfg.Slack.Mode)
+	}
 
 	// Start server
+	server := &http.Server{Addr: ":8082", Handler: r}
+	go func() {
+		<-ctx.Done()
+		log.Println("Shutting down")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			log.Printf("could not shut down server: %v", err)
+		}
+	}()
 	log.Println("Starting server on :8082")
-	if err := http.ListenAndServe(":8082", r); err != nil {
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 		log.Fatalf("could not start server: %v", err)
 	}
+	jobs.Close()
 }
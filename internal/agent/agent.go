@@ -2,59 +2,136 @@ package agent
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
-	"regexp"
 	"strings"
-	"sync"
 	"time"
 
+	"github.com/gemini/go-service-communicator/internal/intent"
 	"github.com/gemini/go-service-communicator/internal/llm"
+	"github.com/gemini/go-service-communicator/internal/services/jira"
 	"github.com/gemini/go-service-communicator/internal/services/slack"
+	"github.com/gemini/go-service-communicator/internal/storage"
 	"github.com/gemini/go-service-communicator/internal/util"
 	slackgo "github.com/slack-go/slack"
 )
 
-// SummaryContext holds information about the last summary generated for a user.
-type SummaryContext struct {
-	Summary     string
-	ChannelID   string
-	InitialData []slackgo.Message
-}
+// Feature identifies a kind of LLM call made by the Processor, so that each one
+// can be tuned (model, temperature, ...) independently.
+type Feature string
+
+const (
+	FeatureChat        Feature = "chat"
+	FeatureSummary     Feature = "summary"
+	FeatureConsolidate Feature = "consolidate"
+	FeatureIntent      Feature = "intent"
+	FeatureAgent       Feature = "agent"
+)
 
 // Processor is the agent that handles business logic.
 type Processor struct {
-	apiKey       string
-	slackClient  *slack.Client
-	lastSummary  map[string]SummaryContext
-	summaryMutex sync.Mutex
+	llm            llm.Provider
+	featureOptions map[Feature][]llm.Option
+	contextTokens  int
+	slackClient    *slack.Client
+	jiraClient     *jira.Client
+	router         *intent.Router
+	maxToolSteps   int
+	toolTimeout    time.Duration
+	store          storage.Store
+	sessionTTL     time.Duration
+	channelScope   slack.ChannelScope
+	// catchUpLookback bounds how far back "what did I miss" looks.
+	catchUpLookback time.Duration
 }
 
 // New creates a new Processor.
-func New(apiKey string, slackClient *slack.Client) *Processor {
-	return &Processor{
-		apiKey:      apiKey,
-		slackClient: slackClient,
-		lastSummary: make(map[string]SummaryContext),
+func New(provider llm.Provider, slackClient *slack.Client, jiraClient *jira.Client) *Processor {
+	p := &Processor{
+		llm:             provider,
+		featureOptions:  make(map[Feature][]llm.Option),
+		contextTokens:   defaultContextTokens,
+		slackClient:     slackClient,
+		jiraClient:      jiraClient,
+		maxToolSteps:    defaultMaxToolSteps,
+		toolTimeout:     defaultToolTimeout,
+		store:           storage.NewMemoryStore(),
+		sessionTTL:      defaultSessionTTL,
+		catchUpLookback: defaultCatchUpLookback,
 	}
+	p.router = intent.NewRouter(intent.NewLLMClassifier(featureProvider{p: p, feature: FeatureIntent}), IntentChat)
+	p.registerIntents()
+	return p
 }
 
-// SetLastSummary stores the most recent summary generated for a user.
-func (p *Processor) SetLastSummary(userID, channelID, summary string, initialData []slackgo.Message) {
-	p.summaryMutex.Lock()
-	defer p.summaryMutex.Unlock()
-	log.Printf("Storing summary context for user %s in channel %s", userID, channelID)
-	p.lastSummary[userID] = SummaryContext{Summary: summary, ChannelID: channelID, InitialData: initialData}
+// SetFeatureOptions sets the LLM options used for every call made on behalf of feature.
+// It should be called before the Processor starts handling requests.
+func (p *Processor) SetFeatureOptions(feature Feature, opts ...llm.Option) {
+	p.featureOptions[feature] = opts
 }
 
-// ProcessMessage is for simple, non-contextual AI responses (e.g., for @mentions).
-func (p *Processor) ProcessMessage(userID, channelID, message string) string {
-	lowerMessage := strings.ToLower(message)
-	if strings.Contains(lowerMessage, "summary") || strings.Contains(lowerMessage, "summarize") {
-		return p.performSummary(userID, message, channelID)
+// notConfiguredMessage is shown instead of an answer when the LLM has no API key.
+const notConfiguredMessage = "AI service is not configured. Please add an API key for the LLM provider selected by llm_provider to config.yaml."
+
+// llmFailure returns the message shown when generating an answer failed with err:
+// how to configure the LLM if it has no API key, and fallback otherwise.
+func llmFailure(err error, fallback string) string {
+	if errors.Is(err, llm.ErrNotConfigured) {
+		return notConfiguredMessage
 	}
+	return fallback
+}
+
+// generate sends prompt to the configured provider using the options registered for feature.
+func (p *Processor) generate(ctx context.Context, feature Feature, prompt string) (string, error) {
+	return p.llm.Generate(ctx, prompt, p.featureOptions[feature]...)
+}
+
+// featureProvider adapts the Processor's provider so that every call uses the
+// options registered for one feature.
+type featureProvider struct {
+	p       *Processor
+	feature Feature
+}
+
+// Generate implements llm.Provider.
+func (f featureProvider) Generate(ctx context.Context, prompt string, opts ...llm.Option) (string, error) {
+	all := append([]llm.Option{}, f.p.featureOptions[f.feature]...)
+	return f.p.llm.Generate(ctx, prompt, append(all, opts...)...)
+}
+
+// SetStorage replaces the in-memory store that keeps summary sessions, issue
+// drafts and proposed comments between messages, e.g. with one that survives
+// restarts. sessionTTL is how long a session lasts after its last use; a
+// non-positive value keeps the current TTL.
+func (p *Processor) SetStorage(store storage.Store, sessionTTL time.Duration) {
+	if store != nil {
+		p.store = store
+	}
+	if sessionTTL > 0 {
+		p.sessionTTL = sessionTTL
+	}
+}
+
+// ProcessMessage is for simple, non-contextual AI responses (e.g., for @mentions).
+func (p *Processor) ProcessMessage(ctx context.Context, userID, channelID, message string) string {
+	return p.router.Dispatch(ctx, intent.Request{UserID: userID, ChannelID: channelID, Text: message})
+}
+
+// ProcessDM is for conversational AI responses in direct messages.
+func (p *Processor) ProcessDM(ctx context.Context, userID string, history []string, latestMessage string) string {
+	return p.router.Dispatch(ctx, intent.Request{UserID: userID, Text: latestMessage, History: history, DM: true})
+}
 
-	prompt := fmt.Sprintf(`A user mentioned the bot with the following message. Please provide a helpful response in Slack's Block Kit JSON format. The JSON should be a valid array of blocks.
+// respondToMention generates a direct answer to an @mention. summaryContext is the
+// summary session of the channel, if any.
+func (p *Processor) respondToMention(ctx context.Context, message, summaryContext, threadContext string) string {
+	if threadContext != "" {
+		threadContext = "The message was posted in a Slack thread. The thread so far:\n" + threadContext + "\n\n"
+	}
+	threadContext = summaryContext + threadContext
+	prompt := fmt.Sprintf(`%sA user mentioned the bot with the following message. Please provide a helpful response in Slack's Block Kit JSON format. The JSON should be a valid array of blocks.
 
 Example of a simple response:
 [
@@ -67,16 +144,28 @@ Example of a simple response:
   }
 ]
 
-User message: "%s"`, message)
-	response, err := llm.GenerateContent(context.Background(), p.apiKey, prompt)
+User message: "%s"`, threadContext, message)
+	response, err := p.generateBlocks(ctx, FeatureChat, prompt)
 	if err != nil {
-		return response // Error message is already formatted
+		return llmFailure(err, "Sorry, I had trouble generating a response.")
 	}
-	return cleanGeminiResponse(response)
+	return response
 }
 
-// ProcessDM is for conversational AI responses in direct messages.
-func (p *Processor) ProcessDM(userID string, history []string, latestMessage string) string {
+// converse continues a DM conversation, using the summary session the message refers to as context if there is one.
+// When the provider supports function calling the answer is produced by the tool loop,
+// so follow-up questions can be answered from live Slack and Jira data.
+func (p *Processor) converse(ctx context.Context, userID string, history []string, latestMessage string) string {
+	summaryContext := p.sessionContext(ctx, userID, "", latestMessage)
+
+	if answer, err := p.runAgent(ctx, userID, summaryContext, history, latestMessage); err == nil {
+		return answer
+	} else if errors.Is(err, llm.ErrNotConfigured) {
+		return notConfiguredMessage
+	} else if err != errToolsUnsupported {
+		log.Printf("Tool-calling agent failed, answering without tools: %v", err)
+	}
+
 	var builder strings.Builder
 	builder.WriteString(`You are a helpful and friendly conversational AI assistant. Continue the following conversation naturally.
 Please provide a response in Slack's Block Kit JSON format. The JSON should be a valid array of blocks.
@@ -93,44 +182,7 @@ Example of a simple response:
 ]
 
 `)
-
-	// Check for specific intents
-	lowerMessage := strings.ToLower(latestMessage)
-	if strings.Contains(lowerMessage, "summary") || strings.Contains(lowerMessage, "summarize") {
-		p.slackClient.SendMessage(userID, "Working on your summary. This might take a moment...")                                                                                                                                  
-		return p.performSummary(userID, latestMessage, "") // Pass empty channelID
-	}
-	if strings.Contains(lowerMessage, "mentions") || strings.Contains(lowerMessage, "tagged") || strings.Contains(lowerMessage, "missed") {
-		p.slackClient.SendMessage(userID, "Working on your request. This might take a moment...")                                                                                                                                  
-		return p.findUserMentions(userID)
-	}
-
-
-	// Check if there's a recent summary to add as context.
-	p.summaryMutex.Lock()
-	if summaryCtx, ok := p.lastSummary[userID]; ok {
-		log.Printf("Found summary context for user %s", userID)
-		builder.WriteString("CONTEXT: The user was just shown the following summary. Use this summary and the initial data to answer any follow-up questions.\n")
-		if summaryCtx.ChannelID != "" {
-			builder.WriteString(fmt.Sprintf("(The summary was for channel %s)\n", summaryCtx.ChannelID))
-		}
-		builder.WriteString("--- SUMMARY START ---\n")
-		builder.WriteString(summaryCtx.Summary)
-		builder.WriteString("\n--- SUMMARY END ---\n\n")
-
-		if len(summaryCtx.InitialData) > 0 {
-			builder.WriteString("--- INITIAL DATA START ---\n")
-			formattedMessages := formatMessagesForLLM(summaryCtx.InitialData, p.slackClient, userID)
-			for _, data := range formattedMessages {
-				builder.WriteString(data + "\n")
-			}
-			builder.WriteString("--- INITIAL DATA END ---\n\n")
-		}
-
-		// The summary context is now loaded. Delete it so it's not used in the *next* turn.
-		delete(p.lastSummary, userID)
-	}
-	p.summaryMutex.Unlock()
+	builder.WriteString(summaryContext)
 
 	builder.WriteString("--- CONVERSATION HISTORY ---\n")
 	for _, msg := range history {
@@ -142,58 +194,79 @@ Example of a simple response:
 
 	prompt := builder.String()
 
-	response, err := llm.GenerateContent(context.Background(), p.apiKey, prompt)
+	response, err := p.generateBlocks(ctx, FeatureChat, prompt)
 	if err != nil {
-		return response // Error message is already formatted
+		return llmFailure(err, "Sorry, I had trouble generating a response.")
 	}
-	return cleanGeminiResponse(response)
+	return response
 }
 
-// performSummary fetches channel history and generates a summary.
-func (p *Processor) performSummary(userID, message, channelID string) string {
+// SetChannelScope sets which channels a summary covers when the user names no
+// channel, e.g. when asking for a summary in a direct message.
+func (p *Processor) SetChannelScope(scope slack.ChannelScope) {
+	p.channelScope = scope
+}
+
+// performSummary fetches channel history and generates a summary. Channels and the
+// time range come from the intent slots; channelID is used when no channel was named.
+func (p *Processor) performSummary(ctx context.Context, userID string, slots intent.Slots, channelID string) string {
 	// Default to 1 day if parsing fails
 	duration := 24 * time.Hour
-	durationRegex := regexp.MustCompile(`(\d+\s*(hour|day|month|year)s?|\d+(h|d|m|y))`)
-	match := durationRegex.FindString(message)
-	if match != "" {
-		parsedDuration, err := util.ParseDuration(match)
+	if slots.TimeRange != "" {
+		parsedDuration, err := util.ParseDuration(slots.TimeRange)
 		if err == nil {
 			duration = parsedDuration
 		}
 	}
 
-	// Try to parse a channel ID from the message
-	channelRegex := regexp.MustCompile(`<#(C[A-Z0-9]{10})\|.*?>`)
-	matches := channelRegex.FindStringSubmatch(message)
-	if len(matches) == 2 {
-		channelID = matches[1]
+	if len(slots.Channels) == 1 {
+		channelID = slots.Channels[0]
 	}
 
 	endTime := time.Now()
 	startTime := endTime.Add(-duration)
 
 	var channelsToSummarize []string
-	if channelID != "" {
+	var skipped []slack.SkippedChannel
+	hidden := 0
+	if len(slots.Channels) > 0 {
+		// Named channels may be private; only summarize those the user is in.
+		channelsToSummarize, hidden = p.slackClient.FilterReadable(ctx, userID, slots.Channels)
+		if len(channelsToSummarize) == 0 {
+			return "I can only summarize conversations you are a member of."
+		}
+	} else if channelID != "" {
 		channelsToSummarize = []string{channelID}
 	} else {
-		publicChannels, err := p.slackClient.GetPublicChannels()
+		selection, err := p.slackClient.SelectChannels(ctx, p.channelScope, userID)
 		if err != nil {
-			log.Printf("Error fetching public channels: %v", err)
-			return "Sorry, I couldn't fetch the list of public channels."
+			log.Printf("Error selecting channels: %v", err)
+			return "Sorry, I couldn't fetch the list of channels."
 		}
-		channelsToSummarize = publicChannels
+		if len(selection.Channels) == 0 {
+			return "There are no channels I can summarize for you." + skippedNote(selection.Skipped, selection.Hidden)
+		}
+		channelsToSummarize = selection.Channels
+		skipped = selection.Skipped
+		hidden = selection.Hidden
 	}
 
 	var allRawMessages []slackgo.Message
+	var truncated []string
 	for _, chID := range channelsToSummarize {
-		messages, err := p.slackClient.GetConversationHistory(chID, startTime, endTime)
+		history, err := p.slackClient.GetConversationHistory(ctx, chID, startTime, endTime)
 		if err != nil {
 			log.Printf("Error fetching history for channel %s: %v", chID, err)
 			continue // Skip channels we can't access
 		}
+		if history.Truncated {
+			truncated = append(truncated, chID)
+		}
+		messages := history.Messages
 		for i := range messages {
 			messages[i].Channel = chID
 		}
+		messages = p.slackClient.ExpandThreads(ctx, chID, messages, startTime, endTime)
 		allRawMessages = append(allRawMessages, messages...)
 	}
 
@@ -201,7 +274,9 @@ func (p *Processor) performSummary(userID, message, channelID string) string {
 		return "I couldn't find any messages in the specified time period."
 	}
 
-	formattedMessages := formatMessagesForLLM(allRawMessages, p.slackClient, userID)
+	d := p.buildDigest(ctx, userID, allRawMessages, truncated)
+	d.Skipped = skipped
+	d.Hidden = hidden
 
 	// Create a prompt for the AI to summarize
 	var promptBuilder strings.Builder
@@ -228,64 +303,99 @@ Example of the desired format:
     }
 ]
 
-Slack Messages:
 `)
-	for _, msg := range formattedMessages {
-		promptBuilder.WriteString("- " + msg + "\n")
-	}
+	writeDigest(&promptBuilder, "Slack Messages", d)
 
-	summary, err := llm.GenerateContent(context.Background(), p.apiKey, promptBuilder.String())
+	summary, err := p.generateBlocks(ctx, FeatureSummary, promptBuilder.String())
 	if err != nil {
-		return "I was able to fetch the messages, but I encountered an error while generating the summary."
+		return llmFailure(err, "I was able to fetch the messages, but I encountered an error while generating the summary.")
 	}
 
-	cleanSummary := cleanGeminiResponse(summary)
-	p.SetLastSummary(userID, channelID, cleanSummary, allRawMessages)
-	return cleanSummary
+	// Follow-ups in a channel may be answered there, so only a summary of that
+	// channel alone is tied to it. Others can be followed up in a DM.
+	sessionChannel := ""
+	if len(channelsToSummarize) == 1 && channelsToSummarize[0] == channelID {
+		sessionChannel = channelID
+	}
+	return p.StartSession(ctx, userID, sessionChannel, appendCoverageBlock(summary, d), allRawMessages)
 }
 
-// findUserMentions searches for messages where the given userID was mentioned.
-func (p *Processor) findUserMentions(userID string) string {
-	query := fmt.Sprintf("<@%s>", userID)
-	searchResult, err := p.slackClient.SearchMessages(query)
+// summarizeThread summarizes the thread started by threadTS in channelID.
+func (p *Processor) summarizeThread(ctx context.Context, userID, channelID, threadTS string) string {
+	messages, err := p.slackClient.GetThreadReplies(ctx, channelID, threadTS)
 	if err != nil {
-		log.Printf("Error searching for mentions for user %s: %v", userID, err)
-		if strings.Contains(err.Error(), "not_allowed_token_type") { // Specific error for user token issue
-			return "I can't search for your mentions because I'm missing the `search:read` permission or the token type is not allowed. Please ensure I have the `search:read` scope and that your workspace allows bot tokens for search."
-		}
-		if strings.Contains(err.Error(), "missing_scope") {
-			return "I can't search for your mentions because I'm missing the `search:read` permission. Please add it to my Slack App configuration."
-		}
-		return "Sorry, I couldn't search for your mentions."
+		log.Printf("Error fetching thread %s in channel %s: %v", threadTS, channelID, err)
+		return "Sorry, I couldn't read this thread."
 	}
-
-	if searchResult == nil || len(searchResult.Matches) == 0 {
-		return "I couldn't find any recent mentions of you."
+	if len(messages) == 0 {
+		return "I couldn't find any messages in this thread."
 	}
 
-	var builder strings.Builder
-	builder.WriteString("Here are some recent mentions of you:\n\n")
-	for i, match := range searchResult.Matches {
-		if i >= 5 { // Limit to top 5 mentions for brevity
-			builder.WriteString(fmt.Sprintf("\n...and %d more. Ask me to summarize if you want to know more!", len(searchResult.Matches)-5))
-			break
-		}
-		// Highlight the user's mention in the search result
-		highlightedText := highlightMentions(match.Text, userID)
-		builder.WriteString(fmt.Sprintf("- In #%s, <@%s> said: \"%s\"\n", match.Channel.Name, match.User, highlightedText))
+	d := p.buildDigest(ctx, userID, messages, nil)
+
+	var promptBuilder strings.Builder
+	promptBuilder.WriteString(`Please summarize the following Slack thread in Slack's Block Kit JSON format.
+Cover what the thread is about, the decisions made, open questions, and any action items with their owners.
+
+Example of the desired format:
+[
+    {
+        "type": "header",
+        "text": {
+            "type": "plain_text",
+            "text": "Thread Summary"
+        }
+    },
+    {
+        "type": "section",
+        "text": {
+            "type": "mrkdwn",
+            "text": "*Decisions*\n• ..."
+        }
+    }
+]
+
+`)
+	writeDigest(&promptBuilder, "Thread Messages", d)
+
+	summary, err := p.generateBlocks(ctx, FeatureSummary, promptBuilder.String())
+	if err != nil {
+		return llmFailure(err, "I was able to read the thread, but I encountered an error while generating the summary.")
 	}
 
-	return builder.String()
+	return p.StartSession(ctx, userID, channelID, appendCoverageBlock(summary, d), messages)
+}
+
+// threadContextMessages is how many thread messages are included when answering inside a thread.
+const threadContextMessages = 30
+
+// threadContext renders the latest messages of a thread for use in a prompt.
+func (p *Processor) threadContext(ctx context.Context, userID, channelID, threadTS string) string {
+	messages, err := p.slackClient.GetThreadReplies(ctx, channelID, threadTS)
+	if err != nil {
+		log.Printf("Error fetching thread %s in channel %s: %v", threadTS, channelID, err)
+		return ""
+	}
+	if len(messages) > threadContextMessages {
+		// Keep the parent message, which usually states the topic, and the latest replies.
+		messages = append(messages[:1], messages[len(messages)-threadContextMessages+1:]...)
+	}
+	return strings.Join(formatMessagesForLLM(messages, p.slackClient, userID), "\n")
 }
 
 // ConsolidateInfo uses the AI to create a summary from Slack messages and Jira issues.
+// truncatedChannels lists the channels whose history was cut off at the message cap.
 // This is used by the /summary slash command.
-func (p *Processor) ConsolidateInfo(userID string, slackMessages []slackgo.Message, jiraIssues []string) string {
-	formattedMessages := formatMessagesForLLM(slackMessages, p.slackClient, userID)
+func (p *Processor) ConsolidateInfo(ctx context.Context, userID string, slackMessages []slackgo.Message, truncatedChannels []string, jiraIssues []jira.Issue) string {
+	d := p.buildDigest(ctx, userID, slackMessages, truncatedChannels)
 	var builder strings.Builder
-	builder.WriteString(`Please provide a concise summary of the following activities in Slack's Block Kit JSON format. The JSON should be a valid array of blocks.
-
-Use a header for "Slack Conversations" and "Jira Issues", and a divider between them.
+	builder.WriteString("Please provide a concise summary of the following activities in Slack's Block Kit JSON format. The JSON should be a valid array of blocks.\n\n")
+	if len(jiraIssues) > 0 {
+		builder.WriteString(`Use a header for "Slack Conversations" and "Jira Issues", and a divider between them.`)
+	} else {
+		builder.WriteString(`Use a header for "Slack Conversations". There are no Jira issues, so leave out the "Jira Issues" part of the example.`)
+	}
+	builder.WriteString(`
 
 Example of the desired format:
 [
@@ -331,33 +441,35 @@ Example of the desired format:
 
 `)
 
-	if len(formattedMessages) > 0 {
-		builder.WriteString("Slack Conversations:\n")
-		for _, msg := range formattedMessages {
-			builder.WriteString(fmt.Sprintf("- %s\n", msg))
-		}
+	if len(d.Lines) > 0 {
+		writeDigest(&builder, "Slack Conversations", d)
 	}
 
 	if len(jiraIssues) > 0 {
 		builder.WriteString("\nJira Issues:\n")
 		for _, issue := range jiraIssues {
-			builder.WriteString(fmt.Sprintf("- %s\n", issue))
+			builder.WriteString(fmt.Sprintf("- %s\n", issue.String()))
 		}
 	}
 
-	if len(formattedMessages) == 0 && len(jiraIssues) == 0 {
+	if len(d.Lines) == 0 && len(jiraIssues) == 0 {
 		return "There were no activities to summarize in the given time period."
 	}
 
 	prompt := builder.String()
 
-	summary, err := llm.GenerateContent(context.Background(), p.apiKey, prompt)
+	summary, err := p.generateBlocks(ctx, FeatureConsolidate, prompt)
 	if err != nil {
-		return "I was able to fetch the activities, but I encountered an error while generating the summary."
+		return llmFailure(err, "I was able to fetch the activities, but I encountered an error while generating the summary.")
 	}
-	return cleanGeminiResponse(summary)
+	return appendCoverageBlock(summary, d)
 }
 
+// threadReplyMarker starts the formatted line of a thread reply, which follows its parent message.
+const threadReplyMarker = "↳ "
+
+// formatMessagesForLLM renders messages as one line each. Thread replies are
+// marked with threadReplyMarker so the model can tell them from top-level messages.
 func formatMessagesForLLM(messages []slackgo.Message, slackClient *slack.Client, userID string) []string {
 	var formattedMessages []string
 	for _, msg := range messages {
@@ -369,7 +481,15 @@ func formatMessagesForLLM(messages []slackgo.Message, slackClient *slack.Client,
 		}
 
 		channelName := slackClient.GetChannelName(msg.Channel)
-		formattedMsg := fmt.Sprintf("[Channel: %s] %s: %s", channelName, userName, msg.Text)
+		var formattedMsg string
+		switch {
+		case msg.ThreadTimestamp != "" && msg.ThreadTimestamp != msg.Timestamp:
+			formattedMsg = fmt.Sprintf("%s[Channel: %s] %s (in thread): %s", threadReplyMarker, channelName, userName, msg.Text)
+		case msg.ReplyCount > 0:
+			formattedMsg = fmt.Sprintf("[Channel: %s] %s (started a thread with %d replies): %s", channelName, userName, msg.ReplyCount, msg.Text)
+		default:
+			formattedMsg = fmt.Sprintf("[Channel: %s] %s: %s", channelName, userName, msg.Text)
+		}
 		formattedMessages = append(formattedMessages, highlightMentions(formattedMsg, userID))
 	}
 	return formattedMessages
@@ -0,0 +1,51 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/gemini/go-service-communicator/internal/services/slack"
+)
+
+// generateBlocks asks the model for a Block Kit response and validates it. If the
+// response is not valid Block Kit the model is asked once more with the validation
+// error; if that fails too the response is rendered deterministically.
+func (p *Processor) generateBlocks(ctx context.Context, feature Feature, prompt string) (string, error) {
+	response, err := p.generate(ctx, feature, prompt)
+	if err != nil {
+		return "", err
+	}
+	response = cleanGeminiResponse(response)
+
+	validationErr := slack.ValidateBlocks(response)
+	if validationErr == nil {
+		return response, nil
+	}
+	log.Printf("LLM returned invalid Block Kit (%v); asking it to repair the response", validationErr)
+
+	repairPrompt := fmt.Sprintf(`%s
+
+Your previous response was not valid Slack Block Kit JSON: %v
+
+Previous response:
+%s
+
+Reply with only the corrected JSON array of blocks. Use at most %d blocks, keep section text under %d characters and header text under %d characters, and use only these block types: %s. Context blocks hold 1 to %d text or image elements; actions blocks hold 1 to %d interactive elements such as buttons.`,
+		prompt, validationErr, response, slack.MaxBlocks, slack.MaxSectionTextLength, slack.MaxHeaderTextLength,
+		strings.Join(slack.MessageBlockTypes(), ", "), slack.MaxContextElements, slack.MaxActionsElements)
+
+	repaired, err := p.generate(ctx, feature, repairPrompt)
+	if err == nil {
+		repaired = cleanGeminiResponse(repaired)
+		if validationErr = slack.ValidateBlocks(repaired); validationErr == nil {
+			return repaired, nil
+		}
+		log.Printf("Repaired Block Kit is still invalid (%v); rendering it as text", validationErr)
+		return slack.RenderFallbackBlocks(repaired), nil
+	}
+
+	log.Printf("Error asking the LLM to repair Block Kit: %v", err)
+	return slack.RenderFallbackBlocks(response), nil
+}
@@ -0,0 +1,376 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gemini/go-service-communicator/internal/intent"
+	"github.com/gemini/go-service-communicator/internal/services/jira"
+	slackgo "github.com/slack-go/slack"
+)
+
+const (
+	// checkpointBucket holds the time each user last caught up, keyed by user ID.
+	checkpointBucket = "catch_up_checkpoints"
+	// defaultCatchUpLookback is how far back a catch-up looks when the user hasn't
+	// been active or caught up more recently.
+	defaultCatchUpLookback = 72 * time.Hour
+	// maxCatchUpItems bounds the items handed to the model, most important first.
+	maxCatchUpItems = 30
+	// maxCatchUpLinks bounds the permalinks fetched for the top items.
+	maxCatchUpLinks = 10
+)
+
+// Reasons an item shows up in a catch-up.
+const (
+	reasonMention     = "mentioned you"
+	reasonThreadReply = "replied to your thread"
+	reasonGroup       = "mentioned a user group you are in"
+	reasonBroadcast   = "notified the whole channel"
+	reasonJira        = "updated a Jira issue you are involved in"
+)
+
+// reasonWeights rank catch-up items: the sum of an item's reason weights is its score.
+var reasonWeights = map[string]int{
+	reasonMention:     8,
+	reasonThreadReply: 5,
+	reasonGroup:       4,
+	reasonJira:        4,
+	reasonBroadcast:   2,
+}
+
+// broadcastRegex matches @here, @channel and @everyone.
+var broadcastRegex = regexp.MustCompile(`<!(?:here|channel|everyone)[|>]`)
+
+// catchUpItem is a message or Jira issue the user may have missed.
+type catchUpItem struct {
+	Reasons []string
+	Score   int
+	Time    time.Time
+	Message *slackgo.Message
+	Issue   *jira.Issue
+	Link    string
+}
+
+func (item *catchUpItem) add(reason string) {
+	item.Reasons = append(item.Reasons, reason)
+	item.Score += reasonWeights[reason]
+}
+
+// SetCatchUpLookback sets how far back "what did I miss" looks when the user has
+// no more recent activity or checkpoint. Non-positive values keep the default.
+func (p *Processor) SetCatchUpLookback(lookback time.Duration) {
+	if lookback > 0 {
+		p.catchUpLookback = lookback
+	}
+}
+
+// checkpoint returns when the user last caught up, or the zero time.
+func (p *Processor) checkpoint(ctx context.Context, userID string) time.Time {
+	data, ok, err := p.store.Get(ctx, checkpointBucket, userID)
+	if err != nil {
+		log.Printf("Error loading catch-up checkpoint for user %s: %v", userID, err)
+	}
+	if !ok {
+		return time.Time{}
+	}
+	t, err := time.Parse(time.RFC3339, string(data))
+	if err != nil {
+		log.Printf("Discarding unreadable catch-up checkpoint for user %s: %v", userID, err)
+		return time.Time{}
+	}
+	return t
+}
+
+// setCheckpoint records that the user has seen everything up to t.
+func (p *Processor) setCheckpoint(ctx context.Context, userID string, t time.Time) {
+	if err := p.store.Put(ctx, checkpointBucket, userID, []byte(t.UTC().Format(time.RFC3339)), 0); err != nil {
+		log.Printf("Error storing catch-up checkpoint for user %s: %v", userID, err)
+	}
+}
+
+// messageTime converts a Slack message timestamp such as "1700000000.000100" to a time.
+func messageTime(ts string) time.Time {
+	seconds, err := strconv.ParseFloat(ts, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.Unix(0, int64(seconds*float64(time.Second)))
+}
+
+// slackDate renders t with Slack's date formatting, so each reader sees it in their own time zone.
+func slackDate(t time.Time) string {
+	return fmt.Sprintf("<!date^%d^{date_short_pretty} at {time}|%s>", t.Unix(), t.UTC().Format("Jan 2 15:04 MST"))
+}
+
+// catchUp tells the user what they missed since they were last active: their
+// last message in a channel they share with the bot or their last catch-up,
+// whichever is later. It reads channel history instead of search.messages, so
+// it works with a bot token.
+func (p *Processor) catchUp(ctx context.Context, userID string) string {
+	now := time.Now()
+	since := now.Add(-p.catchUpLookback)
+	start := since
+	if checkpoint := p.checkpoint(ctx, userID); checkpoint.After(start) {
+		start = checkpoint
+	}
+
+	scope := p.channelScope
+	scope.MemberOnly = true
+	if len(scope.Types) == 0 {
+		scope.Types = []string{"public_channel", "private_channel", "mpim"}
+	}
+	selection, err := p.slackClient.SelectChannels(ctx, scope, userID)
+	if err != nil {
+		log.Printf("Error selecting channels for user %s: %v", userID, err)
+		return "Sorry, I couldn't fetch the list of your channels."
+	}
+
+	// The user's own latest message tells when they were last active. History
+	// is read over the whole lookback, so that threads the user started before
+	// then are found and their new replies included.
+	lastSeen := start
+	histories := make(map[string][]slackgo.Message)
+	for _, channelID := range selection.Channels {
+		history, err := p.slackClient.GetConversationHistory(ctx, channelID, since, now)
+		if err != nil {
+			log.Printf("Error fetching history for channel %s: %v", channelID, err)
+			continue
+		}
+		for i := range history.Messages {
+			history.Messages[i].Channel = channelID
+			if history.Messages[i].User == userID {
+				if t := messageTime(history.Messages[i].Timestamp); t.After(lastSeen) {
+					lastSeen = t
+				}
+			}
+		}
+		histories[channelID] = history.Messages
+	}
+
+	groups, err := p.slackClient.GetUserGroupIDs(ctx, userID)
+	if err != nil {
+		log.Printf("Could not load user groups of %s; group mentions are skipped: %v", userID, err)
+	}
+
+	var items []*catchUpItem
+	for _, channelID := range selection.Channels {
+		messages, ok := histories[channelID]
+		if !ok {
+			continue
+		}
+		messages = p.slackClient.ExpandThreads(ctx, channelID, messages, lastSeen, now)
+		items = append(items, missedMessages(messages, userID, groups, lastSeen)...)
+	}
+	items = append(items, p.missedIssues(ctx, userID, now.Sub(lastSeen))...)
+
+	if len(items) == 0 {
+		p.setCheckpoint(ctx, userID, now)
+		return fmt.Sprintf("You're all caught up! Nothing needed your attention since %s.", slackDate(lastSeen))
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		if items[i].Score != items[j].Score {
+			return items[i].Score > items[j].Score
+		}
+		return items[i].Time.After(items[j].Time)
+	})
+	total := len(items)
+	if len(items) > maxCatchUpItems {
+		items = items[:maxCatchUpItems]
+	}
+
+	var messages []slackgo.Message
+	for i, item := range items {
+		switch {
+		case item.Issue != nil:
+			item.Link = p.jiraClient.BrowseURL(item.Issue.Key)
+		case i < maxCatchUpLinks:
+			if link, err := p.slackClient.GetPermalink(item.Message.Channel, item.Message.Timestamp); err == nil {
+				item.Link = link
+			}
+		}
+		if item.Message != nil {
+			messages = append(messages, *item.Message)
+		}
+	}
+
+	summary, err := p.generateBlocks(ctx, FeatureSummary, p.catchUpPrompt(userID, items, lastSeen))
+	if err != nil {
+		log.Printf("Error generating catch-up for user %s: %v", userID, err)
+		summary = p.catchUpList(userID, items)
+	}
+
+	note := fmt.Sprintf("Found %d item(s) since you were last active (%s) in %d channel(s).", total, slackDate(lastSeen), len(histories))
+	if total > len(items) {
+		note += fmt.Sprintf(" Showing the %d most important.", len(items))
+	}
+	summary = appendBlocks(summary, note, map[string]interface{}{
+		"type":     "context",
+		"elements": []map[string]string{{"type": "mrkdwn", "text": note}},
+	})
+
+	p.setCheckpoint(ctx, userID, now)
+	return p.StartSession(ctx, userID, "", summary, messages)
+}
+
+// missedMessages picks the messages posted after lastSeen that concern userID.
+// Thread replies the user has already answered are treated as seen.
+func missedMessages(messages []slackgo.Message, userID string, groups []string, lastSeen time.Time) []*catchUpItem {
+	threadStarters := make(map[string]string)
+	answered := make(map[string]time.Time)
+	for _, msg := range messages {
+		if msg.ThreadTimestamp == "" || msg.ThreadTimestamp == msg.Timestamp {
+			threadStarters[msg.Timestamp] = msg.User
+		} else if msg.User == userID {
+			if t := messageTime(msg.Timestamp); t.After(answered[msg.ThreadTimestamp]) {
+				answered[msg.ThreadTimestamp] = t
+			}
+		}
+	}
+
+	var items []*catchUpItem
+	for i := range messages {
+		msg := &messages[i]
+		t := messageTime(msg.Timestamp)
+		if msg.User == userID || !t.After(lastSeen) {
+			continue
+		}
+		isReply := msg.ThreadTimestamp != "" && msg.ThreadTimestamp != msg.Timestamp
+		if isReply && !t.After(answered[msg.ThreadTimestamp]) {
+			continue
+		}
+
+		item := &catchUpItem{Time: t, Message: msg}
+		if strings.Contains(msg.Text, "<@"+userID+">") {
+			item.add(reasonMention)
+		}
+		if isReply && threadStarters[msg.ThreadTimestamp] == userID {
+			item.add(reasonThreadReply)
+		}
+		for _, group := range groups {
+			if strings.Contains(msg.Text, "<!subteam^"+group) {
+				item.add(reasonGroup)
+				break
+			}
+		}
+		if broadcastRegex.MatchString(msg.Text) {
+			item.add(reasonBroadcast)
+		}
+		if len(item.Reasons) > 0 {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// missedIssues returns the Jira issues the user is assigned to, reported or
+// watches that someone else changed or commented on within the window. The
+// Slack and Jira accounts are matched by email address.
+func (p *Processor) missedIssues(ctx context.Context, userID string, window time.Duration) []*catchUpItem {
+	if !p.jiraClient.Configured() {
+		return nil
+	}
+	email, err := p.slackClient.GetUserEmail(ctx, userID)
+	if err != nil || email == "" {
+		log.Printf("No email address for user %s, skipping Jira activity: %v", userID, err)
+		return nil
+	}
+	account, err := p.jiraClient.FindUser(ctx, email)
+	if err != nil || account == "" {
+		log.Printf("No Jira account for user %s, skipping Jira activity: %v", userID, err)
+		return nil
+	}
+
+	jql := fmt.Sprintf(`(assignee = "%[1]s" OR reporter = "%[1]s" OR watcher = "%[1]s") AND updated >= "%[2]s" ORDER BY updated DESC`, account, jira.RelativeDate(window))
+	issues, err := p.jiraClient.FetchIssueActivity(ctx, jql)
+	if err != nil {
+		log.Printf("Error fetching Jira activity for user %s: %v", userID, err)
+		return nil
+	}
+	var items []*catchUpItem
+	for i := range issues {
+		// The user already knows about their own changes.
+		if issues[i].UpdatedBy == account {
+			continue
+		}
+		item := &catchUpItem{Time: issues[i].Updated, Issue: &issues[i]}
+		item.add(reasonJira)
+		items = append(items, item)
+	}
+	return items
+}
+
+// catchUpLines renders the items for the prompt and the fallback list, most important first.
+func (p *Processor) catchUpLines(userID string, items []*catchUpItem) []string {
+	lines := make([]string, 0, len(items))
+	for _, item := range items {
+		var line string
+		if item.Issue != nil {
+			line = "[Jira] " + item.Issue.String()
+		} else {
+			line = formatMessagesForLLM([]slackgo.Message{*item.Message}, p.slackClient, userID)[0]
+			line = strings.TrimPrefix(line, threadReplyMarker)
+		}
+		line += " (" + strings.Join(item.Reasons, ", ") + ")"
+		if item.Link != "" {
+			line += " <" + item.Link + "|link>"
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func (p *Processor) catchUpPrompt(userID string, items []*catchUpItem, lastSeen time.Time) string {
+	var builder strings.Builder
+	builder.WriteString(fmt.Sprintf(`The user asked what they missed since they were last active on %s. Below are the Slack messages and Jira updates that concern them, most important first, each with the reason it concerns them.
+Write a short catch-up in Slack's Block Kit JSON format. Start with what needs the user's reply or action, then summarize the rest briefly, grouping related items. Keep the links (<url|link>) of the items you mention. The JSON should be a valid array of blocks.
+
+Example of the desired format:
+[
+    {
+        "type": "header",
+        "text": {
+            "type": "plain_text",
+            "text": "While you were away"
+        }
+    },
+    {
+        "type": "section",
+        "text": {
+            "type": "mrkdwn",
+            "text": "*Needs your attention*\n• ..."
+        }
+    }
+]
+
+--- ITEMS START ---
+`, lastSeen.UTC().Format("2006-01-02 15:04 MST")))
+	for _, line := range p.catchUpLines(userID, items) {
+		builder.WriteString("- " + line + "\n")
+	}
+	builder.WriteString("--- ITEMS END ---\n")
+	return builder.String()
+}
+
+// catchUpList is the plain-text catch-up used when the summary can't be generated.
+func (p *Processor) catchUpList(userID string, items []*catchUpItem) string {
+	var builder strings.Builder
+	builder.WriteString("Here's what you missed, most important first:\n\n")
+	for _, line := range p.catchUpLines(userID, items) {
+		builder.WriteString("- " + line + "\n")
+	}
+	return builder.String()
+}
+
+// handleCheckpoint marks everything up to now as seen, so the next catch-up starts here.
+func (p *Processor) handleCheckpoint(ctx context.Context, req intent.Request, slots intent.Slots) string {
+	p.setCheckpoint(ctx, req.UserID, time.Now())
+	return "Got it. Next time you ask what you missed, I'll start from now."
+}
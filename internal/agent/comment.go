@@ -0,0 +1,114 @@
+package agent
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/gemini/go-service-communicator/internal/services/jira"
+)
+
+const (
+	// ActionConfirmComment is the action ID of the button that posts a Jira
+	// comment the agent proposed.
+	ActionConfirmComment = "confirm_jira_comment"
+	// pendingCommentBucket holds proposed comments until the user confirms them.
+	pendingCommentBucket = "pending_jira_comments"
+	// pendingCommentTTL is how long a proposed comment can be confirmed.
+	pendingCommentTTL = 30 * time.Minute
+	// maxCommentPreview caps the comment quoted above the confirm button; section
+	// text is limited to 3000 characters.
+	maxCommentPreview = 2500
+)
+
+// PendingComment is a Jira comment the agent proposed, waiting for the user to
+// confirm it.
+type PendingComment struct {
+	UserID   string
+	IssueKey string
+	Comment  string
+}
+
+// agentRun collects the blocks tools add below the agent's answer.
+type agentRun struct {
+	blocks []interface{}
+}
+
+// proposeComment stores a comment for the user to confirm and returns the blocks
+// that quote it with a confirm button.
+func (p *Processor) proposeComment(ctx context.Context, userID, issueKey, comment string) ([]interface{}, error) {
+	if !p.jiraClient.Configured() {
+		return nil, jira.ErrNotConfigured
+	}
+	data, err := json.Marshal(PendingComment{UserID: userID, IssueKey: issueKey, Comment: comment})
+	if err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 8)
+	rand.Read(buf)
+	id := hex.EncodeToString(buf)
+	if err := p.store.Put(ctx, pendingCommentBucket, id, data, pendingCommentTTL); err != nil {
+		return nil, err
+	}
+	log.Printf("Proposed a comment on %s to user %s", issueKey, userID)
+
+	preview := fmt.Sprintf("Add this comment to *<%s|%s>*?\n>%s", p.jiraClient.BrowseURL(issueKey), issueKey, strings.ReplaceAll(truncateRunes(comment, maxCommentPreview), "\n", "\n>"))
+	return []interface{}{
+		map[string]interface{}{
+			"type": "section",
+			"text": map[string]string{"type": "mrkdwn", "text": preview},
+		},
+		map[string]interface{}{
+			"type": "actions",
+			"elements": []map[string]interface{}{{
+				"type":      "button",
+				"action_id": ActionConfirmComment,
+				"value":     id,
+				"style":     "primary",
+				"text":      map[string]string{"type": "plain_text", "text": "Add comment"},
+			}},
+		},
+	}, nil
+}
+
+// ConfirmComment posts the comment behind a confirm button userID clicked and
+// returns the message to show them. Each comment is posted at most once.
+func (p *Processor) ConfirmComment(ctx context.Context, userID, id string) string {
+	var pending PendingComment
+	errNotOwner := errors.New("comment was proposed to another user")
+	found := false
+	err := p.store.Update(ctx, pendingCommentBucket, id, pendingCommentTTL, func(old []byte) ([]byte, error) {
+		found = false
+		if old == nil {
+			return nil, nil
+		}
+		if err := json.Unmarshal(old, &pending); err != nil {
+			return nil, nil
+		}
+		if pending.UserID != userID {
+			return nil, errNotOwner
+		}
+		found = true
+		return nil, nil
+	})
+	if errors.Is(err, errNotOwner) || (err == nil && !found) {
+		return "That comment has expired or was already added. Ask me again if you still want to post it."
+	}
+	if err != nil {
+		log.Printf("Error loading pending comment %s: %v", id, err)
+		return "Sorry, I couldn't add the comment. Please try again."
+	}
+
+	if err := p.jiraClient.AddComment(ctx, pending.IssueKey, pending.Comment); err != nil {
+		log.Printf("Error adding comment to %s for user %s: %v", pending.IssueKey, userID, err)
+		return fmt.Sprintf("Sorry, I couldn't add the comment to %s: %v", pending.IssueKey, err)
+	}
+	log.Printf("User %s confirmed a comment on %s", userID, pending.IssueKey)
+	return fmt.Sprintf("Done! I've added the comment to <%s|%s>.", p.jiraClient.BrowseURL(pending.IssueKey), pending.IssueKey)
+}
@@ -0,0 +1,114 @@
+package agent
+
+import (
+	"context"
+
+	"github.com/gemini/go-service-communicator/internal/intent"
+)
+
+// Intents handled by the Processor.
+const (
+	IntentSummarize  = "summarize"
+	IntentMentions   = "mentions"
+	IntentCheckpoint = "checkpoint"
+	IntentFileTicket = "file_ticket"
+	IntentEndSession = "end_session"
+	IntentChat       = "chat"
+)
+
+// registerIntents registers the Processor's built-in capabilities with its router.
+func (p *Processor) registerIntents() {
+	p.router.Register(intent.Intent{
+		Name:        IntentSummarize,
+		Description: "The user asks for a summary or recap of Slack conversations, optionally for specific channels, a time range, or just the current thread.",
+		Keywords:    []string{"summary", "summarize", "summarise", "recap", "tldr"},
+		Private:     true,
+		Handler:     p.handleSummarize,
+	})
+	p.router.Register(intent.Intent{
+		Name:        IntentMentions,
+		Description: "The user wants to catch up: where they were mentioned or tagged, replies to their threads, or what they missed since they were last active.",
+		Keywords:    []string{"mentions", "mentioned", "tagged", "miss", "missed", "catch me up", "catch up"},
+		Private:     true,
+		Handler:     p.handleMentions,
+	})
+	p.router.Register(intent.Intent{
+		Name:        IntentCheckpoint,
+		Description: "The user says they are caught up or asks to mark everything as read, so the next catch-up starts from now.",
+		Keywords:    []string{"mark as read", "mark all as read", "caught up", "checkpoint"},
+		Private:     true,
+		Handler:     p.handleCheckpoint,
+	})
+	p.router.Register(intent.Intent{
+		Name:        IntentFileTicket,
+		Description: "The user asks to file, create or open a Jira ticket or issue from the current discussion.",
+		Keywords:    []string{"file a ticket", "create a ticket", "open a ticket", "file an issue", "create an issue", "jira ticket"},
+		Private:     true,
+		Handler:     p.handleFileTicket,
+	})
+	p.router.Register(intent.Intent{
+		Name:        IntentEndSession,
+		Description: "The user is done asking follow-up questions about a summary and wants to end or close the summary session.",
+		Keywords:    []string{"end session", "end the session", "stop session", "close session", "done with the summary"},
+		Private:     true,
+		Handler:     p.handleEndSession,
+	})
+	p.router.Register(intent.Intent{
+		Name:        IntentChat,
+		Description: "Anything else: questions, follow-ups about an earlier summary, small talk.",
+		Handler:     p.handleChat,
+	})
+}
+
+// RegisterIntent adds a capability to the Processor. Messages classified as
+// in.Name are handed to in.Handler.
+func (p *Processor) RegisterIntent(in intent.Intent) {
+	p.router.Register(in)
+}
+
+// Classify determines the intent of a message without handling it.
+func (p *Processor) Classify(ctx context.Context, message string) intent.Result {
+	return p.router.Classify(ctx, message)
+}
+
+// IsPrivate reports whether answers to the named intent may contain content only
+// the requester may see, so they must be shown to the requester alone.
+func (p *Processor) IsPrivate(name string) bool {
+	return p.router.Private(name)
+}
+
+// Handle runs the handler for an already classified message.
+func (p *Processor) Handle(ctx context.Context, req intent.Request, result intent.Result) string {
+	return p.router.Route(ctx, req, result)
+}
+
+func (p *Processor) handleSummarize(ctx context.Context, req intent.Request, slots intent.Slots) string {
+	if req.DM {
+		p.slackClient.SendMessage(req.UserID, "Working on your summary. This might take a moment...")
+	}
+	if slots.Scope == intent.ScopeThread {
+		if req.ThreadTS == "" {
+			return "Mention me inside a thread and I'll summarize just that thread."
+		}
+		return p.summarizeThread(ctx, req.UserID, req.ChannelID, req.ThreadTS)
+	}
+	return p.performSummary(ctx, req.UserID, slots, req.ChannelID)
+}
+
+func (p *Processor) handleMentions(ctx context.Context, req intent.Request, slots intent.Slots) string {
+	if req.DM {
+		p.slackClient.SendMessage(req.UserID, "Working on your request. This might take a moment...")
+	}
+	return p.catchUp(ctx, req.UserID)
+}
+
+func (p *Processor) handleChat(ctx context.Context, req intent.Request, slots intent.Slots) string {
+	if req.DM {
+		return p.converse(ctx, req.UserID, req.History, req.Text)
+	}
+	var thread string
+	if req.ThreadTS != "" {
+		thread = p.threadContext(ctx, req.UserID, req.ChannelID, req.ThreadTS)
+	}
+	return p.respondToMention(ctx, req.Text, p.sessionContext(ctx, req.UserID, req.ChannelID, req.Text), thread)
+}
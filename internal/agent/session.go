@@ -0,0 +1,273 @@
+package agent
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gemini/go-service-communicator/internal/intent"
+	slackgo "github.com/slack-go/slack"
+)
+
+const (
+	// ActionEndSession is the action ID of the button that ends a summary session.
+	ActionEndSession = "end_summary_session"
+
+	// sessionBucket holds each user's summary sessions, keyed by user ID. A
+	// user's sessions are stored together, by channel, so that finding them
+	// reads one entry.
+	sessionBucket = "user_summary_sessions"
+	// defaultSessionTTL is how long a summary session lasts after its last use.
+	defaultSessionTTL = 24 * time.Hour
+)
+
+// channelRefRegex matches channel references like <#C0123456789|name> or <#C0123456789>.
+var channelRefRegex = regexp.MustCompile(`<#([A-Z0-9]+)(\|[^>]*)?>`)
+
+// SummarySession keeps a summary and the messages it was built from, so that a
+// user can ask several follow-up questions about it. A user has at most one
+// session per channel; summaries that cover no single channel share the "" channel.
+type SummarySession struct {
+	ID          string
+	UserID      string
+	ChannelID   string
+	Summary     string
+	InitialData []slackgo.Message
+	Created     time.Time
+	// Expires is pushed back every time the session is used.
+	Expires time.Time
+}
+
+// StartSession opens a follow-up session for a summary the user was just shown,
+// replacing the user's previous session for the same channel. It returns the
+// summary with a note and an "End session" button appended.
+func (p *Processor) StartSession(ctx context.Context, userID, channelID, summary string, initialData []slackgo.Message) string {
+	buf := make([]byte, 8)
+	rand.Read(buf)
+	now := time.Now()
+	session := SummarySession{
+		ID:          hex.EncodeToString(buf),
+		UserID:      userID,
+		ChannelID:   channelID,
+		Summary:     summary,
+		InitialData: initialData,
+		Created:     now,
+		Expires:     now.Add(p.sessionTTL),
+	}
+	if err := p.saveSession(ctx, session); err != nil {
+		log.Printf("Error storing summary session for user %s in channel %s: %v", userID, channelID, err)
+		return summary
+	}
+	log.Printf("Started summary session %s for user %s in channel %s", session.ID, userID, channelID)
+
+	note := fmt.Sprintf("Ask me follow-up questions about this summary until %s, or end the session when you're done.", session.Expires.Format("Jan 2 15:04 MST"))
+	return appendBlocks(summary, note,
+		map[string]interface{}{
+			"type":     "context",
+			"elements": []map[string]string{{"type": "mrkdwn", "text": note}},
+		},
+		map[string]interface{}{
+			"type": "actions",
+			"elements": []map[string]interface{}{{
+				"type":      "button",
+				"action_id": ActionEndSession,
+				"value":     session.ID,
+				"text":      map[string]string{"type": "plain_text", "text": "End session"},
+			}},
+		})
+}
+
+// EndSession ends the user's session with the given ID. It reports whether
+// there was such a session.
+func (p *Processor) EndSession(ctx context.Context, userID, sessionID string) bool {
+	ended := false
+	err := p.updateSessions(ctx, userID, func(sessions map[string]SummarySession) {
+		for channelID, session := range sessions {
+			if session.ID == sessionID {
+				delete(sessions, channelID)
+				ended = true
+			}
+		}
+	})
+	if err != nil {
+		log.Printf("Error ending summary session %s: %v", sessionID, err)
+		return false
+	}
+	if ended {
+		log.Printf("Ended summary session %s for user %s", sessionID, userID)
+	}
+	return ended
+}
+
+// Sessions returns the user's live sessions, most recently used first.
+func (p *Processor) Sessions(ctx context.Context, userID string) []SummarySession {
+	data, ok, err := p.store.Get(ctx, sessionBucket, userID)
+	if err != nil {
+		log.Printf("Error loading summary sessions of user %s: %v", userID, err)
+		return nil
+	}
+	if !ok {
+		return nil
+	}
+	var stored map[string]SummarySession
+	if err := json.Unmarshal(data, &stored); err != nil {
+		log.Printf("Discarding unreadable summary sessions of user %s: %v", userID, err)
+		return nil
+	}
+	now := time.Now()
+	var sessions []SummarySession
+	for _, session := range stored {
+		if session.Expires.After(now) {
+			sessions = append(sessions, session)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].Expires.After(sessions[j].Expires) })
+	return sessions
+}
+
+// HasSession reports whether the user has a live session for channelID, whose
+// content a chat answer in that channel may quote.
+func (p *Processor) HasSession(ctx context.Context, userID, channelID string) bool {
+	for _, session := range p.Sessions(ctx, userID) {
+		if session.ChannelID == channelID {
+			return true
+		}
+	}
+	return false
+}
+
+// findSession picks the session a message refers to. In a channel that is only
+// the session of that channel, since answers there may be seen by others. In a
+// DM (channelID "") it is the session of a channel the message links to, else
+// the most recently used one. Using a session extends it.
+func (p *Processor) findSession(ctx context.Context, userID, channelID, message string) (SummarySession, bool) {
+	sessions := p.Sessions(ctx, userID)
+	if len(sessions) == 0 {
+		return SummarySession{}, false
+	}
+
+	pick := func(channel string) (SummarySession, bool) {
+		for _, session := range sessions {
+			if session.ChannelID == channel {
+				return session, true
+			}
+		}
+		return SummarySession{}, false
+	}
+
+	var session SummarySession
+	found := false
+	if channelID != "" {
+		session, found = pick(channelID)
+	} else {
+		for _, match := range channelRefRegex.FindAllStringSubmatch(message, -1) {
+			if session, found = pick(match[1]); found {
+				break
+			}
+		}
+		if !found {
+			session, found = sessions[0], true
+		}
+	}
+	if !found {
+		return SummarySession{}, false
+	}
+
+	session.Expires = time.Now().Add(p.sessionTTL)
+	if err := p.saveSession(ctx, session); err != nil {
+		log.Printf("Error extending summary session %s: %v", session.ID, err)
+	}
+	return session, true
+}
+
+func (p *Processor) saveSession(ctx context.Context, session SummarySession) error {
+	return p.updateSessions(ctx, session.UserID, func(sessions map[string]SummarySession) {
+		sessions[session.ChannelID] = session
+	})
+}
+
+// updateSessions applies fn to the user's sessions, by channel, and stores the
+// result. Expired sessions are dropped first.
+func (p *Processor) updateSessions(ctx context.Context, userID string, fn func(map[string]SummarySession)) error {
+	// Every save pushes a session's expiry to sessionTTL from now, so the entry
+	// outlives all of its sessions.
+	return p.store.Update(ctx, sessionBucket, userID, p.sessionTTL, func(old []byte) ([]byte, error) {
+		sessions := make(map[string]SummarySession)
+		if old != nil {
+			if err := json.Unmarshal(old, &sessions); err != nil {
+				log.Printf("Discarding unreadable summary sessions of user %s: %v", userID, err)
+				sessions = make(map[string]SummarySession)
+			}
+		}
+		now := time.Now()
+		for channelID, session := range sessions {
+			if !session.Expires.After(now) {
+				delete(sessions, channelID)
+			}
+		}
+		fn(sessions)
+		if len(sessions) == 0 {
+			return nil, nil
+		}
+		return json.Marshal(sessions)
+	})
+}
+
+// sessionContext renders the session a message refers to as prompt context.
+// It returns "" if there is no such session.
+func (p *Processor) sessionContext(ctx context.Context, userID, channelID, message string) string {
+	session, ok := p.findSession(ctx, userID, channelID, message)
+	if !ok {
+		return ""
+	}
+
+	log.Printf("Using summary session %s for user %s", session.ID, userID)
+	var builder strings.Builder
+	builder.WriteString("CONTEXT: The user was shown the following summary. Use this summary and the initial data to answer follow-up questions about it.\n")
+	if session.ChannelID != "" {
+		builder.WriteString(fmt.Sprintf("(The summary was for channel <#%s>)\n", session.ChannelID))
+	}
+	builder.WriteString("--- SUMMARY START ---\n")
+	builder.WriteString(session.Summary)
+	builder.WriteString("\n--- SUMMARY END ---\n\n")
+
+	// The initial data fills what is left of the prompt budget, keeping the most
+	// recent messages.
+	lines := formatMessagesForLLM(session.InitialData, p.slackClient, userID)
+	budget := p.lineBudget() - estimateTokens(session.Summary)
+	first := len(lines)
+	for first > 0 && budget-estimateTokens(lines[first-1]) >= 0 {
+		first--
+		budget -= estimateTokens(lines[first])
+	}
+	if first < len(lines) {
+		builder.WriteString("--- INITIAL DATA START ---\n")
+		if first > 0 {
+			builder.WriteString(fmt.Sprintf("(%d earlier messages omitted)\n", first))
+		}
+		for _, line := range lines[first:] {
+			builder.WriteString(line + "\n")
+		}
+		builder.WriteString("--- INITIAL DATA END ---\n\n")
+	}
+	return builder.String()
+}
+
+// handleEndSession ends the session findSession picks for the message.
+func (p *Processor) handleEndSession(ctx context.Context, req intent.Request, slots intent.Slots) string {
+	session, ok := p.findSession(ctx, req.UserID, req.ChannelID, req.Text)
+	if !ok || !p.EndSession(ctx, req.UserID, session.ID) {
+		return "You don't have an active summary session here."
+	}
+	if session.ChannelID == "" {
+		return "Done! I've ended your summary session."
+	}
+	return fmt.Sprintf("Done! I've ended your summary session for <#%s>.", session.ChannelID)
+}
@@ -0,0 +1,346 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+
+	"github.com/gemini/go-service-communicator/internal/services/slack"
+	slackgo "github.com/slack-go/slack"
+)
+
+const (
+	// defaultContextTokens is the prompt budget used when none is configured.
+	// It is deliberately conservative so that it fits most hosted and self-hosted models.
+	defaultContextTokens = 24000
+	// promptReserveTokens is kept free for the prompt instructions and the model's answer.
+	promptReserveTokens = 4000
+	// maxParallelChunks bounds how many chunk summaries are generated at once.
+	maxParallelChunks = 4
+)
+
+// digest is the LLM input for a summary. When the formatted messages fit into the
+// context window Lines holds them verbatim; otherwise it holds partial summaries
+// produced by the map step.
+type digest struct {
+	Lines        []string
+	Partial      bool
+	MessageCount int
+	ChannelCount int
+	// Truncated names the channels whose history hit the message cap, so only
+	// their most recent messages are included.
+	Truncated []string
+	// Skipped lists the channels left out of a workspace-wide summary.
+	Skipped []slack.SkippedChannel
+	// Hidden counts the conversations left out because the user is not a member.
+	Hidden int
+}
+
+// maxSkippedListed bounds how many skipped channels are named in a summary.
+const maxSkippedListed = 10
+
+// SetContextTokens sets the approximate number of prompt tokens the model accepts.
+// Histories larger than this are summarized chunk by chunk.
+func (p *Processor) SetContextTokens(tokens int) {
+	if tokens > 0 {
+		p.contextTokens = tokens
+	}
+}
+
+// estimateTokens roughly estimates the number of tokens in s (about four characters per token).
+func estimateTokens(s string) int {
+	return len(s)/4 + 1
+}
+
+// lineBudget returns the number of tokens available for message lines in a single prompt.
+func (p *Processor) lineBudget() int {
+	budget := p.contextTokens - promptReserveTokens
+	if budget < 1000 {
+		budget = 1000
+	}
+	return budget
+}
+
+// buildDigest formats messages for the LLM and, if they don't fit into one prompt,
+// summarizes them chunk by chunk (map) and merges the partial summaries (reduce).
+// truncatedChannels lists the IDs of channels whose history was cut off.
+func (p *Processor) buildDigest(ctx context.Context, userID string, messages []slackgo.Message, truncatedChannels []string) digest {
+	formatted := formatMessagesForLLM(messages, p.slackClient, userID)
+	d := digest{MessageCount: len(messages), ChannelCount: countChannels(messages)}
+	for _, channelID := range truncatedChannels {
+		d.Truncated = append(d.Truncated, "#"+p.slackClient.GetChannelName(channelID))
+	}
+
+	budget := p.lineBudget()
+	total := 0
+	for _, line := range formatted {
+		total += estimateTokens(line)
+	}
+	if total <= budget {
+		d.Lines = formatted
+		return d
+	}
+
+	chunks := chunkMessages(messages, formatted, budget)
+	log.Printf("Summarizing %d messages (~%d tokens) in %d chunks", len(messages), total, len(chunks))
+
+	partials := p.summarizeChunks(ctx, chunks)
+	d.Lines = p.reducePartials(ctx, partials, budget)
+	d.Partial = true
+	return d
+}
+
+// chunkMessages groups formatted messages into chunks that each fit the token budget.
+// Messages are grouped by channel first; small channels share a chunk and channels
+// that are too large on their own are split by time.
+func chunkMessages(messages []slackgo.Message, formatted []string, budget int) [][]string {
+	var channelOrder []string
+	byChannel := make(map[string][]string)
+	for i, msg := range messages {
+		if _, ok := byChannel[msg.Channel]; !ok {
+			channelOrder = append(channelOrder, msg.Channel)
+		}
+		byChannel[msg.Channel] = append(byChannel[msg.Channel], formatted[i])
+	}
+
+	var chunks [][]string
+	var current []string
+	currentTokens := 0
+	flush := func() {
+		if len(current) > 0 {
+			chunks = append(chunks, current)
+			current = nil
+			currentTokens = 0
+		}
+	}
+
+	for _, channelID := range channelOrder {
+		lines := byChannel[channelID]
+		channelTokens := 0
+		for _, line := range lines {
+			channelTokens += estimateTokens(line)
+		}
+
+		// Keep a channel in one chunk whenever possible.
+		if currentTokens+channelTokens > budget {
+			flush()
+		}
+		if channelTokens <= budget {
+			current = append(current, lines...)
+			currentTokens += channelTokens
+			continue
+		}
+
+		// The channel alone exceeds the budget: split it into consecutive time windows.
+		for _, line := range lines {
+			tokens := estimateTokens(line)
+			if currentTokens+tokens > budget {
+				flush()
+			}
+			current = append(current, line)
+			currentTokens += tokens
+		}
+		flush()
+	}
+	flush()
+	return chunks
+}
+
+// summarizeChunks generates a plain-text partial summary for every chunk of messages.
+func (p *Processor) summarizeChunks(ctx context.Context, chunks [][]string) []string {
+	prompts := make([]string, len(chunks))
+	for i, chunk := range chunks {
+		var builder strings.Builder
+		builder.WriteString(`Summarize the following Slack messages as concise plain-text bullet points.
+Keep channel names, people, decisions, open questions and action items. Do not use JSON.
+
+Slack Messages:
+`)
+		writeMessageLines(&builder, chunk)
+		prompts[i] = builder.String()
+	}
+	return p.generateAll(ctx, prompts)
+}
+
+// reducePartials merges partial summaries until they fit the token budget.
+func (p *Processor) reducePartials(ctx context.Context, partials []string, budget int) []string {
+	for {
+		total := 0
+		for _, partial := range partials {
+			total += estimateTokens(partial)
+		}
+		if total <= budget || len(partials) <= 1 {
+			return partials
+		}
+
+		var groups [][]string
+		var current []string
+		currentTokens := 0
+		for _, partial := range partials {
+			tokens := estimateTokens(partial)
+			if len(current) > 0 && currentTokens+tokens > budget {
+				groups = append(groups, current)
+				current = nil
+				currentTokens = 0
+			}
+			current = append(current, partial)
+			currentTokens += tokens
+		}
+		groups = append(groups, current)
+
+		if len(groups) >= len(partials) {
+			// Every partial summary is already as large as the budget; merging won't help.
+			log.Printf("Partial summaries still exceed the context budget; the final prompt may be truncated")
+			return partials
+		}
+
+		prompts := make([]string, len(groups))
+		for i, group := range groups {
+			prompts[i] = "Merge these partial summaries of Slack conversations into one concise plain-text list of bullet points. Keep decisions, open questions and action items. Do not use JSON.\n\n" + strings.Join(group, "\n\n")
+		}
+		partials = p.generateAll(ctx, prompts)
+	}
+}
+
+// generateAll runs the prompts with bounded concurrency and returns the cleaned
+// responses in the same order. A failed prompt yields a placeholder note.
+func (p *Processor) generateAll(ctx context.Context, prompts []string) []string {
+	results := make([]string, len(prompts))
+	sem := make(chan struct{}, maxParallelChunks)
+	var wg sync.WaitGroup
+
+	for i, prompt := range prompts {
+		wg.Add(1)
+		go func(i int, prompt string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			response, err := p.generate(ctx, FeatureSummary, prompt)
+			if err != nil {
+				log.Printf("Error summarizing part %d of %d: %v", i+1, len(prompts), err)
+				results[i] = fmt.Sprintf("(Part %d of the conversation could not be summarized.)", i+1)
+				return
+			}
+			results[i] = cleanGeminiResponse(response)
+		}(i, prompt)
+	}
+	wg.Wait()
+	return results
+}
+
+// writeDigest writes the digest into a prompt under an appropriate heading.
+func writeDigest(builder *strings.Builder, heading string, d digest) {
+	if len(d.Truncated) > 0 {
+		builder.WriteString(fmt.Sprintf("Note: only the most recent messages of %s could be read, so older messages in the period are missing. Say so in the summary.\n\n", strings.Join(d.Truncated, ", ")))
+	}
+	if d.Partial {
+		builder.WriteString(fmt.Sprintf("%s (partial summaries, each covering a slice of %d messages):\n", heading, d.MessageCount))
+		for _, partial := range d.Lines {
+			builder.WriteString(partial + "\n\n")
+		}
+		return
+	}
+	builder.WriteString(heading + ":\n")
+	writeMessageLines(builder, d.Lines)
+}
+
+// writeMessageLines writes formatted messages as a bullet list, nesting thread
+// replies under their parent and explaining the nesting to the model.
+func writeMessageLines(builder *strings.Builder, lines []string) {
+	replies := false
+	for _, line := range lines {
+		if strings.HasPrefix(line, threadReplyMarker) {
+			builder.WriteString("    - " + line + "\n")
+			replies = true
+			continue
+		}
+		builder.WriteString("- " + line + "\n")
+	}
+	if replies {
+		builder.WriteString("\n(Indented lines starting with " + strings.TrimSpace(threadReplyMarker) + " are replies in the thread started by the message above them; treat each thread as one discussion.)\n")
+	}
+}
+
+// appendCoverageBlock adds a context block stating how many messages the summary covers
+// and which channels were truncated. If the summary is not a JSON block array the note
+// is appended as text.
+func appendCoverageBlock(summary string, d digest) string {
+	note := fmt.Sprintf("Covered %d messages across %d channel(s).", d.MessageCount, d.ChannelCount)
+	if len(d.Truncated) > 0 {
+		note += fmt.Sprintf(" %s had more messages than I can read, so only the most recent ones are included.", strings.Join(d.Truncated, ", "))
+	}
+	note += skippedNote(d.Skipped, d.Hidden)
+
+	return appendBlocks(summary, note, map[string]interface{}{
+		"type": "context",
+		"elements": []map[string]string{
+			{"type": "mrkdwn", "text": note},
+		},
+	})
+}
+
+// appendBlocks adds extra blocks to the end of a JSON block array, dropping
+// trailing blocks of the summary if needed to stay under Slack's block limit. If
+// the summary is not a JSON block array, fallback is appended as text instead.
+func appendBlocks(summary, fallback string, extra ...interface{}) string {
+	var blocks []json.RawMessage
+	if err := json.Unmarshal([]byte(summary), &blocks); err != nil {
+		return summary + "\n\n_" + fallback + "_"
+	}
+
+	var encoded []json.RawMessage
+	for _, block := range extra {
+		data, err := json.Marshal(block)
+		if err != nil {
+			return summary
+		}
+		encoded = append(encoded, data)
+	}
+	if keep := slack.MaxBlocks - len(encoded); len(blocks) > keep {
+		blocks = blocks[:keep]
+	}
+	blocks = append(blocks, encoded...)
+
+	out, err := json.Marshal(blocks)
+	if err != nil {
+		return summary
+	}
+	return string(out)
+}
+
+// skippedNote names the channels left out of a summary and why, and counts the
+// conversations hidden from the user without naming them. It returns "" if none were left out.
+func skippedNote(skipped []slack.SkippedChannel, hidden int) string {
+	var note string
+	if hidden > 0 {
+		note = fmt.Sprintf(" Left out %d conversation(s) you are not a member of.", hidden)
+	}
+	if len(skipped) == 0 {
+		return note
+	}
+	var entries []string
+	for i, channel := range skipped {
+		if i == maxSkippedListed {
+			entries = append(entries, fmt.Sprintf("and %d more", len(skipped)-maxSkippedListed))
+			break
+		}
+		name := "#" + channel.Name
+		if channel.Name == "" {
+			name = "<#" + channel.ID + ">"
+		}
+		entries = append(entries, fmt.Sprintf("%s (%s)", name, channel.Reason))
+	}
+	return note + fmt.Sprintf(" Skipped %d channel(s): %s.", len(skipped), strings.Join(entries, ", "))
+}
+
+func countChannels(messages []slackgo.Message) int {
+	seen := make(map[string]struct{})
+	for _, msg := range messages {
+		seen[msg.Channel] = struct{}{}
+	}
+	return len(seen)
+}
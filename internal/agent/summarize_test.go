@@ -0,0 +1,193 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gemini/go-service-communicator/internal/llm"
+	slackgo "github.com/slack-go/slack"
+)
+
+// fakeLLM answers every prompt through respond and records the prompts.
+type fakeLLM struct {
+	mu      sync.Mutex
+	prompts []string
+	respond func(prompt string) (string, error)
+}
+
+func (f *fakeLLM) Generate(ctx context.Context, prompt string, opts ...llm.Option) (string, error) {
+	f.mu.Lock()
+	f.prompts = append(f.prompts, prompt)
+	f.mu.Unlock()
+	return f.respond(prompt)
+}
+
+func (f *fakeLLM) calls() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.prompts...)
+}
+
+// tokens returns a line of exactly n estimated tokens that starts with id.
+func tokens(id string, n int) string {
+	return id + strings.Repeat(".", 4*(n-1)-len(id))
+}
+
+// lineIDs strips the padding added by tokens.
+func lineIDs(chunks [][]string) [][]string {
+	var ids [][]string
+	for _, chunk := range chunks {
+		var chunkIDs []string
+		for _, line := range chunk {
+			chunkIDs = append(chunkIDs, strings.TrimRight(line, "."))
+		}
+		ids = append(ids, chunkIDs)
+	}
+	return ids
+}
+
+func TestChunkMessages(t *testing.T) {
+	// Every message is 10 tokens and the budget is 30, so a chunk holds three.
+	const budget = 30
+	tests := []struct {
+		name     string
+		channels []string
+		want     string
+	}{
+		{"nothing", nil, "[]"},
+		{"small channels share a chunk", []string{"C1", "C2", "C3"}, "[[C1-1 C2-1 C3-1]]"},
+		{"a channel that fits is kept whole", []string{"C1", "C1", "C2", "C2"}, "[[C1-1 C1-2] [C2-1 C2-2]]"},
+		{"messages are grouped by channel in order of appearance", []string{"C1", "C2", "C1"}, "[[C1-1 C1-2 C2-1]]"},
+		{"exactly the budget", []string{"C1", "C1", "C1"}, "[[C1-1 C1-2 C1-3]]"},
+		{"a large channel is split by time", []string{"C1", "C1", "C1", "C1", "C1", "C1", "C1"}, "[[C1-1 C1-2 C1-3] [C1-4 C1-5 C1-6] [C1-7]]"},
+		{
+			"a large channel gets chunks of its own",
+			[]string{"C1", "C2", "C2", "C2", "C2", "C3"},
+			"[[C1-1] [C2-1 C2-2 C2-3] [C2-4] [C3-1]]",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var messages []slackgo.Message
+			var formatted []string
+			seen := make(map[string]int)
+			for _, channel := range tt.channels {
+				seen[channel]++
+				msg := slackgo.Message{}
+				msg.Channel = channel
+				messages = append(messages, msg)
+				formatted = append(formatted, tokens(fmt.Sprintf("%s-%d", channel, seen[channel]), 10))
+			}
+
+			chunks := chunkMessages(messages, formatted, budget)
+			if got := fmt.Sprint(lineIDs(chunks)); got != tt.want {
+				t.Errorf("chunks = %s, want %s", got, tt.want)
+			}
+			for _, chunk := range chunks {
+				total := 0
+				for _, line := range chunk {
+					total += estimateTokens(line)
+				}
+				if total > budget {
+					t.Errorf("chunk %v has %d tokens, over the budget of %d", lineIDs([][]string{chunk}), total, budget)
+				}
+			}
+		})
+	}
+}
+
+func TestReducePartials(t *testing.T) {
+	// merge answers a merge prompt with a summary of the given size that names
+	// the partials it merged.
+	merge := func(size int) func(string) (string, error) {
+		return func(prompt string) (string, error) {
+			var ids []string
+			for _, part := range strings.Split(prompt, "\n\n")[1:] {
+				ids = append(ids, strings.TrimRight(part, "."))
+			}
+			return tokens("("+strings.Join(ids, "+")+")", size), nil
+		}
+	}
+	partials := func(n, size int) []string {
+		var out []string
+		for i := 1; i <= n; i++ {
+			out = append(out, tokens(fmt.Sprintf("p%d", i), size))
+		}
+		return out
+	}
+
+	tests := []struct {
+		name      string
+		partials  []string
+		respond   func(string) (string, error)
+		want      string
+		wantCalls int
+	}{
+		{
+			name:     "fits the budget",
+			partials: partials(2, 10),
+			want:     "[p1 p2]",
+		},
+		{
+			name:      "merged in groups that fit the budget",
+			partials:  partials(4, 10),
+			respond:   merge(5),
+			want:      "[(p1+p2) (p3+p4)]",
+			wantCalls: 2,
+		},
+		{
+			name:      "merged again until it fits",
+			partials:  partials(8, 10),
+			respond:   merge(10),
+			want:      "[((p1+p2)+(p3+p4)) ((p5+p6)+(p7+p8))]",
+			wantCalls: 6,
+		},
+		{
+			name:     "every partial over the budget is left alone",
+			partials: partials(3, 30),
+			want:     "[p1 p2 p3]",
+		},
+		{
+			name:     "a single partial is left alone",
+			partials: partials(1, 100),
+			want:     "[p1]",
+		},
+		{
+			name:      "failed merges leave a note",
+			partials:  partials(4, 10),
+			respond:   func(string) (string, error) { return "", errors.New("boom") },
+			want:      "[(Part 1 of the conversation could not be summarized.) (Part 2 of the conversation could not be summarized.)]",
+			wantCalls: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeLLM{respond: tt.respond}
+			if fake.respond == nil {
+				fake.respond = func(string) (string, error) { return "", errors.New("unexpected call") }
+			}
+			p := New(fake, nil, nil)
+
+			got := p.reducePartials(context.Background(), tt.partials, 25)
+			var ids []string
+			for _, partial := range got {
+				ids = append(ids, strings.TrimRight(partial, "."))
+			}
+			if fmt.Sprint(ids) != tt.want {
+				t.Errorf("partials = %v, want %s", ids, tt.want)
+			}
+			if n := len(fake.calls()); n != tt.wantCalls {
+				t.Errorf("LLM called %d times, want %d", n, tt.wantCalls)
+			}
+			for _, prompt := range fake.calls() {
+				if !strings.HasPrefix(prompt, "Merge these partial summaries") {
+					t.Errorf("prompt = %q, want a merge prompt", prompt)
+				}
+			}
+		})
+	}
+}
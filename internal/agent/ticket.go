@@ -0,0 +1,205 @@
+package agent
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/gemini/go-service-communicator/internal/intent"
+	"github.com/gemini/go-service-communicator/internal/services/jira"
+	"github.com/gemini/go-service-communicator/internal/services/slack"
+	slackgo "github.com/slack-go/slack"
+)
+
+const (
+	// ActionReviewTicket is the action ID of the button that opens the "create issue" modal.
+	ActionReviewTicket = "review_jira_ticket"
+	// draftBucket holds drafts between the review button being posted and clicked.
+	draftBucket = "issue_drafts"
+	// draftTTL is how long an unconfirmed draft is kept.
+	draftTTL = 30 * time.Minute
+	// ticketContextMessages is how many recent channel messages are drafted from
+	// when the request was not made inside a thread.
+	ticketContextMessages = 20
+)
+
+// IssueDraft is a Jira issue drafted from a Slack discussion, waiting for the user to confirm it.
+type IssueDraft struct {
+	ChannelID          string
+	ThreadTS           string
+	Summary            string   `json:"summary"`
+	Description        string   `json:"description"`
+	AcceptanceCriteria []string `json:"acceptance_criteria"`
+}
+
+// putDraft stores a draft until its review button is clicked and returns its ID.
+func (p *Processor) putDraft(ctx context.Context, draft IssueDraft) (string, error) {
+	data, err := json.Marshal(draft)
+	if err != nil {
+		return "", err
+	}
+	buf := make([]byte, 8)
+	rand.Read(buf)
+	id := hex.EncodeToString(buf)
+	if err := p.store.Put(ctx, draftBucket, id, data, draftTTL); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// TakeDraft returns and forgets the draft behind a review button.
+func (p *Processor) TakeDraft(ctx context.Context, id string) (IssueDraft, bool) {
+	var draft IssueDraft
+	found := false
+	err := p.store.Update(ctx, draftBucket, id, draftTTL, func(old []byte) ([]byte, error) {
+		found = old != nil && json.Unmarshal(old, &draft) == nil
+		return nil, nil
+	})
+	if err != nil {
+		log.Printf("Error loading draft %s: %v", id, err)
+		return IssueDraft{}, false
+	}
+	return draft, found
+}
+
+// DraftIssue asks the model to turn a thread (or, without threadTS, the recent
+// channel messages) into a Jira issue draft.
+func (p *Processor) DraftIssue(ctx context.Context, userID, channelID, threadTS string) (IssueDraft, error) {
+	var messages []slackgo.Message
+	var err error
+	if threadTS != "" {
+		messages, err = p.slackClient.GetThreadReplies(ctx, channelID, threadTS)
+	} else {
+		endTime := time.Now()
+		var history *slack.History
+		history, err = p.slackClient.GetConversationHistory(ctx, channelID, endTime.Add(-24*time.Hour), endTime)
+		if err == nil {
+			messages = history.Messages
+		}
+		if len(messages) > ticketContextMessages {
+			messages = messages[len(messages)-ticketContextMessages:]
+		}
+		for i := range messages {
+			messages[i].Channel = channelID
+		}
+	}
+	if err != nil {
+		return IssueDraft{}, fmt.Errorf("could not read the discussion: %w", err)
+	}
+	if len(messages) == 0 {
+		return IssueDraft{}, fmt.Errorf("there are no messages to draft from")
+	}
+
+	var builder strings.Builder
+	builder.WriteString(`Turn the following Slack discussion into a Jira issue.
+Reply with only a JSON object, without markdown, of this shape:
+{"summary": "<one-line title under 120 characters>", "description": "<problem statement and relevant context, plain text>", "acceptance_criteria": ["<testable criterion>", "..."]}
+
+Slack discussion:
+`)
+	for _, line := range formatMessagesForLLM(messages, p.slackClient, userID) {
+		builder.WriteString("- " + line + "\n")
+	}
+
+	response, err := p.generate(ctx, FeatureChat, builder.String())
+	if err != nil {
+		return IssueDraft{}, err
+	}
+
+	response = cleanGeminiResponse(response)
+	start, end := strings.Index(response, "{"), strings.LastIndex(response, "}")
+	if start < 0 || end < start {
+		return IssueDraft{}, fmt.Errorf("model did not return a draft: %q", response)
+	}
+	var draft IssueDraft
+	if err := json.Unmarshal([]byte(response[start:end+1]), &draft); err != nil {
+		return IssueDraft{}, fmt.Errorf("could not decode draft: %w", err)
+	}
+	draft.ChannelID = channelID
+	draft.ThreadTS = threadTS
+	if draft.ThreadTS == "" && len(messages) > 0 {
+		// Link the issue to the latest message so there is a thread to post into.
+		draft.ThreadTS = messages[len(messages)-1].Timestamp
+	}
+	return draft, nil
+}
+
+// CreateIssue files draft in Jira, posts the link into the Slack thread it came
+// from and returns the new issue key.
+func (p *Processor) CreateIssue(ctx context.Context, userID, projectKey, issueType string, draft IssueDraft) (string, error) {
+	description := draft.Description
+	if len(draft.AcceptanceCriteria) > 0 {
+		description += "\n\nAcceptance criteria:"
+		for _, criterion := range draft.AcceptanceCriteria {
+			description += "\n- " + criterion
+		}
+	}
+	if draft.ThreadTS != "" {
+		if permalink, err := p.slackClient.GetPermalink(draft.ChannelID, draft.ThreadTS); err == nil {
+			description += "\n\nSlack discussion: " + permalink
+		}
+	}
+
+	key, err := p.jiraClient.CreateIssue(ctx, jira.IssueInput{
+		ProjectKey:  projectKey,
+		IssueType:   issueType,
+		Summary:     draft.Summary,
+		Description: description,
+	})
+	if err != nil {
+		return "", err
+	}
+	log.Printf("User %s created Jira issue %s", userID, key)
+
+	if draft.ThreadTS != "" {
+		message := fmt.Sprintf("<@%s> filed <%s|%s>: %s", userID, p.jiraClient.BrowseURL(key), key, draft.Summary)
+		if err := p.slackClient.SendMessageInThread(draft.ChannelID, draft.ThreadTS, message); err != nil {
+			log.Printf("Error posting issue link to thread %s: %v", draft.ThreadTS, err)
+		}
+	}
+	return key, nil
+}
+
+func (p *Processor) handleFileTicket(ctx context.Context, req intent.Request, slots intent.Slots) string {
+	if !p.jiraClient.Configured() {
+		return "Jira isn't configured yet, so I can't file tickets. Please add your Jira site to config.yaml."
+	}
+	if req.DM {
+		return "Mention me inside the thread you want to turn into a ticket, or use the \"Create Jira issue\" message shortcut."
+	}
+
+	draft, err := p.DraftIssue(ctx, req.UserID, req.ChannelID, req.ThreadTS)
+	if err != nil {
+		log.Printf("Error drafting issue for user %s: %v", req.UserID, err)
+		return llmFailure(err, "Sorry, I couldn't draft a ticket from this discussion.")
+	}
+	id, err := p.putDraft(ctx, draft)
+	if err != nil {
+		log.Printf("Error saving draft for user %s: %v", req.UserID, err)
+		return "Sorry, I couldn't save the draft ticket. Please try again."
+	}
+
+	preview := fmt.Sprintf("*%s*\n%s", draft.Summary, draft.Description)
+	if len(draft.AcceptanceCriteria) > 0 {
+		preview += "\n\n*Acceptance criteria*\n• " + strings.Join(draft.AcceptanceCriteria, "\n• ")
+	}
+	if runes := []rune(preview); len(runes) > 2900 {
+		preview = string(runes[:2900]) + "…"
+	}
+
+	blocks := []slackgo.Block{
+		slackgo.NewSectionBlock(slackgo.NewTextBlockObject("mrkdwn", "Here's a draft ticket for this discussion:", false, false), nil, nil),
+		slackgo.NewSectionBlock(slackgo.NewTextBlockObject("mrkdwn", preview, false, false), nil, nil),
+		slackgo.NewActionBlock("", slackgo.NewButtonBlockElement(ActionReviewTicket, id, slackgo.NewTextBlockObject("plain_text", "Review & create", false, false)).WithStyle(slackgo.StylePrimary)),
+	}
+	out, err := json.Marshal(blocks)
+	if err != nil {
+		return preview
+	}
+	return string(out)
+}
@@ -0,0 +1,56 @@
+package agent
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gemini/go-service-communicator/internal/storage"
+)
+
+// ttlStore records the TTL of every Put.
+type ttlStore struct {
+	storage.Store
+	ttls map[string]time.Duration
+}
+
+func (s *ttlStore) Put(ctx context.Context, bucket, key string, value []byte, ttl time.Duration) error {
+	s.ttls[bucket+"/"+key] = ttl
+	return s.Store.Put(ctx, bucket, key, value, ttl)
+}
+
+func TestDraftsAreSharedThroughTheStore(t *testing.T) {
+	ctx := context.Background()
+	store := &ttlStore{Store: storage.NewMemoryStore(), ttls: make(map[string]time.Duration)}
+	// Two replicas: one posts the review button, the other receives the click.
+	poster, clicked := New(nil, nil, nil), New(nil, nil, nil)
+	poster.SetStorage(store, 0)
+	clicked.SetStorage(store, 0)
+
+	draft := IssueDraft{
+		ChannelID:          "C1",
+		ThreadTS:           "1714600000.000100",
+		Summary:            "Checkout fails for saved cards",
+		Description:        "Payments with a saved card time out.",
+		AcceptanceCriteria: []string{"Saved cards can be charged"},
+	}
+	id, err := poster.putDraft(ctx, draft)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ttl := store.ttls[draftBucket+"/"+id]; ttl != draftTTL {
+		t.Errorf("draft stored for %s, want %s", ttl, draftTTL)
+	}
+
+	got, ok := clicked.TakeDraft(ctx, id)
+	if !ok || !reflect.DeepEqual(got, draft) {
+		t.Errorf("TakeDraft = %+v, %v; want %+v", got, ok, draft)
+	}
+	if _, ok := clicked.TakeDraft(ctx, id); ok {
+		t.Error("a draft was taken twice")
+	}
+	if _, ok := poster.TakeDraft(ctx, "0123456789abcdef"); ok {
+		t.Error("took a draft that was never stored")
+	}
+}
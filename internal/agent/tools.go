@@ -0,0 +1,404 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/gemini/go-service-communicator/internal/llm"
+	"github.com/gemini/go-service-communicator/internal/services/slack"
+	"github.com/gemini/go-service-communicator/internal/util"
+	slackgo "github.com/slack-go/slack"
+)
+
+const (
+	defaultMaxToolSteps = 6
+	defaultToolTimeout  = 20 * time.Second
+	// maxToolOutputChars keeps a single tool result from flooding the context window.
+	maxToolOutputChars = 12000
+)
+
+var errToolsUnsupported = errors.New("provider does not support tool calling")
+
+// errNotMember is returned to the model when a tool asks for a conversation the user may not read.
+var errNotMember = errors.New("the user is not a member of that conversation, so it can't be read on their behalf")
+
+// agentTool is a capability exposed to the model through function calling.
+type agentTool struct {
+	def llm.Tool
+	run func(ctx context.Context, userID string, args json.RawMessage) (string, error)
+}
+
+// SetToolLimits bounds the tool-calling loop. Non-positive values keep the defaults.
+func (p *Processor) SetToolLimits(maxSteps int, toolTimeout time.Duration) {
+	if maxSteps > 0 {
+		p.maxToolSteps = maxSteps
+	}
+	if toolTimeout > 0 {
+		p.toolTimeout = toolTimeout
+	}
+}
+
+// tools returns the tools the model may call. Tools that need the user's
+// confirmation add their buttons to run.
+func (p *Processor) tools(run *agentRun) []agentTool {
+	return []agentTool{
+		{
+			def: llm.Tool{
+				Name:        "get_conversation_history",
+				Description: "Fetch the messages posted in a Slack channel during a recent time range.",
+				Parameters: &llm.Schema{
+					Type: "object",
+					Properties: map[string]*llm.Schema{
+						"channel_id": {Type: "string", Description: "Slack channel ID, e.g. C0123456789."},
+						"time_range": {Type: "string", Description: "How far back to look, e.g. \"24h\", \"7d\" or \"1m\". Defaults to 24h."},
+					},
+					Required: []string{"channel_id"},
+				},
+			},
+			run: p.toolConversationHistory,
+		},
+		{
+			def: llm.Tool{
+				Name:        "search_messages",
+				Description: "Search Slack messages with Slack search syntax, e.g. \"retries in:#payments after:2024-05-01\".",
+				Parameters: &llm.Schema{
+					Type: "object",
+					Properties: map[string]*llm.Schema{
+						"query": {Type: "string", Description: "Slack search query."},
+					},
+					Required: []string{"query"},
+				},
+			},
+			run: p.toolSearchMessages,
+		},
+		{
+			def: llm.Tool{
+				Name:        "get_channel_name",
+				Description: "Look up the name of a Slack channel by its ID.",
+				Parameters: &llm.Schema{
+					Type: "object",
+					Properties: map[string]*llm.Schema{
+						"channel_id": {Type: "string", Description: "Slack channel ID."},
+					},
+					Required: []string{"channel_id"},
+				},
+			},
+			run: p.toolChannelName,
+		},
+		{
+			def: llm.Tool{
+				Name:        "fetch_jira_issues",
+				Description: "Search Jira issues with a JQL query.",
+				Parameters: &llm.Schema{
+					Type: "object",
+					Properties: map[string]*llm.Schema{
+						"jql": {Type: "string", Description: "JQL query, e.g. \"project = PAY AND text ~ retries\"."},
+					},
+					Required: []string{"jql"},
+				},
+			},
+			run: p.toolFetchIssues,
+		},
+		{
+			def: llm.Tool{
+				Name:        "propose_jira_comment",
+				Description: "Propose a comment on a Jira issue. The comment is not posted until the user confirms it with a button shown below your answer. Only use this when the user explicitly asks for it.",
+				Parameters: &llm.Schema{
+					Type: "object",
+					Properties: map[string]*llm.Schema{
+						"issue_key": {Type: "string", Description: "Issue key, e.g. PAY-123."},
+						"comment":   {Type: "string", Description: "Comment text."},
+					},
+					Required: []string{"issue_key", "comment"},
+				},
+			},
+			run: func(ctx context.Context, userID string, raw json.RawMessage) (string, error) {
+				return p.toolProposeComment(ctx, userID, raw, run)
+			},
+		},
+	}
+}
+
+// runAgent answers latestMessage with a bounded plan/act loop in which the model may
+// call tools. It returns errToolsUnsupported if the provider can't call functions.
+func (p *Processor) runAgent(ctx context.Context, userID, summaryContext string, history []string, latestMessage string) (string, error) {
+	caller, ok := p.llm.(llm.ToolCaller)
+	if !ok {
+		return "", errToolsUnsupported
+	}
+
+	run := &agentRun{}
+	tools := p.tools(run)
+	defs := make([]llm.Tool, len(tools))
+	byName := make(map[string]agentTool, len(tools))
+	for i, tool := range tools {
+		defs[i] = tool.def
+		byName[tool.def.Name] = tool
+	}
+
+	system := fmt.Sprintf(`You are a helpful assistant inside Slack for user <@%s>. Today is %s.
+Use the available tools to look up Slack conversations and Jira issues whenever the question needs live data; don't guess.
+Channels appear as <#C0123456789|name>; pass only the ID to tools.
+When you are done, answer concisely in Slack mrkdwn text (not JSON), citing channels and issue keys you used.
+
+%s`, userID, time.Now().Format("Monday, 2 January 2006"), summaryContext)
+
+	messages := []llm.Message{{Role: llm.RoleSystem, Content: system}}
+	messages = append(messages, historyToMessages(history)...)
+	messages = append(messages, llm.Message{Role: llm.RoleUser, Content: latestMessage})
+
+	opts := p.featureOptions[FeatureAgent]
+	for step := 0; step < p.maxToolSteps; step++ {
+		resp, err := caller.Chat(ctx, messages, defs, opts...)
+		if err != nil {
+			return "", err
+		}
+		if len(resp.ToolCalls) == 0 {
+			return run.answer(resp.Content), nil
+		}
+
+		messages = append(messages, llm.Message{Role: llm.RoleAssistant, Content: resp.Content, ToolCalls: resp.ToolCalls})
+		for _, call := range resp.ToolCalls {
+			output := p.runTool(ctx, userID, byName, call)
+			messages = append(messages, llm.Message{Role: llm.RoleTool, Content: output, ToolCallID: call.ID, Name: call.Name})
+		}
+	}
+
+	log.Printf("Tool loop for user %s hit the step limit of %d; asking for a final answer", userID, p.maxToolSteps)
+	messages = append(messages, llm.Message{Role: llm.RoleUser, Content: "You have reached the tool call limit. Answer now with the information you already have, and say what you could not check."})
+	resp, err := caller.Chat(ctx, messages, nil, opts...)
+	if err != nil {
+		return "", err
+	}
+	return run.answer(resp.Content), nil
+}
+
+// runTool executes a single tool call with the per-tool timeout. Errors are returned
+// to the model as text so it can recover.
+func (p *Processor) runTool(ctx context.Context, userID string, tools map[string]agentTool, call llm.ToolCall) string {
+	tool, ok := tools[call.Name]
+	if !ok {
+		return fmt.Sprintf("error: unknown tool %q", call.Name)
+	}
+	log.Printf("Agent is calling tool %s with %s", call.Name, string(call.Arguments))
+
+	toolCtx, cancel := context.WithTimeout(ctx, p.toolTimeout)
+	defer cancel()
+
+	type result struct {
+		output string
+		err    error
+	}
+	done := make(chan result, 1)
+	go func() {
+		output, err := tool.run(toolCtx, userID, call.Arguments)
+		done <- result{output, err}
+	}()
+
+	select {
+	case <-toolCtx.Done():
+		return fmt.Sprintf("error: %s timed out after %s", call.Name, p.toolTimeout)
+	case r := <-done:
+		if r.err != nil {
+			return "error: " + r.err.Error()
+		}
+		if output := truncateRunes(r.output, maxToolOutputChars); output != r.output {
+			return output + "\n(output truncated)"
+		}
+		return r.output
+	}
+}
+
+func (p *Processor) toolConversationHistory(ctx context.Context, userID string, raw json.RawMessage) (string, error) {
+	var args struct {
+		ChannelID string `json:"channel_id"`
+		TimeRange string `json:"time_range"`
+	}
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return "", err
+	}
+
+	duration := 24 * time.Hour
+	if args.TimeRange != "" {
+		parsed, err := util.ParseDuration(args.TimeRange)
+		if err != nil {
+			return "", err
+		}
+		duration = parsed
+	}
+
+	if ok, err := p.slackClient.CanRead(ctx, userID, args.ChannelID); err != nil || !ok {
+		return "", errNotMember
+	}
+
+	endTime := time.Now()
+	startTime := endTime.Add(-duration)
+	history, err := p.slackClient.GetConversationHistory(ctx, args.ChannelID, startTime, endTime)
+	if err != nil {
+		return "", err
+	}
+	messages := history.Messages
+	if len(messages) == 0 {
+		return "No messages in that time range.", nil
+	}
+	for i := range messages {
+		messages[i].Channel = args.ChannelID
+	}
+	messages = p.slackClient.ExpandThreads(ctx, args.ChannelID, messages, startTime, endTime)
+	result := strings.Join(formatMessagesForLLM(messages, p.slackClient, userID), "\n")
+	if history.Truncated {
+		result = "(Only the most recent messages in this time range could be read.)\n" + result
+	}
+	return result, nil
+}
+
+func (p *Processor) toolSearchMessages(ctx context.Context, userID string, raw json.RawMessage) (string, error) {
+	var args struct {
+		Query string `json:"query"`
+	}
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return "", err
+	}
+
+	result, err := p.slackClient.SearchMessages(ctx, args.Query)
+	if err != nil {
+		return "", err
+	}
+	if result == nil {
+		return "No matching messages.", nil
+	}
+	matches := p.readableMatches(ctx, userID, result.Matches)
+	if len(matches) == 0 {
+		return "No matching messages.", nil
+	}
+
+	var builder strings.Builder
+	for _, match := range matches {
+		builder.WriteString(fmt.Sprintf("[Channel: %s] %s: %s\n", match.Channel.Name, match.Username, match.Text))
+	}
+	return builder.String(), nil
+}
+
+func (p *Processor) toolChannelName(ctx context.Context, userID string, raw json.RawMessage) (string, error) {
+	var args struct {
+		ChannelID string `json:"channel_id"`
+	}
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return "", err
+	}
+	if ok, err := p.slackClient.CanRead(ctx, userID, args.ChannelID); err != nil || !ok {
+		return "", errNotMember
+	}
+	return p.slackClient.GetChannelName(args.ChannelID), nil
+}
+
+func (p *Processor) toolFetchIssues(ctx context.Context, userID string, raw json.RawMessage) (string, error) {
+	var args struct {
+		JQL string `json:"jql"`
+	}
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return "", err
+	}
+
+	issues, err := p.jiraClient.FetchIssuesContext(ctx, args.JQL)
+	if err != nil {
+		return "", err
+	}
+	if len(issues) == 0 {
+		return "No matching issues.", nil
+	}
+	lines := make([]string, len(issues))
+	for i, issue := range issues {
+		lines[i] = issue.String()
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+func (p *Processor) toolProposeComment(ctx context.Context, userID string, raw json.RawMessage, run *agentRun) (string, error) {
+	var args struct {
+		IssueKey string `json:"issue_key"`
+		Comment  string `json:"comment"`
+	}
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return "", err
+	}
+
+	blocks, err := p.proposeComment(ctx, userID, args.IssueKey, args.Comment)
+	if err != nil {
+		return "", err
+	}
+	run.blocks = append(run.blocks, blocks...)
+	return fmt.Sprintf("The comment has not been posted yet. A button to add it to %s is shown below your answer; ask the user to review and confirm it.", args.IssueKey), nil
+}
+
+// historyToMessages converts the "User: ..." / "Assistant: ..." lines kept by the
+// event handler into chat messages.
+func historyToMessages(history []string) []llm.Message {
+	var messages []llm.Message
+	for _, line := range history {
+		switch {
+		case strings.HasPrefix(line, "User: "):
+			messages = append(messages, llm.Message{Role: llm.RoleUser, Content: strings.TrimPrefix(line, "User: ")})
+		case strings.HasPrefix(line, "Assistant: "):
+			messages = append(messages, llm.Message{Role: llm.RoleAssistant, Content: strings.TrimPrefix(line, "Assistant: ")})
+		}
+	}
+	return messages
+}
+
+// answer renders the model's final answer followed by the blocks tools added.
+func (run *agentRun) answer(content string) string {
+	answer := renderAnswer(content)
+	if len(run.blocks) == 0 {
+		return answer
+	}
+	return appendBlocks(answer, "Confirm the proposed changes with the buttons below.", run.blocks...)
+}
+
+// truncateRunes shortens s to at most limit characters without splitting one.
+func truncateRunes(s string, limit int) string {
+	if runes := []rune(s); len(runes) > limit {
+		return string(runes[:limit])
+	}
+	return s
+}
+
+// renderAnswer turns the model's final answer into Block Kit JSON.
+func renderAnswer(answer string) string {
+	answer = cleanGeminiResponse(answer)
+	if slack.ValidateBlocks(answer) == nil {
+		return answer
+	}
+	return slack.RenderFallbackBlocks(answer)
+}
+
+// readableMatches drops search results from private conversations userID is not a member of.
+func (p *Processor) readableMatches(ctx context.Context, userID string, matches []slackgo.SearchMessage) []slackgo.SearchMessage {
+	readable := make(map[string]bool)
+	var kept []slackgo.SearchMessage
+	for _, match := range matches {
+		channel := match.Channel
+		if !channel.IsPrivate && !channel.IsMPIM && !strings.HasPrefix(channel.ID, "D") {
+			kept = append(kept, match)
+			continue
+		}
+		ok, checked := readable[channel.ID]
+		if !checked {
+			var err error
+			ok, err = p.slackClient.CanRead(ctx, userID, channel.ID)
+			if err != nil {
+				log.Printf("Could not check whether user %s may read channel %s: %v", userID, channel.ID, err)
+			}
+			readable[channel.ID] = ok
+		}
+		if ok {
+			kept = append(kept, match)
+		}
+	}
+	return kept
+}
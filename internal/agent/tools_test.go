@@ -0,0 +1,214 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gemini/go-service-communicator/internal/llm"
+	"github.com/gemini/go-service-communicator/internal/services/jira"
+)
+
+// chatCall records the arguments of one ToolCaller.Chat call.
+type chatCall struct {
+	messages []llm.Message
+	tools    []llm.Tool
+}
+
+// scriptedCaller is a ToolCaller that plays back responses in order.
+type scriptedCaller struct {
+	mu        sync.Mutex
+	responses []llm.ChatResponse
+	calls     []chatCall
+}
+
+func (s *scriptedCaller) Generate(ctx context.Context, prompt string, opts ...llm.Option) (string, error) {
+	return "", errors.New("unexpected Generate call")
+}
+
+func (s *scriptedCaller) Chat(ctx context.Context, messages []llm.Message, tools []llm.Tool, opts ...llm.Option) (llm.ChatResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls = append(s.calls, chatCall{append([]llm.Message(nil), messages...), tools})
+	if len(s.responses) == 0 {
+		return llm.ChatResponse{}, errors.New("script exhausted")
+	}
+	resp := s.responses[0]
+	s.responses = s.responses[1:]
+	return resp, nil
+}
+
+func toolCall(id, name, args string) llm.ChatResponse {
+	return llm.ChatResponse{ToolCalls: []llm.ToolCall{{ID: id, Name: name, Arguments: json.RawMessage(args)}}}
+}
+
+// fakeJira serves the Jira REST API: searches block until the request is
+// cancelled and comments are recorded.
+type fakeJira struct {
+	mu       sync.Mutex
+	comments []string
+}
+
+func newFakeJira(t *testing.T) (*jira.Client, *fakeJira) {
+	t.Helper()
+	fake := &fakeJira{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/comment"):
+			body, _ := io.ReadAll(r.Body)
+			fake.mu.Lock()
+			fake.comments = append(fake.comments, r.URL.Path+" "+string(body))
+			fake.mu.Unlock()
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{}`))
+		case strings.Contains(r.URL.Path, "/search"):
+			<-r.Context().Done()
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return jira.New(jira.Config{BaseURL: server.URL, Deployment: jira.DeploymentDataCenter}), fake
+}
+
+func (f *fakeJira) posted() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.comments...)
+}
+
+func TestRunAgentAnswersAfterToolCalls(t *testing.T) {
+	caller := &scriptedCaller{responses: []llm.ChatResponse{
+		toolCall("call-1", "no_such_tool", `{}`),
+		{Content: "All done."},
+	}}
+	p := New(caller, nil, nil)
+
+	answer, err := p.runAgent(context.Background(), "U1", "", []string{"User: hi", "Assistant: hello"}, "what's up?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(answer, "All done.") {
+		t.Errorf("answer = %s, want the final response", answer)
+	}
+	if len(caller.calls) != 2 {
+		t.Fatalf("Chat called %d times, want 2", len(caller.calls))
+	}
+
+	first := caller.calls[0].messages
+	if roles := []string{first[0].Role, first[1].Role, first[2].Role, first[3].Role}; strings.Join(roles, " ") != "system user assistant user" || first[3].Content != "what's up?" {
+		t.Errorf("first messages = %+v, want the system prompt, the history and the question", first)
+	}
+	if len(caller.calls[0].tools) == 0 {
+		t.Error("no tools were offered")
+	}
+	last := caller.calls[1].messages[len(caller.calls[1].messages)-1]
+	if last.Role != llm.RoleTool || last.ToolCallID != "call-1" || !strings.Contains(last.Content, `unknown tool "no_such_tool"`) {
+		t.Errorf("tool result = %+v, want the unknown tool error for call-1", last)
+	}
+}
+
+func TestRunAgentStepLimit(t *testing.T) {
+	caller := &scriptedCaller{responses: []llm.ChatResponse{
+		toolCall("call-1", "no_such_tool", `{}`),
+		toolCall("call-2", "no_such_tool", `{}`),
+		{Content: "Here is what I found so far."},
+	}}
+	p := New(caller, nil, nil)
+	p.SetToolLimits(2, time.Second)
+
+	answer, err := p.runAgent(context.Background(), "U1", "", nil, "dig deep")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(answer, "Here is what I found so far.") {
+		t.Errorf("answer = %s, want the final response", answer)
+	}
+	if len(caller.calls) != 3 {
+		t.Fatalf("Chat called %d times, want 2 steps and a final answer", len(caller.calls))
+	}
+	final := caller.calls[2]
+	if final.tools != nil {
+		t.Errorf("the final call offered %d tools, want none", len(final.tools))
+	}
+	if last := final.messages[len(final.messages)-1]; last.Role != llm.RoleUser || !strings.Contains(last.Content, "tool call limit") {
+		t.Errorf("last message = %+v, want the step limit notice", last)
+	}
+}
+
+func TestRunAgentToolTimeout(t *testing.T) {
+	jiraClient, _ := newFakeJira(t)
+	caller := &scriptedCaller{responses: []llm.ChatResponse{
+		toolCall("call-1", "fetch_jira_issues", `{"jql": "project = PAY"}`),
+		{Content: "Jira didn't answer in time."},
+	}}
+	p := New(caller, nil, jiraClient)
+	p.SetToolLimits(0, 50*time.Millisecond)
+
+	start := time.Now()
+	if _, err := p.runAgent(context.Background(), "U1", "", nil, "any open PAY issues?"); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("runAgent took %s despite the tool timeout", elapsed)
+	}
+	last := caller.calls[1].messages[len(caller.calls[1].messages)-1]
+	if last.Role != llm.RoleTool || last.Content != "error: fetch_jira_issues timed out after 50ms" {
+		t.Errorf("tool result = %+v, want a timeout error", last)
+	}
+}
+
+func TestRunAgentConfirmsBeforeWriting(t *testing.T) {
+	jiraClient, fake := newFakeJira(t)
+	caller := &scriptedCaller{responses: []llm.ChatResponse{
+		toolCall("call-1", "propose_jira_comment", `{"issue_key": "PAY-1", "comment": "Fixed in 1.2"}`),
+		{Content: "I've drafted the comment; confirm it below."},
+	}}
+	p := New(caller, nil, jiraClient)
+
+	answer, err := p.runAgent(context.Background(), "U1", "", nil, "comment on PAY-1 that it's fixed in 1.2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if posted := fake.posted(); len(posted) != 0 {
+		t.Fatalf("comments were posted before confirmation: %v", posted)
+	}
+	if last := caller.calls[1].messages[len(caller.calls[1].messages)-1]; !strings.Contains(last.Content, "has not been posted yet") {
+		t.Errorf("tool result = %q, want it to say the comment awaits confirmation", last.Content)
+	}
+
+	m := regexp.MustCompile(`"value":"([0-9a-f]{16})"`).FindStringSubmatch(answer)
+	if m == nil || !strings.Contains(answer, `"action_id":"`+ActionConfirmComment+`"`) {
+		t.Fatalf("answer has no confirm button: %s", answer)
+	}
+	id := m[1]
+
+	if reply := p.ConfirmComment(context.Background(), "U2", id); !strings.Contains(reply, "expired") {
+		t.Errorf("another user's confirmation = %q, want it refused", reply)
+	}
+	if reply := p.ConfirmComment(context.Background(), "U1", id); !strings.Contains(reply, "Done!") {
+		t.Errorf("confirmation = %q, want the comment added", reply)
+	}
+	if reply := p.ConfirmComment(context.Background(), "U1", id); !strings.Contains(reply, "already added") {
+		t.Errorf("second confirmation = %q, want it refused", reply)
+	}
+	posted := fake.posted()
+	if len(posted) != 1 || !strings.Contains(posted[0], "/issue/PAY-1/comment") || !strings.Contains(posted[0], "Fixed in 1.2") {
+		t.Errorf("posted comments = %v, want one on PAY-1", posted)
+	}
+}
+
+func TestRunAgentNeedsToolCalling(t *testing.T) {
+	p := New(&fakeLLM{respond: func(string) (string, error) { return "", nil }}, nil, nil)
+	if _, err := p.runAgent(context.Background(), "U1", "", nil, "hi"); !errors.Is(err, errToolsUnsupported) {
+		t.Errorf("err = %v, want errToolsUnsupported", err)
+	}
+}
@@ -9,17 +9,221 @@ import (
 type Config struct {
 	Slack  SlackConfig  `mapstructure:"slack"`
 	Gemini GeminiConfig `mapstructure:"gemini"`
+	Jira   JiraConfig   `mapstructure:"jira"`
+	OpenAI OpenAIConfig `mapstructure:"openai"`
+	// LLMProvider selects the LLM backend: "gemini" (default) or "openai".
+	LLMProvider string `mapstructure:"llm_provider"`
+	// LLMContextTokens is the approximate prompt size the model accepts. Larger
+	// histories are summarized in chunks. 0 uses the agent default.
+	LLMContextTokens int           `mapstructure:"llm_context_tokens"`
+	Agent            AgentConfig   `mapstructure:"agent"`
+	Queue            QueueConfig   `mapstructure:"queue"`
+	Storage          StorageConfig `mapstructure:"storage"`
+	Digests          DigestConfig  `mapstructure:"digests"`
+}
+
+// DigestConfig tunes the scheduler that delivers "/summary subscribe" digests.
+// Subscriptions are kept in the configured storage.
+type DigestConfig struct {
+	// CheckIntervalSeconds is how often due subscriptions are looked for. 0 means one minute.
+	CheckIntervalSeconds int `mapstructure:"check_interval_seconds"`
+}
+
+// StorageConfig selects where DM history and summary sessions are kept.
+type StorageConfig struct {
+	// Backend is "memory" (default), "bolt" for a BoltDB file that survives
+	// restarts, or "redis" for a Redis server that several replicas can share.
+	Backend string `mapstructure:"backend"`
+	// Path is the BoltDB file. Empty means "communicator.db".
+	Path string `mapstructure:"path"`
+	// Redis is the server used by the "redis" backend.
+	Redis RedisConfig `mapstructure:"redis"`
+	// HistoryTTLSeconds is how long a DM conversation is remembered. 0 means one day.
+	HistoryTTLSeconds int `mapstructure:"history_ttl_seconds"`
+	// SessionTTLSeconds is how long a summary session lasts after its last
+	// follow-up question. 0 means one day.
+	SessionTTLSeconds int `mapstructure:"session_ttl_seconds"`
+}
+
+// RedisConfig locates the Redis server of the "redis" storage backend.
+type RedisConfig struct {
+	// Address is host:port. Empty means "localhost:6379".
+	Address  string `mapstructure:"address"`
+	Username string `mapstructure:"username"`
+	Password string `mapstructure:"password"`
+	DB       int    `mapstructure:"db"`
+	// KeyPrefix namespaces the keys, so deployments can share a server. Empty means "communicator:".
+	KeyPrefix string `mapstructure:"key_prefix"`
+}
+
+// QueueConfig sizes the worker pool that processes Slack requests.
+type QueueConfig struct {
+	// Workers is the number of requests processed at once. 0 means 4.
+	Workers int `mapstructure:"workers"`
+	// Size is the number of requests that may wait. 0 means 100.
+	Size int `mapstructure:"size"`
+	// PerUser caps one user's concurrent requests. 0 means 1; negative means no cap.
+	PerUser int `mapstructure:"per_user"`
+	// PerWorkspace caps one workspace's concurrent requests. 0 means no cap.
+	PerWorkspace int `mapstructure:"per_workspace"`
+	// MetricsToken is the bearer token required by GET /metrics/queue. Empty
+	// disables the endpoint.
+	MetricsToken string `mapstructure:"metrics_token"`
+}
+
+// AgentConfig bounds the tool-calling loop used to answer questions from live data.
+type AgentConfig struct {
+	// MaxToolSteps is the maximum number of model turns that may call tools. 0 uses the default.
+	MaxToolSteps int `mapstructure:"max_tool_steps"`
+	// ToolTimeoutSeconds bounds a single tool run. 0 uses the default.
+	ToolTimeoutSeconds int `mapstructure:"tool_timeout_seconds"`
+	// SummaryChannels selects the channels summarized when the user names none.
+	SummaryChannels ChannelScopeConfig `mapstructure:"summary_channels"`
+	// CatchUpLookbackHours is how far back "what did I miss" looks for users with
+	// no recent message or catch-up. 0 means 72 hours.
+	CatchUpLookbackHours int `mapstructure:"catch_up_lookback_hours"`
+}
+
+// ChannelScopeConfig selects channels for workspace-wide summaries.
+type ChannelScopeConfig struct {
+	// Include lists channel IDs or names; when set, only these channels are summarized.
+	Include []string `mapstructure:"include"`
+	// Exclude lists channel IDs or names that are never summarized.
+	Exclude []string `mapstructure:"exclude"`
+	// Types are the conversation types considered (public_channel, private_channel,
+	// mpim, im). Empty means public channels only.
+	Types []string `mapstructure:"types"`
+	// NamePatterns are glob patterns, e.g. "team-*", that channel names must match.
+	NamePatterns []string `mapstructure:"name_patterns"`
+	// MemberOnly limits summaries to channels the requesting user is a member of.
+	MemberOnly bool `mapstructure:"member_only"`
+	// MaxChannels caps the number of channels summarized. 0 means no cap.
+	MaxChannels int `mapstructure:"max_channels"`
 }
 
 // SlackConfig stores the configuration for the Slack service.
 type SlackConfig struct {
-	Token          string `mapstructure:"token"`
+	Token         string `mapstructure:"token"`
 	SigningSecret string `mapstructure:"signing_secret"`
+	// Mode is "http" (default) to receive Slack requests on /slack/* or "socket"
+	// to receive them over Socket Mode, which needs AppToken.
+	Mode string `mapstructure:"mode"`
+	// AppToken is an app-level token (xapp-...) with the connections:write scope.
+	AppToken string `mapstructure:"app_token"`
+	// SignatureMaxSkewSeconds is how old a signed request may be before it is
+	// rejected as a replay. 0 means 300 seconds.
+	SignatureMaxSkewSeconds int `mapstructure:"signature_max_skew_seconds"`
+	// Dedup configures how retried events are recognized.
+	Dedup DedupConfig `mapstructure:"dedup"`
+	// MaxThreadReplies caps the replies fetched per thread when summarizing. 0 means 50.
+	MaxThreadReplies int `mapstructure:"max_thread_replies"`
+	// MaxHistoryMessages caps the messages read from one channel's history. 0 means 1000.
+	MaxHistoryMessages int `mapstructure:"max_history_messages"`
+	// ThreadLookbackHours is how far before a summarized period threads are looked
+	// for that got replies during it. 0 means one week; negative turns this off.
+	ThreadLookbackHours int `mapstructure:"thread_lookback_hours"`
+	// APIURL overrides the Slack Web API base URL, e.g. for a proxy or a fake server in tests.
+	APIURL string `mapstructure:"api_url"`
+	// MaxRetries is how often a rate-limited or failed API call is retried. 0 means 5.
+	MaxRetries int `mapstructure:"max_retries"`
+	// RateLimits overrides the requests per minute made to individual API methods,
+	// e.g. {"conversations.history": 1}. Other methods follow Slack's published tiers.
+	RateLimits map[string]int `mapstructure:"rate_limits"`
+	// Cache bounds the user and channel name caches.
+	Cache SlackCacheConfig `mapstructure:"cache"`
+}
+
+// SlackCacheConfig sizes the caches that map user and channel IDs to names.
+type SlackCacheConfig struct {
+	// Size is the number of names kept per cache. 0 means 5000.
+	Size int `mapstructure:"size"`
+	// TTLSeconds is how long a name is trusted before it is looked up again. 0 means one hour.
+	TTLSeconds int `mapstructure:"ttl_seconds"`
+	// NegativeTTLSeconds is how long a failed lookup is remembered. 0 means five minutes.
+	NegativeTTLSeconds int `mapstructure:"negative_ttl_seconds"`
+	// Warmup loads all users and channels at startup through users.list and conversations.list.
+	Warmup bool `mapstructure:"warmup"`
+}
+
+// DedupConfig selects the store that remembers handled Slack events.
+type DedupConfig struct {
+	// Backend is "memory" (default) or "storage" to keep handled event IDs in the
+	// configured storage, so they survive restarts and, with Redis, are shared by replicas.
+	Backend string `mapstructure:"backend"`
+	// TTLSeconds is how long an event ID is remembered. 0 means one hour.
+	TTLSeconds int `mapstructure:"ttl_seconds"`
+}
+
+// JiraConfig stores the configuration for the Jira service.
+type JiraConfig struct {
+	// BaseURL is the site root, e.g. "https://example.atlassian.net". Leave empty to disable Jira.
+	BaseURL string `mapstructure:"base_url"`
+	// Deployment is "cloud" (default) or "datacenter".
+	Deployment string `mapstructure:"deployment"`
+	// Username and APIToken are used for basic auth (on Cloud the username is the account email).
+	Username string `mapstructure:"username"`
+	APIToken string `mapstructure:"api_token"`
+	// BearerToken is a Data Center personal access token; it takes precedence over basic auth.
+	BearerToken    string `mapstructure:"bearer_token"`
+	TimeoutSeconds int    `mapstructure:"timeout_seconds"`
+	// MaxIssues caps the number of issues returned by a search. 0 means 200.
+	MaxIssues int `mapstructure:"max_issues"`
+	// DefaultProject and DefaultIssueType prefill the "create issue" modal.
+	DefaultProject   string `mapstructure:"default_project"`
+	DefaultIssueType string `mapstructure:"default_issue_type"`
+	// DefaultJQL is the query template for channels without a mapping, e.g.
+	// "updated >= {{since}} ORDER BY updated DESC". Empty means summaries of
+	// unmapped channels have no Jira section.
+	DefaultJQL string `mapstructure:"default_jql"`
+	// Channels maps a Slack channel ID or name to the Jira issues shown in its summaries.
+	Channels map[string]JiraChannelConfig `mapstructure:"channels"`
+	// WebhookSecret authenticates deliveries to /jira/webhook. Webhooks are rejected while it is empty.
+	WebhookSecret string `mapstructure:"webhook_secret"`
+	// WebhookRules route webhook events to Slack channels.
+	WebhookRules []JiraWebhookRule `mapstructure:"webhook_rules"`
+}
+
+// JiraWebhookRule sends matching Jira webhook events to a Slack channel. Empty lists match anything.
+type JiraWebhookRule struct {
+	Channel string `mapstructure:"channel"`
+	// Events are "issue_created", "issue_updated" and/or "comment_created".
+	Events     []string `mapstructure:"events"`
+	Projects   []string `mapstructure:"projects"`
+	IssueTypes []string `mapstructure:"issue_types"`
+	Priorities []string `mapstructure:"priorities"`
+	// Filter is a JQL-like expression, e.g. "status != Done AND labels in (incident)".
+	Filter string `mapstructure:"filter"`
+}
+
+// JiraChannelConfig selects the Jira issues for one Slack channel.
+type JiraChannelConfig struct {
+	// Projects are Jira project keys, e.g. ["PAY"].
+	Projects []string `mapstructure:"projects"`
+	// JQL is a query template; {{since}} becomes the summary window (e.g. "-7d") and
+	// {{projects}} the project keys. Empty means issues in Projects updated in the window.
+	JQL string `mapstructure:"jql"`
 }
 
 // GeminiConfig stores the configuration for the Gemini service.
 type GeminiConfig struct {
 	APIKey string `mapstructure:"api_key"`
+	// Model is the default model; empty means llm.DefaultGeminiModel.
+	Model string `mapstructure:"model"`
+	// FeatureModels overrides the model per agent feature ("chat", "summary", "consolidate").
+	FeatureModels map[string]string `mapstructure:"feature_models"`
+}
+
+// OpenAIConfig stores the configuration for an OpenAI-compatible
+// chat completions endpoint such as vLLM, llama.cpp server or Ollama.
+type OpenAIConfig struct {
+	// BaseURL is the API root including the version, e.g. "http://localhost:11434/v1".
+	BaseURL string `mapstructure:"base_url"`
+	APIKey  string `mapstructure:"api_key"`
+	Model   string `mapstructure:"model"`
+	// TimeoutSeconds bounds a single completion request; 0 means 120 seconds.
+	TimeoutSeconds int `mapstructure:"timeout_seconds"`
+	// FeatureModels overrides the model per agent feature ("chat", "summary", "consolidate").
+	FeatureModels map[string]string `mapstructure:"feature_models"`
 }
 
 // LoadConfig reads configuration from file or environment variables.
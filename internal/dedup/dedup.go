@@ -0,0 +1,102 @@
+// Package dedup remembers which Slack deliveries have already been handled, so
+// retried events are processed only once.
+package dedup
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/gemini/go-service-communicator/internal/storage"
+)
+
+// DefaultTTL is how long a key is remembered when no TTL is given. Slack stops
+// retrying an event well within this window.
+const DefaultTTL = time.Hour
+
+// Store records processed keys. Implementations shared by several replicas let
+// only one of them handle a given event.
+type Store interface {
+	// Claim records key for ttl and reports whether this caller is the first to
+	// claim it. A false result means the key was claimed earlier and is still live.
+	Claim(ctx context.Context, key string, ttl time.Duration) (bool, error)
+}
+
+// MemoryStore is an in-process Store. It is the default and is only suitable
+// for a single replica.
+type MemoryStore struct {
+	mu        sync.Mutex
+	expiries  map[string]time.Time
+	lastSweep time.Time
+}
+
+// NewMemoryStore creates an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{expiries: make(map[string]time.Time)}
+}
+
+// Claim implements Store.
+func (s *MemoryStore) Claim(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Drop expired keys at most once a minute so the map doesn't grow forever.
+	if now.Sub(s.lastSweep) > time.Minute {
+		for k, expiry := range s.expiries {
+			if now.After(expiry) {
+				delete(s.expiries, k)
+			}
+		}
+		s.lastSweep = now
+	}
+
+	if expiry, ok := s.expiries[key]; ok && now.Before(expiry) {
+		return false, nil
+	}
+	s.expiries[key] = now.Add(ttl)
+	return true, nil
+}
+
+// claimBucket is the storage bucket that holds claimed keys.
+const claimBucket = "dedup"
+
+// errClaimed aborts the update of a key that is already claimed.
+var errClaimed = errors.New("already claimed")
+
+// PersistentStore is a Store kept in a storage.Store, so claims survive restarts
+// and are shared by every process using the same storage.
+type PersistentStore struct {
+	store storage.Store
+}
+
+// NewPersistentStore creates a PersistentStore on top of store.
+func NewPersistentStore(store storage.Store) *PersistentStore {
+	return &PersistentStore{store: store}
+}
+
+// Claim implements Store.
+func (s *PersistentStore) Claim(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	err := s.store.Update(ctx, claimBucket, key, ttl, func(old []byte) ([]byte, error) {
+		if old != nil {
+			// Leave the existing claim and its expiry untouched.
+			return nil, errClaimed
+		}
+		return []byte{1}, nil
+	})
+	if errors.Is(err, errClaimed) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,38 @@
+package dedup
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gemini/go-service-communicator/internal/storage"
+)
+
+func TestClaim(t *testing.T) {
+	stores := map[string]Store{
+		"memory":     NewMemoryStore(),
+		"persistent": NewPersistentStore(storage.NewMemoryStore()),
+	}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			if ok, err := store.Claim(ctx, "Ev1", 50*time.Millisecond); err != nil || !ok {
+				t.Fatalf("first claim = %v, %v; want true", ok, err)
+			}
+			if ok, err := store.Claim(ctx, "Ev1", 50*time.Millisecond); err != nil || ok {
+				t.Fatalf("second claim = %v, %v; want false", ok, err)
+			}
+			if ok, _ := store.Claim(ctx, "Ev2", 50*time.Millisecond); !ok {
+				t.Error("claiming another key failed")
+			}
+
+			// A repeated claim must not extend the first one.
+			time.Sleep(30 * time.Millisecond)
+			store.Claim(ctx, "Ev1", 50*time.Millisecond)
+			time.Sleep(30 * time.Millisecond)
+			if ok, err := store.Claim(ctx, "Ev1", 50*time.Millisecond); err != nil || !ok {
+				t.Errorf("claim after expiry = %v, %v; want true", ok, err)
+			}
+		})
+	}
+}
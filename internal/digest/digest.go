@@ -0,0 +1,397 @@
+// Package digest keeps users' subscriptions to scheduled channel summaries and
+// delivers each one when its schedule comes due.
+package digest
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	// Embedded so user time zones resolve in images without tzdata.
+	_ "time/tzdata"
+
+	"github.com/gemini/go-service-communicator/internal/queue"
+	"github.com/gemini/go-service-communicator/internal/storage"
+	"github.com/robfig/cron/v3"
+)
+
+const (
+	// subscriptionBucket holds subscriptions keyed by user and subscription ID.
+	subscriptionBucket = "digest_subscriptions"
+	// defaultInterval is how often the scheduler looks for due subscriptions.
+	defaultInterval = time.Minute
+	// maxLookback caps how far back a digest reaches, e.g. after a long outage.
+	maxLookback = 31 * 24 * time.Hour
+	// MinInterval is the shortest time allowed between two digests of a subscription.
+	MinInterval = time.Hour
+	// intervalHorizon is how far ahead a schedule's delivery times are checked
+	// against MinInterval; it covers every weekday.
+	intervalHorizon = 8 * 24 * time.Hour
+	// jobWorkspace is the workspace key of digest jobs, so that the pool's
+	// per-workspace limit keeps a burst of due digests from taking every worker.
+	jobWorkspace = "digests"
+)
+
+// ErrTooFrequent is returned by Subscribe for schedules that deliver digests
+// less than MinInterval apart.
+var ErrTooFrequent = errors.New("digests can be sent at most once an hour")
+
+// Subscription is a user's request for a recurring summary of a channel.
+type Subscription struct {
+	ID     string
+	UserID string
+	// ChannelID is the channel that is summarized.
+	ChannelID string
+	// Target is where the digest is posted: ChannelID itself, or UserID for a DM.
+	Target string
+	// Schedule is a five-field cron expression evaluated in Timezone.
+	Schedule string
+	// Timezone is an IANA time zone such as "Europe/Berlin". Empty means UTC.
+	Timezone string
+	// Description is the schedule as the user wrote it, e.g. "daily 9am".
+	Description string
+	Created     time.Time
+	// LastWindow is the scheduled time of the last delivery. A window is claimed
+	// before its digest is delivered, so it is never delivered twice.
+	LastWindow time.Time
+}
+
+// ToDM reports whether the digest is sent to the subscriber in a DM.
+func (s Subscription) ToDM() bool {
+	return s.Target == s.UserID
+}
+
+// DeliverFunc summarizes sub.ChannelID between start and end and posts the
+// result to sub.Target.
+type DeliverFunc func(ctx context.Context, sub Subscription, start, end time.Time) error
+
+// Scheduler stores subscriptions and delivers them on schedule. Subscriptions
+// and the windows already delivered are kept in a storage.Store, so nothing is
+// lost or repeated across restarts when the store is persistent.
+type Scheduler struct {
+	store    storage.Store
+	deliver  DeliverFunc
+	interval time.Duration
+	jobs     *queue.Pool
+
+	mu sync.Mutex
+	// queued holds the IDs of subscriptions with a delivery job in the pool.
+	queued map[string]bool
+}
+
+// New creates a Scheduler that keeps subscriptions in store and hands due ones to deliver.
+func New(store storage.Store, deliver DeliverFunc) *Scheduler {
+	return &Scheduler{store: store, deliver: deliver, interval: defaultInterval, queued: make(map[string]bool)}
+}
+
+// SetJobs makes the scheduler deliver digests as jobs on pool, under the
+// subscriber's per-user limit. Without a pool, digests are delivered one at a
+// time on the scheduler's goroutine.
+func (s *Scheduler) SetJobs(pool *queue.Pool) {
+	s.jobs = pool
+}
+
+// SetInterval sets how often the scheduler looks for due subscriptions. A
+// non-positive interval keeps the default of one minute.
+func (s *Scheduler) SetInterval(interval time.Duration) {
+	if interval > 0 {
+		s.interval = interval
+	}
+}
+
+func subscriptionKey(userID, id string) string {
+	return userID + "/" + id
+}
+
+// parse returns the cron schedule of sub in its time zone.
+func parse(sub Subscription) (cron.Schedule, error) {
+	tz := sub.Timezone
+	if tz == "" {
+		tz = "UTC"
+	}
+	schedule, err := cron.ParseStandard("CRON_TZ=" + tz + " " + sub.Schedule)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schedule %q in time zone %s: %w", sub.Schedule, tz, err)
+	}
+	return schedule, nil
+}
+
+// Subscribe validates and stores sub, filling in its ID and creation time. The
+// first digest is delivered at the next scheduled time.
+func (s *Scheduler) Subscribe(ctx context.Context, sub Subscription) (Subscription, error) {
+	schedule, err := parse(sub)
+	if err != nil {
+		return Subscription{}, err
+	}
+	if tooFrequent(schedule, time.Now()) {
+		return Subscription{}, ErrTooFrequent
+	}
+	buf := make([]byte, 4)
+	rand.Read(buf)
+	sub.ID = hex.EncodeToString(buf)
+	sub.Created = time.Now()
+	sub.LastWindow = time.Time{}
+
+	data, err := json.Marshal(sub)
+	if err != nil {
+		return Subscription{}, err
+	}
+	if err := s.store.Put(ctx, subscriptionBucket, subscriptionKey(sub.UserID, sub.ID), data, 0); err != nil {
+		return Subscription{}, err
+	}
+	log.Printf("User %s subscribed to a digest of channel %s (%s, %s)", sub.UserID, sub.ChannelID, sub.Schedule, sub.Timezone)
+	return sub, nil
+}
+
+// Unsubscribe removes the user's subscription with the given ID and reports
+// whether it existed.
+func (s *Scheduler) Unsubscribe(ctx context.Context, userID, id string) (bool, error) {
+	existed := false
+	err := s.store.Update(ctx, subscriptionBucket, subscriptionKey(userID, id), 0, func(old []byte) ([]byte, error) {
+		existed = old != nil
+		return nil, nil
+	})
+	if err != nil {
+		return false, err
+	}
+	if existed {
+		log.Printf("User %s unsubscribed from digest %s", userID, id)
+	}
+	return existed, nil
+}
+
+// Subscriptions returns the user's subscriptions, oldest first.
+func (s *Scheduler) Subscriptions(ctx context.Context, userID string) ([]Subscription, error) {
+	subs, err := s.all(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var mine []Subscription
+	for _, sub := range subs {
+		if sub.UserID == userID {
+			mine = append(mine, sub)
+		}
+	}
+	return mine, nil
+}
+
+// Next returns the next time sub is delivered after now, or the zero time if
+// its schedule is invalid.
+func (s *Scheduler) Next(sub Subscription, now time.Time) time.Time {
+	schedule, err := parse(sub)
+	if err != nil {
+		return time.Time{}
+	}
+	return schedule.Next(now)
+}
+
+// Run delivers due subscriptions every interval until ctx is cancelled.
+func (s *Scheduler) Run(ctx context.Context) {
+	log.Printf("Digest scheduler started; checking every %s", s.interval)
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		s.RunDue(ctx, time.Now())
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunDue delivers every subscription with a scheduled time at or before now
+// that hasn't been delivered yet. If several windows were missed, e.g. while the
+// service was down, only the latest is delivered.
+func (s *Scheduler) RunDue(ctx context.Context, now time.Time) {
+	subs, err := s.all(ctx)
+	if err != nil {
+		log.Printf("Error loading digest subscriptions: %v", err)
+		return
+	}
+	for _, sub := range subs {
+		if !due(sub, now) {
+			continue
+		}
+		if s.jobs == nil {
+			s.deliverDue(ctx, sub, now)
+			continue
+		}
+
+		s.mu.Lock()
+		if s.queued[sub.ID] {
+			s.mu.Unlock()
+			continue // Still waiting in the pool from an earlier check.
+		}
+		s.queued[sub.ID] = true
+		s.mu.Unlock()
+
+		sub := sub
+		_, err := s.jobs.Submit(queue.Job{
+			Name:        "digest",
+			UserID:      sub.UserID,
+			WorkspaceID: jobWorkspace,
+			Run: func(ctx context.Context) {
+				defer s.dequeue(sub.ID)
+				s.deliverDue(ctx, sub, now)
+			},
+		})
+		if err != nil {
+			// Nothing was claimed, so the next check tries again.
+			log.Printf("Could not queue digest %s: %v", sub.ID, err)
+			s.dequeue(sub.ID)
+		}
+	}
+}
+
+func (s *Scheduler) dequeue(id string) {
+	s.mu.Lock()
+	delete(s.queued, id)
+	s.mu.Unlock()
+}
+
+// deliverDue claims the latest due window of sub and delivers its digest.
+func (s *Scheduler) deliverDue(ctx context.Context, sub Subscription, now time.Time) {
+	claimed, start, window, err := s.claim(ctx, sub, now)
+	if err != nil {
+		log.Printf("Error claiming digest %s: %v", sub.ID, err)
+		return
+	}
+	if claimed == nil {
+		return
+	}
+	log.Printf("Delivering digest %s of channel %s to %s for %s", claimed.ID, claimed.ChannelID, claimed.Target, window.Format(time.RFC3339))
+	if err := s.deliver(ctx, *claimed, start, window); err != nil {
+		log.Printf("Error delivering digest %s: %v", claimed.ID, err)
+	}
+}
+
+// due reports whether sub has a scheduled time after its last delivery and at
+// or before now. claim makes the same check atomically.
+func due(sub Subscription, now time.Time) bool {
+	schedule, err := parse(sub)
+	if err != nil {
+		log.Printf("Skipping digest %s: %v", sub.ID, err)
+		return false
+	}
+	from := sub.LastWindow
+	if from.IsZero() {
+		from = sub.Created
+	}
+	return !latestWindow(schedule, from, now).IsZero()
+}
+
+// claim atomically records the latest due window of sub as delivered. It returns
+// nil if no window is due or another run claimed it first, and otherwise the
+// claimed subscription and the period its digest covers.
+func (s *Scheduler) claim(ctx context.Context, sub Subscription, now time.Time) (*Subscription, time.Time, time.Time, error) {
+	var claimed *Subscription
+	var start, window time.Time
+	err := s.store.Update(ctx, subscriptionBucket, subscriptionKey(sub.UserID, sub.ID), 0, func(old []byte) ([]byte, error) {
+		claimed = nil
+		if old == nil {
+			return nil, nil // Unsubscribed in the meantime.
+		}
+		var current Subscription
+		if err := json.Unmarshal(old, &current); err != nil {
+			return nil, err
+		}
+		schedule, err := parse(current)
+		if err != nil {
+			return nil, err
+		}
+
+		from := current.LastWindow
+		if from.IsZero() {
+			from = current.Created
+		}
+		window = latestWindow(schedule, from, now)
+		if window.IsZero() {
+			return old, nil
+		}
+
+		// A digest covers the time since the previous one; the first digest
+		// covers one schedule period.
+		start = current.LastWindow
+		if start.IsZero() {
+			start = window.Add(-schedule.Next(window).Sub(window))
+		}
+		if window.Sub(start) > maxLookback {
+			start = window.Add(-maxLookback)
+		}
+
+		current.LastWindow = window
+		claimed = &current
+		return json.Marshal(current)
+	})
+	if err != nil {
+		return nil, time.Time{}, time.Time{}, err
+	}
+	return claimed, start, window, nil
+}
+
+// tooFrequent reports whether two deliveries of schedule within intervalHorizon
+// after now are less than MinInterval apart.
+func tooFrequent(schedule cron.Schedule, now time.Time) bool {
+	prev := schedule.Next(now)
+	for !prev.IsZero() && prev.Sub(now) < intervalHorizon {
+		next := schedule.Next(prev)
+		if !next.IsZero() && next.Sub(prev) < MinInterval {
+			return true
+		}
+		prev = next
+	}
+	return false
+}
+
+// latestWindow returns the last scheduled time after from and at or before now,
+// or the zero time if there is none.
+func latestWindow(schedule cron.Schedule, from, now time.Time) time.Time {
+	var window time.Time
+	for next := schedule.Next(from); !next.IsZero() && !next.After(now); next = schedule.Next(next) {
+		window = next
+	}
+	return window
+}
+
+// all returns every stored subscription, oldest first.
+func (s *Scheduler) all(ctx context.Context) ([]Subscription, error) {
+	entries, err := s.store.List(ctx, subscriptionBucket)
+	if err != nil {
+		return nil, err
+	}
+	subs := make([]Subscription, 0, len(entries))
+	for key, data := range entries {
+		var sub Subscription
+		if err := json.Unmarshal(data, &sub); err != nil {
+			log.Printf("Skipping unreadable digest subscription %s: %v", key, err)
+			continue
+		}
+		subs = append(subs, sub)
+	}
+	sort.Slice(subs, func(i, j int) bool { return subs[i].Created.Before(subs[j].Created) })
+	return subs, nil
+}
+
+// Describe renders sub for the user, e.g. "daily 9am (Europe/Berlin) summary of <#C123> to your DMs".
+func Describe(sub Subscription) string {
+	var b strings.Builder
+	b.WriteString(sub.Description)
+	if sub.Timezone != "" {
+		b.WriteString(" (" + sub.Timezone + ")")
+	}
+	b.WriteString(" summary of <#" + sub.ChannelID + ">")
+	if sub.ToDM() {
+		b.WriteString(" to your DMs")
+	} else {
+		b.WriteString(" in the channel")
+	}
+	return b.String()
+}
@@ -0,0 +1,291 @@
+package digest
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gemini/go-service-communicator/internal/queue"
+	"github.com/gemini/go-service-communicator/internal/storage"
+	"github.com/robfig/cron/v3"
+)
+
+func date(day, hour, minute int) time.Time {
+	return time.Date(2024, time.May, day, hour, minute, 0, 0, time.UTC)
+}
+
+// delivery records one call of a DeliverFunc.
+type delivery struct {
+	id         string
+	start, end time.Time
+}
+
+// recorder is a DeliverFunc that records its calls.
+type recorder struct {
+	mu         sync.Mutex
+	deliveries []delivery
+	done       chan struct{}
+}
+
+func newRecorder() *recorder {
+	return &recorder{done: make(chan struct{}, 10)}
+}
+
+func (r *recorder) deliver(ctx context.Context, sub Subscription, start, end time.Time) error {
+	r.mu.Lock()
+	r.deliveries = append(r.deliveries, delivery{sub.ID, start, end})
+	r.mu.Unlock()
+	r.done <- struct{}{}
+	return nil
+}
+
+func (r *recorder) list() []delivery {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]delivery(nil), r.deliveries...)
+}
+
+func (r *recorder) wait(t *testing.T) {
+	t.Helper()
+	select {
+	case <-r.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for a delivery")
+	}
+}
+
+func putSubscription(t *testing.T, store storage.Store, sub Subscription) {
+	t.Helper()
+	data, err := json.Marshal(sub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Put(context.Background(), subscriptionBucket, subscriptionKey(sub.UserID, sub.ID), data, 0); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTooFrequent(t *testing.T) {
+	tests := []struct {
+		spec string
+		want bool
+	}{
+		{"0 * * * *", false},
+		{"0 9 * * *", false},
+		{"0 9 * * 1-5", false},
+		{"*/30 * * * *", true},
+		{"0,30 9 * * *", true},
+		{"0,59 9 * * 1", true},
+		{"0 9,10 * * *", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			schedule, err := cron.ParseStandard(tt.spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := tooFrequent(schedule, date(1, 12, 0)); got != tt.want {
+				t.Errorf("tooFrequent = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLatestWindow(t *testing.T) {
+	schedule, err := cron.ParseStandard("0 9 * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		from time.Time
+		now  time.Time
+		want time.Time
+	}{
+		{"none yet", date(1, 10, 0), date(1, 20, 0), time.Time{}},
+		{"exactly on time", date(1, 10, 0), date(2, 9, 0), date(2, 9, 0)},
+		{"late", date(1, 10, 0), date(2, 9, 30), date(2, 9, 0)},
+		{"several missed", date(1, 10, 0), date(4, 12, 0), date(4, 9, 0)},
+		{"from is exclusive", date(2, 9, 0), date(2, 9, 30), time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := latestWindow(schedule, tt.from, tt.now); !got.Equal(tt.want) {
+				t.Errorf("latestWindow = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunDueWindows(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		sub  Subscription
+		now  time.Time
+		want *delivery
+	}{
+		{
+			name: "first digest covers one period",
+			sub:  Subscription{Schedule: "0 9 * * *", Created: date(1, 8, 0)},
+			now:  date(1, 9, 5),
+			want: &delivery{start: date(1, 9, 0).AddDate(0, 0, -1), end: date(1, 9, 0)},
+		},
+		{
+			name: "not due before the first window",
+			sub:  Subscription{Schedule: "0 9 * * *", Created: date(1, 10, 0)},
+			now:  date(1, 20, 0),
+		},
+		{
+			name: "not due again after a delivery",
+			sub:  Subscription{Schedule: "0 9 * * *", Created: date(1, 8, 0), LastWindow: date(2, 9, 0)},
+			now:  date(2, 20, 0),
+		},
+		{
+			name: "catch-up after an outage covers the missed windows once",
+			sub:  Subscription{Schedule: "0 9 * * *", Created: date(1, 8, 0), LastWindow: date(1, 9, 0)},
+			now:  date(4, 10, 0),
+			want: &delivery{start: date(1, 9, 0), end: date(4, 9, 0)},
+		},
+		{
+			name: "weekly catch-up",
+			sub:  Subscription{Schedule: "0 16 * * 5", Created: date(1, 8, 0), LastWindow: date(3, 16, 0)},
+			now:  date(24, 16, 1),
+			want: &delivery{start: date(3, 16, 0), end: date(24, 16, 0)},
+		},
+		{
+			name: "long outage is capped",
+			sub:  Subscription{Schedule: "0 9 * * *", Created: date(1, 8, 0), LastWindow: date(1, 9, 0).AddDate(0, -3, 0)},
+			now:  date(4, 10, 0),
+			want: &delivery{start: date(4, 9, 0).Add(-maxLookback), end: date(4, 9, 0)},
+		},
+		{
+			name: "time zone",
+			sub:  Subscription{Schedule: "0 9 * * *", Timezone: "Europe/Berlin", Created: date(1, 0, 0)},
+			now:  date(1, 7, 30),
+			want: &delivery{start: time.Date(2024, time.April, 30, 9, 0, 0, 0, berlin), end: time.Date(2024, time.May, 1, 9, 0, 0, 0, berlin)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := storage.NewMemoryStore()
+			rec := newRecorder()
+			s := New(store, rec.deliver)
+			tt.sub.ID, tt.sub.UserID, tt.sub.ChannelID, tt.sub.Target = "d1", "U1", "C1", "C1"
+			putSubscription(t, store, tt.sub)
+
+			s.RunDue(context.Background(), tt.now)
+			s.RunDue(context.Background(), tt.now.Add(time.Minute))
+
+			got := rec.list()
+			if tt.want == nil {
+				if len(got) != 0 {
+					t.Fatalf("deliveries = %+v, want none", got)
+				}
+				return
+			}
+			if len(got) != 1 {
+				t.Fatalf("deliveries = %+v, want exactly one", got)
+			}
+			if !got[0].start.Equal(tt.want.start) || !got[0].end.Equal(tt.want.end) {
+				t.Errorf("window = %v to %v, want %v to %v", got[0].start, got[0].end, tt.want.start, tt.want.end)
+			}
+
+			subs, err := s.Subscriptions(context.Background(), "U1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(subs) != 1 || !subs[0].LastWindow.Equal(tt.want.end) {
+				t.Errorf("stored subscriptions = %+v, want LastWindow %v", subs, tt.want.end)
+			}
+		})
+	}
+}
+
+func TestRunDueSubmitsJobs(t *testing.T) {
+	store := storage.NewMemoryStore()
+	rec := newRecorder()
+	s := New(store, rec.deliver)
+	pool := queue.New(context.Background(), queue.Config{Workers: 2})
+	t.Cleanup(pool.Close)
+	s.SetJobs(pool)
+	for _, user := range []string{"U1", "U2"} {
+		putSubscription(t, store, Subscription{ID: "d-" + user, UserID: user, ChannelID: "C1", Target: user, Schedule: "0 9 * * *", Created: date(1, 8, 0)})
+	}
+
+	// U1 is busy, so their digest has to wait for the per-user limit.
+	release := make(chan struct{})
+	if _, err := pool.Submit(queue.Job{Name: "summary", UserID: "U1", Run: func(ctx context.Context) { <-release }}); err != nil {
+		t.Fatal(err)
+	}
+
+	s.RunDue(context.Background(), date(1, 9, 5))
+	rec.wait(t)
+	if got := rec.list(); len(got) != 1 || got[0].id != "d-U2" {
+		t.Fatalf("deliveries = %+v, want only U2's digest", got)
+	}
+
+	// A digest still waiting in the pool isn't queued twice.
+	s.RunDue(context.Background(), date(1, 9, 6))
+	if stats := pool.Stats(); stats.Queued != 1 {
+		t.Errorf("queued jobs = %d, want 1", stats.Queued)
+	}
+
+	close(release)
+	rec.wait(t)
+	if got := rec.list(); len(got) != 2 || got[1].id != "d-U1" {
+		t.Fatalf("deliveries = %+v, want U1's digest after U2's", got)
+	}
+	s.RunDue(context.Background(), date(1, 9, 7))
+	time.Sleep(50 * time.Millisecond)
+	if got := rec.list(); len(got) != 2 {
+		t.Errorf("deliveries = %+v, want no repeats", got)
+	}
+}
+
+func TestRunDueRetriesWhenQueueIsFull(t *testing.T) {
+	store := storage.NewMemoryStore()
+	rec := newRecorder()
+	s := New(store, rec.deliver)
+	pool := queue.New(context.Background(), queue.Config{Workers: 1, QueueSize: 1})
+	t.Cleanup(pool.Close)
+	s.SetJobs(pool)
+	putSubscription(t, store, Subscription{ID: "d1", UserID: "U1", ChannelID: "C1", Target: "U1", Schedule: "0 9 * * *", Created: date(1, 8, 0)})
+
+	// One job takes the only worker and another the only queue slot.
+	release := make(chan struct{})
+	started := make(chan struct{})
+	pool.Submit(queue.Job{Name: "summary", UserID: "U2", Run: func(ctx context.Context) { close(started); <-release }})
+	<-started
+	if _, err := pool.Submit(queue.Job{Name: "summary", UserID: "U3", Run: func(ctx context.Context) {}}); err != nil {
+		t.Fatal(err)
+	}
+
+	s.RunDue(context.Background(), date(1, 9, 5))
+	subs, err := s.Subscriptions(context.Background(), "U1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(subs) != 1 || !subs[0].LastWindow.IsZero() {
+		t.Fatalf("stored subscriptions = %+v, want the window left unclaimed", subs)
+	}
+
+	close(release)
+	deadline := time.Now().Add(5 * time.Second)
+	for pool.Stats().Queued > 0 || pool.Stats().Running > 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the pool to drain")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	s.RunDue(context.Background(), date(1, 9, 6))
+	rec.wait(t)
+	if got := rec.list(); len(got) != 1 || !got[0].end.Equal(date(1, 9, 0)) {
+		t.Errorf("deliveries = %+v, want the 9:00 digest", got)
+	}
+}
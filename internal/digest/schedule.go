@@ -0,0 +1,94 @@
+package digest
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// weekdays maps day names to cron day-of-week numbers.
+var weekdays = map[string]string{
+	"sunday": "0", "monday": "1", "tuesday": "2", "wednesday": "3",
+	"thursday": "4", "friday": "5", "saturday": "6",
+	"sun": "0", "mon": "1", "tue": "2", "wed": "3", "thu": "4", "fri": "5", "sat": "6",
+}
+
+var (
+	// timeRegex matches times of day such as "9am", "9:30 pm" or "17:00".
+	timeRegex = regexp.MustCompile(`^(\d{1,2})(?::(\d{2}))?\s*(am|pm)?$`)
+	// cronRegex matches a five-field cron expression.
+	cronRegex = regexp.MustCompile(`^[\d*/,\-]+( [\d*/,\-]+){4}$`)
+	// meridiemRegex joins "9 am" into "9am" before the text is split into words.
+	meridiemRegex = regexp.MustCompile(`(\d)\s+(am|pm)\b`)
+)
+
+// ParseSchedule turns a schedule written by a user into a five-field cron
+// expression. It accepts "hourly" (on the hour), as well as "daily",
+// "weekdays", "weekly" and day names such as "friday", each optionally
+// followed by a time of day ("9am", "17:30"), and plain cron expressions such
+// as "0 9 * * 1-5". Days default to Monday and times to 9am.
+func ParseSchedule(text string) (string, error) {
+	text = strings.ToLower(strings.TrimSpace(text))
+	if cronRegex.MatchString(text) {
+		return text, nil
+	}
+
+	minute, hour := "0", "9"
+	frequency, day := "", ""
+	timeSet := false
+	for _, word := range strings.Fields(meridiemRegex.ReplaceAllString(text, "$1$2")) {
+		switch word {
+		case "every", "at", "on":
+			continue
+		case "hourly", "daily", "weekly", "weekdays":
+			if frequency != "" {
+				return "", fmt.Errorf("%q repeats the frequency", text)
+			}
+			frequency = word
+			continue
+		}
+		if d, ok := weekdays[strings.TrimSuffix(word, "s")]; ok && day == "" {
+			day = d
+			continue
+		}
+		if m := timeRegex.FindStringSubmatch(word); m != nil && !timeSet {
+			h, _ := strconv.Atoi(m[1])
+			switch {
+			case m[3] == "" && h > 23, m[3] != "" && (h < 1 || h > 12):
+				return "", fmt.Errorf("%q is not a valid time", word)
+			case m[3] == "am" && h == 12:
+				h = 0
+			case m[3] == "pm" && h != 12:
+				h += 12
+			}
+			mins := 0
+			if m[2] != "" {
+				if mins, _ = strconv.Atoi(m[2]); mins > 59 {
+					return "", fmt.Errorf("%q is not a valid time", word)
+				}
+			}
+			hour, minute = strconv.Itoa(h), strconv.Itoa(mins)
+			timeSet = true
+			continue
+		}
+		return "", fmt.Errorf("I don't understand %q in the schedule", word)
+	}
+
+	switch {
+	case frequency == "hourly" && timeSet:
+		return "", fmt.Errorf("%q sets a time, but hourly digests are sent at the start of every hour", text)
+	case frequency == "hourly" && day == "":
+		return "0 * * * *", nil
+	case frequency == "daily" && day == "":
+		return fmt.Sprintf("%s %s * * *", minute, hour), nil
+	case frequency == "weekdays" && day == "":
+		return fmt.Sprintf("%s %s * * 1-5", minute, hour), nil
+	case frequency == "weekly" || (frequency == "" && day != ""):
+		if day == "" {
+			day = "1"
+		}
+		return fmt.Sprintf("%s %s * * %s", minute, hour, day), nil
+	}
+	return "", fmt.Errorf("%q is not a schedule; try something like \"daily 9am\" or \"weekly friday 4pm\"", text)
+}
@@ -0,0 +1,68 @@
+package digest
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseSchedule(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"hourly", "0 * * * *"},
+		{"daily", "0 9 * * *"},
+		{"daily 9am", "0 9 * * *"},
+		{"Daily at 5:30 PM", "30 17 * * *"},
+		{"daily 12am", "0 0 * * *"},
+		{"daily 12pm", "0 12 * * *"},
+		{"daily 17:45", "45 17 * * *"},
+		{"weekdays 8am", "0 8 * * 1-5"},
+		{"weekly", "0 9 * * 1"},
+		{"weekly friday 4pm", "0 16 * * 5"},
+		{"every friday at 4 pm", "0 16 * * 5"},
+		{"fridays", "0 9 * * 5"},
+		{"sun 10am", "0 10 * * 0"},
+		{"0 9 * * 1-5", "0 9 * * 1-5"},
+		{"*/15 * * * *", "*/15 * * * *"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			got, err := ParseSchedule(tt.text)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseSchedule(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseScheduleErrors(t *testing.T) {
+	tests := []struct {
+		text    string
+		wantErr string
+	}{
+		{"hourly 9am", "hourly digests are sent at the start of every hour"},
+		{"hourly at 17:30", "hourly digests are sent at the start of every hour"},
+		{"hourly friday", "is not a schedule"},
+		{"daily weekly", "repeats the frequency"},
+		{"daily friday", "is not a schedule"},
+		{"daily 25:00", "not a valid time"},
+		{"daily 13pm", "not a valid time"},
+		{"daily 9:75", "not a valid time"},
+		{"daily 9am 10am", "don't understand"},
+		{"fortnightly", "don't understand"},
+		{"", "is not a schedule"},
+		{"9am", "is not a schedule"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			got, err := ParseSchedule(tt.text)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ParseSchedule(%q) = %q, %v; want an error containing %q", tt.text, got, err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,226 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gemini/go-service-communicator/internal/digest"
+	slackclient "github.com/gemini/go-service-communicator/internal/services/slack"
+)
+
+var (
+	// digestChannelRegex matches a channel in a subscribe command, either escaped
+	// by Slack (<#C0123|payments>) or written by hand (#payments).
+	digestChannelRegex = regexp.MustCompile(`<#([A-Z0-9]+)(?:\|[^>]*)?>|#([\w-]+)`)
+	// digestDMRegex matches the phrases that send a digest to the user's DMs.
+	digestDMRegex = regexp.MustCompile(`(?i)\b(?:to|in) (?:my )?(?:dms?|direct messages?)\b|\bto me\b`)
+	// digestChannelTargetRegex matches the phrases that post a digest in the channel, the default.
+	digestChannelTargetRegex = regexp.MustCompile(`(?i)\b(?:to|in) (?:the )?channel\b`)
+)
+
+// digestUsage explains the subscription subcommands.
+const digestUsage = "Try `/summary subscribe daily 9am #payments`, `/summary subscribe weekly friday 4pm to my DM`, `/summary subscriptions` or `/summary unsubscribe <id>`."
+
+// processDigestCommand handles the "subscribe", "unsubscribe" and "subscriptions"
+// subcommands of /summary. It reports whether commandText was one of them.
+func (h *SlashCommandHandler) processDigestCommand(ctx context.Context, userID, requestChannelID, commandText string) bool {
+	fields := strings.Fields(commandText)
+	if len(fields) == 0 {
+		return false
+	}
+	subcommand := strings.ToLower(fields[0])
+	if subcommand != "subscribe" && subcommand != "unsubscribe" && subcommand != "subscriptions" {
+		return false
+	}
+	if h.digests == nil {
+		h.slackClient.SendEphemeralMessage(requestChannelID, userID, "Scheduled summaries are not enabled.")
+		return true
+	}
+
+	rest := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(commandText), fields[0]))
+	var reply string
+	switch subcommand {
+	case "subscribe":
+		reply = h.subscribe(ctx, userID, requestChannelID, rest)
+	case "unsubscribe":
+		reply = h.unsubscribe(ctx, userID, rest)
+	case "subscriptions":
+		reply = h.listSubscriptions(ctx, userID)
+	}
+	h.slackClient.SendEphemeralMessage(requestChannelID, userID, reply)
+	return true
+}
+
+// subscribe parses a subscription like "daily 9am #payments to my DM" and stores it.
+func (h *SlashCommandHandler) subscribe(ctx context.Context, userID, requestChannelID, text string) string {
+	sub := digest.Subscription{UserID: userID, ChannelID: requestChannelID}
+
+	if digestDMRegex.MatchString(text) {
+		sub.Target = userID
+		text = digestDMRegex.ReplaceAllString(text, "")
+	}
+	text = digestChannelTargetRegex.ReplaceAllString(text, "")
+
+	if match := digestChannelRegex.FindStringSubmatch(text); match != nil {
+		text = strings.Replace(text, match[0], "", 1)
+		if match[1] != "" {
+			sub.ChannelID = match[1]
+		} else {
+			channelID, err := h.findChannel(ctx, userID, match[2])
+			if err != nil {
+				log.Printf("Error looking up channel #%s: %v", match[2], err)
+				return "Sorry, I couldn't look up that channel. Please try again."
+			}
+			if channelID == "" {
+				return fmt.Sprintf("I couldn't find #%s among the channels I'm in. Invite me with `/invite @<bot-name>` and try again.", match[2])
+			}
+			sub.ChannelID = channelID
+		}
+	}
+	if strings.HasPrefix(sub.ChannelID, "D") {
+		return "Name the channel to summarize, e.g. `/summary subscribe daily 9am #payments to my DM`."
+	}
+	if sub.Target == "" {
+		sub.Target = sub.ChannelID
+	}
+
+	// A digest must only show a private conversation to its members.
+	if ok, err := h.slackClient.CanRead(ctx, userID, sub.ChannelID); err != nil || !ok {
+		if err != nil {
+			log.Printf("Could not check whether user %s may read channel %s: %v", userID, sub.ChannelID, err)
+		}
+		return "I can only send summaries of conversations you are a member of."
+	}
+	// Posting in a channel speaks to all of its members, so only they or an admin may set it up.
+	if !sub.ToDM() {
+		if ok, err := h.canPostDigest(ctx, userID, sub.ChannelID); err != nil || !ok {
+			if err != nil {
+				log.Printf("Could not check whether user %s may post digests in channel %s: %v", userID, sub.ChannelID, err)
+			}
+			return "I can only post scheduled summaries in channels you are a member of. Add `to my DM` to get them yourself."
+		}
+	}
+
+	sub.Description = strings.Join(strings.Fields(text), " ")
+	schedule, err := digest.ParseSchedule(sub.Description)
+	if err != nil {
+		return fmt.Sprintf("Sorry, %v. %s", err, digestUsage)
+	}
+	sub.Schedule = schedule
+
+	if tz, err := h.slackClient.GetUserTimezone(ctx, userID); err != nil {
+		log.Printf("Error getting time zone of user %s, using UTC: %v", userID, err)
+	} else {
+		sub.Timezone = tz
+	}
+
+	sub, err = h.digests.Subscribe(ctx, sub)
+	if errors.Is(err, digest.ErrTooFrequent) {
+		return fmt.Sprintf("Sorry, %v. Try `hourly` or a less frequent schedule.", err)
+	}
+	if err != nil {
+		log.Printf("Error storing digest subscription for user %s: %v", userID, err)
+		return "Sorry, I couldn't save your subscription."
+	}
+	return fmt.Sprintf("Subscribed! You'll get a %s. The first one arrives %s. Use `/summary unsubscribe %s` to stop it.",
+		digest.Describe(sub), formatNext(h.digests.Next(sub, time.Now()), sub.Timezone), sub.ID)
+}
+
+// canPostDigest reports whether userID may subscribe channelID to digests posted
+// in the channel: members of the channel and workspace admins may.
+func (h *SlashCommandHandler) canPostDigest(ctx context.Context, userID, channelID string) (bool, error) {
+	if ok, err := h.slackClient.IsMember(ctx, userID, channelID); err != nil || ok {
+		return ok, err
+	}
+	return h.slackClient.IsAdmin(ctx, userID)
+}
+
+// findChannel returns the ID of the channel called name that the bot is in and
+// userID may read, or "" if there is none.
+func (h *SlashCommandHandler) findChannel(ctx context.Context, userID, name string) (string, error) {
+	selection, err := h.slackClient.SelectChannels(ctx, slackclient.ChannelScope{
+		Include: []string{name},
+		Types:   []string{"public_channel", "private_channel"},
+	}, userID)
+	if err != nil {
+		return "", err
+	}
+	if len(selection.Channels) == 0 {
+		return "", nil
+	}
+	return selection.Channels[0], nil
+}
+
+func (h *SlashCommandHandler) unsubscribe(ctx context.Context, userID, id string) string {
+	if id == "" {
+		return "Tell me which subscription to stop, e.g. `/summary unsubscribe 1a2b3c4d`. `/summary subscriptions` lists them."
+	}
+	existed, err := h.digests.Unsubscribe(ctx, userID, id)
+	if err != nil {
+		log.Printf("Error removing digest subscription %s: %v", id, err)
+		return "Sorry, I couldn't remove that subscription."
+	}
+	if !existed {
+		return fmt.Sprintf("You don't have a subscription with ID `%s`.", id)
+	}
+	return "Unsubscribed. You won't get that summary anymore."
+}
+
+func (h *SlashCommandHandler) listSubscriptions(ctx context.Context, userID string) string {
+	subs, err := h.digests.Subscriptions(ctx, userID)
+	if err != nil {
+		log.Printf("Error listing digest subscriptions for user %s: %v", userID, err)
+		return "Sorry, I couldn't load your subscriptions."
+	}
+	if len(subs) == 0 {
+		return "You have no scheduled summaries. " + digestUsage
+	}
+	var b strings.Builder
+	b.WriteString("Your scheduled summaries:\n")
+	now := time.Now()
+	for _, sub := range subs {
+		b.WriteString(fmt.Sprintf("- `%s` %s, next %s\n", sub.ID, digest.Describe(sub), formatNext(h.digests.Next(sub, now), sub.Timezone)))
+	}
+	return b.String()
+}
+
+// formatNext renders a delivery time in the subscriber's time zone.
+func formatNext(next time.Time, tz string) string {
+	if next.IsZero() {
+		return "never"
+	}
+	if loc, err := time.LoadLocation(tz); err == nil {
+		next = next.In(loc)
+	}
+	return next.Format("Mon Jan 2 at 15:04 MST")
+}
+
+// DeliverDigest summarizes sub.ChannelID between start and end and posts the
+// summary to the channel or to the subscriber's DMs. It is the delivery function
+// of the digest scheduler.
+func (h *SlashCommandHandler) DeliverDigest(ctx context.Context, sub digest.Subscription, start, end time.Time) error {
+	// The subscriber may have left a private channel since subscribing.
+	ok, err := h.slackClient.CanRead(ctx, sub.UserID, sub.ChannelID)
+	if err != nil {
+		return fmt.Errorf("could not check access to channel %s: %w", sub.ChannelID, err)
+	}
+	if !ok {
+		return fmt.Errorf("user %s may no longer read channel %s", sub.UserID, sub.ChannelID)
+	}
+
+	summary, rawMessages, err := h.channelSummary(ctx, sub.UserID, sub.ChannelID, start, end)
+	if err != nil {
+		return err
+	}
+	if !sub.ToDM() {
+		return h.slackClient.SendMessage(sub.ChannelID, summary)
+	}
+	// Digests in a DM can be followed up like any other summary.
+	summary = h.agent.StartSession(ctx, sub.UserID, sub.ChannelID, summary, rawMessages)
+	return h.slackClient.SendMessage(sub.UserID, summary)
+}
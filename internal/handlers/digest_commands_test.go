@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/gemini/go-service-communicator/internal/agent"
+	"github.com/gemini/go-service-communicator/internal/digest"
+	"github.com/gemini/go-service-communicator/internal/services/jira"
+	"github.com/gemini/go-service-communicator/internal/storage"
+)
+
+func newDigestTestHandler(t *testing.T) (*SlashCommandHandler, *digest.Scheduler) {
+	t.Helper()
+	client, _ := newFakeWorkspace(t)
+	jiraClient := jira.New(jira.Config{})
+	h := NewSlashCommandHandler(client, jiraClient, agent.New(unavailableLLM{}, client, jiraClient), jira.QueryMapping{}, nil)
+	digests := digest.New(storage.NewMemoryStore(), h.DeliverDigest)
+	h.SetDigests(digests)
+	return h, digests
+}
+
+func TestSubscribe(t *testing.T) {
+	tests := []struct {
+		name   string
+		user   string
+		text   string
+		stored bool
+		reply  string
+	}{
+		{"member posting in the channel", "U2", "daily 9am <#C1|general>", true, "Subscribed!"},
+		{"non-member posting in a public channel", "U4", "daily 9am <#C1|general>", false, "channels you are a member of"},
+		{"admin posting in a public channel", "UADMIN", "daily 9am <#C1|general>", true, "Subscribed!"},
+		{"non-member to their DM", "U4", "daily 9am <#C1|general> to my DM", true, "Subscribed!"},
+		{"non-member of a private channel", "U2", "daily 9am <#G1|secret> to my DM", false, "conversations you are a member of"},
+		{"hourly", "U1", "hourly <#C1|general>", true, "Subscribed!"},
+		{"every 30 minutes", "U1", "*/30 * * * * <#C1|general>", false, "at most once an hour"},
+		{"twice within an hour", "U1", "0,30 9 * * * <#C1|general>", false, "at most once an hour"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, digests := newDigestTestHandler(t)
+			reply := h.subscribe(context.Background(), tt.user, "C1", tt.text)
+			if !strings.Contains(reply, tt.reply) {
+				t.Errorf("reply = %q, want it to contain %q", reply, tt.reply)
+			}
+			subs, err := digests.Subscriptions(context.Background(), tt.user)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stored := len(subs) == 1; stored != tt.stored {
+				t.Errorf("stored = %v, want %v", stored, tt.stored)
+			}
+		})
+	}
+}
@@ -0,0 +1,278 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gemini/go-service-communicator/internal/agent"
+	"github.com/gemini/go-service-communicator/internal/queue"
+	slackclient "github.com/gemini/go-service-communicator/internal/services/slack"
+	"github.com/slack-go/slack"
+)
+
+const (
+	// callbackCreateIssueShortcut is the callback ID of the "Create Jira issue" message shortcut.
+	callbackCreateIssueShortcut = "create_jira_issue"
+	// callbackCreateIssueModal is the callback ID of the modal that confirms a new issue.
+	callbackCreateIssueModal = "create_jira_issue_modal"
+	// maxInitialValue is the longest initial value Slack accepts for a plain text input.
+	maxInitialValue = 3000
+)
+
+// InteractionHandler handles interactive payloads from Slack: shortcuts, button clicks and modal submissions.
+type InteractionHandler struct {
+	slackClient      *slackclient.Client
+	agent            *agent.Processor
+	jobs             *queue.Pool
+	defaultProject   string
+	defaultIssueType string
+}
+
+// NewInteractionHandler creates a new InteractionHandler. defaultProject and
+// defaultIssueType prefill the "create issue" modal. Requests must already be
+// verified by VerifySlackSignature. Drafting, issue creation and confirmed
+// comments run on jobs.
+func NewInteractionHandler(slackClient *slackclient.Client, agent *agent.Processor, jobs *queue.Pool, defaultProject, defaultIssueType string) *InteractionHandler {
+	if defaultIssueType == "" {
+		defaultIssueType = "Task"
+	}
+	return &InteractionHandler{
+		slackClient:      slackClient,
+		agent:            agent,
+		jobs:             jobs,
+		defaultProject:   defaultProject,
+		defaultIssueType: defaultIssueType,
+	}
+}
+
+// issueModalMetadata is carried in the modal's private_metadata.
+type issueModalMetadata struct {
+	ChannelID string `json:"channel_id"`
+	ThreadTS  string `json:"thread_ts"`
+}
+
+// HandleInteraction handles the interactive payload.
+func (h *InteractionHandler) HandleInteraction(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	var callback slack.InteractionCallback
+	if err := json.Unmarshal([]byte(r.FormValue("payload")), &callback); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	response := h.ProcessInteraction(callback)
+	if response != nil {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// ProcessInteraction handles an interactive payload, whether it arrived over HTTP
+// or Socket Mode. It returns the response to acknowledge a modal submission with,
+// or nil for an empty acknowledgement.
+func (h *InteractionHandler) ProcessInteraction(callback slack.InteractionCallback) *slack.ViewSubmissionResponse {
+	switch callback.Type {
+	case slack.InteractionTypeMessageAction:
+		if callback.CallbackID == callbackCreateIssueShortcut {
+			h.startIssueFromShortcut(callback, callback.Team.ID)
+		}
+
+	case slack.InteractionTypeBlockActions:
+		for _, action := range callback.ActionCallback.BlockActions {
+			switch action.ActionID {
+			case agent.ActionReviewTicket:
+				h.openDraftModal(callback.TriggerID, action.Value, callback.User.ID, callback.Channel.ID)
+			case agent.ActionEndSession:
+				h.endSession(action.Value, callback.User.ID, callback.Channel.ID)
+			case agent.ActionConfirmComment:
+				h.confirmComment(action.Value, callback.User.ID, callback.Channel.ID, callback.Team.ID)
+			}
+		}
+
+	case slack.InteractionTypeViewSubmission:
+		if callback.View.CallbackID != callbackCreateIssueModal {
+			return nil
+		}
+		if errs := h.submitIssueModal(callback); len(errs) > 0 {
+			return slack.NewErrorsViewSubmissionResponse(errs)
+		}
+	}
+	return nil
+}
+
+// endSession ends the summary session whose "End session" button the user clicked.
+func (h *InteractionHandler) endSession(sessionID, userID, channelID string) {
+	text := "Done! I've ended this summary session."
+	if !h.agent.EndSession(context.Background(), userID, sessionID) {
+		text = "This summary session has already ended."
+	}
+	h.slackClient.SendEphemeralMessage(channelID, userID, text)
+}
+
+// confirmComment posts the Jira comment whose "Add comment" button the user clicked.
+func (h *InteractionHandler) confirmComment(commentID, userID, channelID, workspaceID string) {
+	enqueue(h.jobs, h.slackClient, channelID, queue.Job{
+		Name:        "jira_comment",
+		UserID:      userID,
+		WorkspaceID: workspaceID,
+		Run: func(ctx context.Context) {
+			h.slackClient.SendEphemeralMessage(channelID, userID, h.agent.ConfirmComment(ctx, userID, commentID))
+		},
+	})
+}
+
+// startIssueFromShortcut opens a placeholder modal straight away (the trigger ID
+// expires after three seconds) and fills it in once the draft is ready.
+func (h *InteractionHandler) startIssueFromShortcut(callback slack.InteractionCallback, workspaceID string) {
+	threadTS := callback.Message.ThreadTimestamp
+	if threadTS == "" {
+		threadTS = callback.Message.Timestamp
+	}
+	metadata := issueModalMetadata{ChannelID: callback.Channel.ID, ThreadTS: threadTS}
+
+	loading := slack.ModalViewRequest{
+		Type:  slack.VTModal,
+		Title: slack.NewTextBlockObject("plain_text", "Create Jira issue", false, false),
+		Close: slack.NewTextBlockObject("plain_text", "Cancel", false, false),
+		Blocks: slack.Blocks{BlockSet: []slack.Block{
+			slack.NewSectionBlock(slack.NewTextBlockObject("mrkdwn", ":hourglass_flowing_sand: Drafting an issue from this thread...", false, false), nil, nil),
+		}},
+	}
+	view, err := h.slackClient.OpenView(callback.TriggerID, loading)
+	if err != nil {
+		log.Printf("Error opening issue modal: %v", err)
+		return
+	}
+
+	accepted := enqueue(h.jobs, h.slackClient, metadata.ChannelID, queue.Job{
+		Name:        "draft_issue",
+		UserID:      callback.User.ID,
+		WorkspaceID: workspaceID,
+		Run: func(ctx context.Context) {
+			draft, err := h.agent.DraftIssue(ctx, callback.User.ID, metadata.ChannelID, metadata.ThreadTS)
+			if err != nil {
+				log.Printf("Error drafting issue from shortcut: %v", err)
+				draft = agent.IssueDraft{ChannelID: metadata.ChannelID, ThreadTS: metadata.ThreadTS}
+			}
+			if _, err := h.slackClient.UpdateView(view.ID, h.issueModal(draft)); err != nil {
+				log.Printf("Error updating issue modal: %v", err)
+			}
+		},
+	})
+	if !accepted {
+		// Let the user fill in the form themselves rather than leave it loading.
+		if _, err := h.slackClient.UpdateView(view.ID, h.issueModal(agent.IssueDraft{ChannelID: metadata.ChannelID, ThreadTS: metadata.ThreadTS})); err != nil {
+			log.Printf("Error updating issue modal: %v", err)
+		}
+	}
+}
+
+// openDraftModal opens the modal for a draft posted with a review button.
+func (h *InteractionHandler) openDraftModal(triggerID, draftID, userID, channelID string) {
+	draft, ok := h.agent.TakeDraft(context.Background(), draftID)
+	if !ok {
+		h.slackClient.SendEphemeralMessage(channelID, userID, "That draft has expired. Mention me again to draft a new ticket.")
+		return
+	}
+	if _, err := h.slackClient.OpenView(triggerID, h.issueModal(draft)); err != nil {
+		log.Printf("Error opening issue modal: %v", err)
+	}
+}
+
+// issueModal builds the confirmation modal, prefilled with draft. Long drafted
+// fields are truncated, as Slack rejects the whole view otherwise.
+func (h *InteractionHandler) issueModal(draft agent.IssueDraft) slack.ModalViewRequest {
+	metadata, _ := json.Marshal(issueModalMetadata{ChannelID: draft.ChannelID, ThreadTS: draft.ThreadTS})
+
+	input := func(blockID, label, initial string, multiline, optional bool) *slack.InputBlock {
+		element := slack.NewPlainTextInputBlockElement(nil, blockID).WithInitialValue(truncate(initial, maxInitialValue)).WithMultiline(multiline)
+		block := slack.NewInputBlock(blockID, slack.NewTextBlockObject("plain_text", label, false, false), nil, element)
+		block.Optional = optional
+		return block
+	}
+
+	return slack.ModalViewRequest{
+		Type:            slack.VTModal,
+		CallbackID:      callbackCreateIssueModal,
+		PrivateMetadata: string(metadata),
+		Title:           slack.NewTextBlockObject("plain_text", "Create Jira issue", false, false),
+		Submit:          slack.NewTextBlockObject("plain_text", "Create", false, false),
+		Close:           slack.NewTextBlockObject("plain_text", "Cancel", false, false),
+		Blocks: slack.Blocks{BlockSet: []slack.Block{
+			input("project", "Project key", h.defaultProject, false, false),
+			input("issue_type", "Issue type", h.defaultIssueType, false, false),
+			input("summary", "Summary", draft.Summary, false, false),
+			input("description", "Description", draft.Description, true, false),
+			input("acceptance_criteria", "Acceptance criteria (one per line)", strings.Join(draft.AcceptanceCriteria, "\n"), true, true),
+		}},
+	}
+}
+
+// submitIssueModal validates the modal and creates the issue in the background.
+// It returns field errors to show in the modal, if any.
+func (h *InteractionHandler) submitIssueModal(callback slack.InteractionCallback) map[string]string {
+	value := func(blockID string) string {
+		if callback.View.State == nil {
+			return ""
+		}
+		return strings.TrimSpace(callback.View.State.Values[blockID][blockID].Value)
+	}
+
+	project := strings.ToUpper(value("project"))
+	issueType := value("issue_type")
+	errs := make(map[string]string)
+	if project == "" {
+		errs["project"] = "Please enter a project key."
+	}
+	if issueType == "" {
+		errs["issue_type"] = "Please enter an issue type."
+	}
+	if value("summary") == "" {
+		errs["summary"] = "Please enter a summary."
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+
+	var metadata issueModalMetadata
+	json.Unmarshal([]byte(callback.View.PrivateMetadata), &metadata)
+
+	draft := agent.IssueDraft{
+		ChannelID:   metadata.ChannelID,
+		ThreadTS:    metadata.ThreadTS,
+		Summary:     value("summary"),
+		Description: value("description"),
+	}
+	for _, line := range strings.Split(value("acceptance_criteria"), "\n") {
+		if line = strings.TrimSpace(strings.TrimLeft(line, "-•* ")); line != "" {
+			draft.AcceptanceCriteria = append(draft.AcceptanceCriteria, line)
+		}
+	}
+
+	userID := callback.User.ID
+	enqueue(h.jobs, h.slackClient, draft.ChannelID, queue.Job{
+		Name:        "create_issue",
+		UserID:      userID,
+		WorkspaceID: callback.Team.ID,
+		Run: func(ctx context.Context) {
+			key, err := h.agent.CreateIssue(ctx, userID, project, issueType, draft)
+			if err != nil {
+				log.Printf("Error creating Jira issue for user %s: %v", userID, err)
+				h.slackClient.SendEphemeralMessage(draft.ChannelID, userID, fmt.Sprintf("Sorry, I couldn't create the Jira issue: %v", err))
+				return
+			}
+			log.Printf("Created Jira issue %s from thread %s", key, draft.ThreadTS)
+		},
+	})
+	return nil
+}
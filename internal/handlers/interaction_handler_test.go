@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/gemini/go-service-communicator/internal/agent"
+	"github.com/slack-go/slack"
+)
+
+func TestIssueModalTruncatesLongDrafts(t *testing.T) {
+	h := NewInteractionHandler(nil, nil, nil, "PAY", "Task")
+	draft := agent.IssueDraft{
+		Summary:     "Checkout fails",
+		Description: strings.Repeat("é", 5000),
+	}
+
+	for _, block := range h.issueModal(draft).Blocks.BlockSet {
+		input := block.(*slack.InputBlock)
+		value := input.Element.(*slack.PlainTextInputBlockElement).InitialValue
+		if n := utf8.RuneCountInString(value); n > maxInitialValue {
+			t.Errorf("%s has an initial value of %d characters, Slack allows %d", input.BlockID, n, maxInitialValue)
+		}
+		if input.BlockID == "description" && !strings.HasSuffix(value, "…") {
+			t.Errorf("description was not marked as truncated")
+		}
+		if input.BlockID == "summary" && value != draft.Summary {
+			t.Errorf("summary = %q, want it unchanged", value)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package handlers
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gemini/go-service-communicator/internal/queue"
+	"github.com/gemini/go-service-communicator/internal/services/jira"
+	slackclient "github.com/gemini/go-service-communicator/internal/services/slack"
+	"github.com/slack-go/slack"
+)
+
+const (
+	// maxWebhookBody bounds the size of a webhook delivery we are willing to read.
+	maxWebhookBody = 1 << 20
+	// webhookJobUser is the user and workspace key of webhook jobs, so that the
+	// pool's per-user limit keeps a burst of deliveries from taking every worker.
+	webhookJobUser = "jira-webhook"
+)
+
+// JiraWebhookHandler receives Jira webhooks and posts matching events to Slack.
+type JiraWebhookHandler struct {
+	slackClient *slackclient.Client
+	jiraClient  *jira.Client
+	router      *jira.WebhookRouter
+	secret      string
+	jobs        *queue.Pool
+}
+
+// NewJiraWebhookHandler creates a new JiraWebhookHandler. Deliveries must carry
+// secret, either as an HMAC signature or as a shared token. Events are posted
+// to Slack on jobs.
+func NewJiraWebhookHandler(slackClient *slackclient.Client, jiraClient *jira.Client, router *jira.WebhookRouter, secret string, jobs *queue.Pool) *JiraWebhookHandler {
+	return &JiraWebhookHandler{
+		slackClient: slackClient,
+		jiraClient:  jiraClient,
+		router:      router,
+		secret:      secret,
+		jobs:        jobs,
+	}
+}
+
+// HandleWebhook handles a Jira webhook delivery.
+func (h *JiraWebhookHandler) HandleWebhook(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxWebhookBody))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if !h.verify(r, body) {
+		log.Printf("Rejected Jira webhook from %s: missing or invalid secret", r.RemoteAddr)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	event, err := jira.ParseWebhook(body)
+	if err != nil {
+		// Acknowledge events we don't handle so Jira doesn't retry them.
+		log.Printf("Ignoring Jira webhook: %v", err)
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	channels := h.router.Channels(event)
+	if len(channels) == 0 {
+		log.Printf("No webhook rule matched %s on %s", event.Type, event.Issue.Key)
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	message := h.renderEvent(event)
+	_, err = h.jobs.Submit(queue.Job{
+		Name:        "jira_webhook",
+		UserID:      webhookJobUser,
+		WorkspaceID: webhookJobUser,
+		Run: func(ctx context.Context) {
+			for _, channel := range channels {
+				if err := h.slackClient.SendMessage(channel, message); err != nil {
+					log.Printf("Error posting %s for %s to %s: %v", event.Type, event.Issue.Key, channel, err)
+				}
+			}
+		},
+	})
+	if err != nil {
+		// Jira retries deliveries that fail with a server error.
+		log.Printf("Could not queue %s for %s: %v", event.Type, event.Issue.Key, err)
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// verify checks the delivery against the shared secret. Jira Cloud signs the body
+// with HMAC-SHA256 in X-Hub-Signature; other senders may pass the secret itself
+// in the X-Webhook-Secret header. A secret in the URL is not accepted, since
+// URLs end up in proxy and access logs.
+func (h *JiraWebhookHandler) verify(r *http.Request, body []byte) bool {
+	if h.secret == "" {
+		log.Println("Jira webhook secret is not configured; rejecting delivery")
+		return false
+	}
+
+	if signature := r.Header.Get("X-Hub-Signature"); signature != "" {
+		got, err := hex.DecodeString(strings.TrimPrefix(signature, "sha256="))
+		if err != nil {
+			return false
+		}
+		mac := hmac.New(sha256.New, []byte(h.secret))
+		mac.Write(body)
+		return hmac.Equal(got, mac.Sum(nil))
+	}
+
+	token := r.Header.Get("X-Webhook-Secret")
+	if token == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(h.secret)) == 1
+}
+
+// renderEvent renders a webhook event as Block Kit JSON.
+func (h *JiraWebhookHandler) renderEvent(event *jira.WebhookEvent) string {
+	issue := event.Issue
+	link := issue.Key
+	if h.jiraClient.Configured() {
+		link = fmt.Sprintf("<%s|%s>", h.jiraClient.BrowseURL(issue.Key), issue.Key)
+	}
+
+	actor := event.Actor
+	if actor == "" {
+		actor = "Someone"
+	}
+	var headline string
+	switch event.Type {
+	case jira.EventIssueCreated:
+		headline = fmt.Sprintf(":new: %s created %s", actor, link)
+	case jira.EventIssueUpdated:
+		headline = fmt.Sprintf(":pencil2: %s updated %s", actor, link)
+	case jira.EventCommentCreated:
+		headline = fmt.Sprintf(":speech_balloon: %s commented on %s", actor, link)
+	}
+
+	blocks := []slack.Block{
+		slack.NewSectionBlock(slack.NewTextBlockObject("mrkdwn", truncate(headline+"\n*"+issue.Summary+"*", slackclient.MaxSectionTextLength), false, false), nil, nil),
+	}
+
+	var fields []*slack.TextBlockObject
+	for _, field := range []struct{ label, value string }{
+		{"Project", issue.Project},
+		{"Type", issue.IssueType},
+		{"Priority", issue.Priority},
+		{"Status", issue.Status},
+		{"Assignee", issue.Assignee},
+	} {
+		if field.value != "" {
+			fields = append(fields, slack.NewTextBlockObject("mrkdwn", fmt.Sprintf("*%s*\n%s", field.label, field.value), false, false))
+		}
+	}
+	if len(fields) > 0 {
+		blocks = append(blocks, slack.NewSectionBlock(nil, fields, nil))
+	}
+
+	if len(event.Changes) > 0 {
+		var lines []string
+		for _, change := range event.Changes {
+			from, to := change.From, change.To
+			if from == "" {
+				from = "_none_"
+			}
+			if to == "" {
+				to = "_none_"
+			}
+			lines = append(lines, fmt.Sprintf("• *%s*: %s → %s", change.Field, from, to))
+		}
+		blocks = append(blocks, slack.NewSectionBlock(slack.NewTextBlockObject("mrkdwn", truncate(strings.Join(lines, "\n"), slackclient.MaxSectionTextLength), false, false), nil, nil))
+	}
+
+	if event.Comment != nil && event.Comment.Body != "" {
+		blocks = append(blocks, slack.NewSectionBlock(slack.NewTextBlockObject("mrkdwn", truncate("> "+strings.ReplaceAll(event.Comment.Body, "\n", "\n> "), slackclient.MaxSectionTextLength), false, false), nil, nil))
+	}
+
+	payload, err := json.Marshal(blocks)
+	if err != nil {
+		return headline
+	}
+	return string(payload)
+}
+
+// truncate shortens s to at most limit characters.
+func truncate(s string, limit int) string {
+	if runes := []rune(s); len(runes) > limit {
+		return string(runes[:limit-1]) + "…"
+	}
+	return s
+}
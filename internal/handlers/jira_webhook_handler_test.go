@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gemini/go-service-communicator/internal/services/jira"
+)
+
+func TestJiraWebhookAuthentication(t *testing.T) {
+	const body = `{"webhookEvent":"jira:issue_deleted","issue":{"key":"PAY-1"}}`
+	sign := func(secret, body string) string {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write([]byte(body))
+		return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	}
+
+	tests := []struct {
+		name    string
+		secret  string
+		url     string
+		headers map[string]string
+		want    int
+	}{
+		{"valid signature", "s3cret", "/jira/webhook", map[string]string{"X-Hub-Signature": sign("s3cret", body)}, http.StatusOK},
+		{"signature without prefix", "s3cret", "/jira/webhook", map[string]string{"X-Hub-Signature": strings.TrimPrefix(sign("s3cret", body), "sha256=")}, http.StatusOK},
+		{"signature with another secret", "s3cret", "/jira/webhook", map[string]string{"X-Hub-Signature": sign("other", body)}, http.StatusUnauthorized},
+		{"signature of another body", "s3cret", "/jira/webhook", map[string]string{"X-Hub-Signature": sign("s3cret", body+" ")}, http.StatusUnauthorized},
+		{"malformed signature", "s3cret", "/jira/webhook", map[string]string{"X-Hub-Signature": "sha256=zz"}, http.StatusUnauthorized},
+		{"a bad signature isn't rescued by the token", "s3cret", "/jira/webhook", map[string]string{"X-Hub-Signature": sign("other", body), "X-Webhook-Secret": "s3cret"}, http.StatusUnauthorized},
+		{"shared token", "s3cret", "/jira/webhook", map[string]string{"X-Webhook-Secret": "s3cret"}, http.StatusOK},
+		{"wrong token", "s3cret", "/jira/webhook", map[string]string{"X-Webhook-Secret": "s3cre"}, http.StatusUnauthorized},
+		{"secret in the query", "s3cret", "/jira/webhook?secret=s3cret", nil, http.StatusUnauthorized},
+		{"no credentials", "s3cret", "/jira/webhook", nil, http.StatusUnauthorized},
+		{"no secret configured", "", "/jira/webhook", map[string]string{"X-Webhook-Secret": ""}, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, _ := jira.NewWebhookRouter(nil)
+			h := NewJiraWebhookHandler(nil, jira.New(jira.Config{}), router, tt.secret, nil)
+			req := httptest.NewRequest(http.MethodPost, tt.url, strings.NewReader(body))
+			for name, value := range tt.headers {
+				req.Header.Set(name, value)
+			}
+			rec := httptest.NewRecorder()
+
+			h.HandleWebhook(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gemini/go-service-communicator/internal/queue"
+	slackclient "github.com/gemini/go-service-communicator/internal/services/slack"
+)
+
+// enqueue submits work for a user to the job pool and tells them, in channelID,
+// when it has to wait or can't be accepted. It reports whether the job was accepted.
+func enqueue(jobs *queue.Pool, slackClient *slackclient.Client, channelID string, job queue.Job) bool {
+	ticket, err := jobs.Submit(job)
+	if errors.Is(err, queue.ErrQueueFull) {
+		slackClient.SendEphemeralMessage(channelID, job.UserID, "Sorry, I'm handling a lot of requests right now and can't take yours. Please try again in a few minutes.")
+		return false
+	}
+	if err != nil {
+		log.Printf("Error queueing %s job for user %s: %v", job.Name, job.UserID, err)
+		return false
+	}
+	if ticket.Position > 0 {
+		slackClient.SendEphemeralMessage(channelID, job.UserID, fmt.Sprintf("You're in the queue (position %d). I'll get to your request as soon as I can.", ticket.Position))
+	}
+	return true
+}
+
+// QueueStatsHandler reports the job pool's depth and counters as JSON.
+type QueueStatsHandler struct {
+	jobs  *queue.Pool
+	token string
+}
+
+// NewQueueStatsHandler creates a new QueueStatsHandler. Requests must carry
+// token as a bearer token; with an empty token every request is rejected.
+func NewQueueStatsHandler(jobs *queue.Pool, token string) *QueueStatsHandler {
+	return &QueueStatsHandler{jobs: jobs, token: token}
+}
+
+// HandleStats handles the metrics request.
+func (h *QueueStatsHandler) HandleStats(w http.ResponseWriter, r *http.Request) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || h.token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(h.token)) != 1 {
+		log.Printf("Rejected queue metrics request from %s: missing or wrong token", r.RemoteAddr)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.jobs.Stats())
+}
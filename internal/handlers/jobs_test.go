@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gemini/go-service-communicator/internal/queue"
+)
+
+func TestQueueStatsAuthentication(t *testing.T) {
+	jobs := queue.New(context.Background(), queue.Config{})
+	t.Cleanup(jobs.Close)
+
+	tests := []struct {
+		name   string
+		token  string
+		header string
+		want   int
+	}{
+		{"valid token", "m3trics", "Bearer m3trics", http.StatusOK},
+		{"wrong token", "m3trics", "Bearer m3tric", http.StatusUnauthorized},
+		{"not a bearer token", "m3trics", "m3trics", http.StatusUnauthorized},
+		{"no header", "m3trics", "", http.StatusUnauthorized},
+		{"no token configured", "", "Bearer ", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewQueueStatsHandler(jobs, tt.token)
+			req := httptest.NewRequest(http.MethodGet, "/metrics/queue", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			rec := httptest.NewRecorder()
+
+			h.HandleStats(rec, req)
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.want)
+			}
+			if tt.want == http.StatusOK && !strings.Contains(rec.Body.String(), `"workers":4`) {
+				t.Errorf("body = %s, want the pool stats", rec.Body)
+			}
+		})
+	}
+}
@@ -1,37 +1,81 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
 	"io"
+	"log"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/gemini/go-service-communicator/internal/agent"
+	"github.com/gemini/go-service-communicator/internal/dedup"
+	"github.com/gemini/go-service-communicator/internal/intent"
+	"github.com/gemini/go-service-communicator/internal/queue"
 	"github.com/gemini/go-service-communicator/internal/services/slack"
+	"github.com/gemini/go-service-communicator/internal/storage"
 	"github.com/slack-go/slack/slackevents"
 )
 
-const maxHistory = 10
+const (
+	maxHistory = 10
+	// historyBucket holds each user's recent DM turns.
+	historyBucket = "dm_history"
+	// defaultHistoryTTL is how long a DM conversation is remembered after its last turn.
+	defaultHistoryTTL = 24 * time.Hour
+)
 
 // SlackEventHandler handles Slack event subscriptions.
 type SlackEventHandler struct {
-	slackClient         *slack.Client
-	agent               *agent.Processor
-	botUserID           string
-	conversationHistory map[string][]string
+	slackClient *slack.Client
+	agent       *agent.Processor
+	botUserID   string
+	store       storage.Store
+	historyTTL  time.Duration
+	jobs        *queue.Pool
+	dedupStore  dedup.Store
+	dedupTTL    time.Duration
 }
 
-// NewSlackEventHandler creates a new SlackEventHandler.
-func NewSlackEventHandler(slackClient *slack.Client, agent *agent.Processor, botUserID string) *SlackEventHandler {
+// NewSlackEventHandler creates a new SlackEventHandler. Events are processed on jobs.
+func NewSlackEventHandler(slackClient *slack.Client, agent *agent.Processor, botUserID string, jobs *queue.Pool) *SlackEventHandler {
 	return &SlackEventHandler{
-		slackClient:         slackClient,
-		agent:               agent,
-		botUserID:           botUserID,
-		conversationHistory: make(map[string][]string),
+		slackClient: slackClient,
+		agent:       agent,
+		botUserID:   botUserID,
+		jobs:        jobs,
+		store:       storage.NewMemoryStore(),
+		historyTTL:  defaultHistoryTTL,
+		dedupStore:  dedup.NewMemoryStore(),
+		dedupTTL:    dedup.DefaultTTL,
+	}
+}
+
+// SetDedupStore replaces the in-memory store used to drop retried events, e.g.
+// with one shared by several replicas. A non-positive ttl keeps the current TTL.
+func (h *SlackEventHandler) SetDedupStore(store dedup.Store, ttl time.Duration) {
+	if store != nil {
+		h.dedupStore = store
+	}
+	if ttl > 0 {
+		h.dedupTTL = ttl
+	}
+}
+
+// SetStorage replaces the in-memory store that keeps DM history, e.g. with one
+// that survives restarts. A non-positive historyTTL keeps the current TTL.
+func (h *SlackEventHandler) SetStorage(store storage.Store, historyTTL time.Duration) {
+	if store != nil {
+		h.store = store
+	}
+	if historyTTL > 0 {
+		h.historyTTL = historyTTL
 	}
 }
 
-// HandleEvent handles incoming Slack events.
+// HandleEvent handles incoming Slack events. Requests must already be verified
+// by VerifySlackSignature.
 func (h *SlackEventHandler) HandleEvent(w http.ResponseWriter, r *http.Request) {
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
@@ -39,6 +83,8 @@ func (h *SlackEventHandler) HandleEvent(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	// The request signature has been checked by the middleware, which supersedes
+	// the deprecated verification token.
 	eventsAPIEvent, err := slackevents.ParseEvent(json.RawMessage(body), slackevents.OptionNoVerifyToken())
 	if err != nil {
 		w.WriteHeader(http.StatusBadRequest)
@@ -57,61 +103,151 @@ func (h *SlackEventHandler) HandleEvent(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	if eventsAPIEvent.Type == slackevents.CallbackEvent {
-		// Acknowledge the event immediately to prevent Slack from retrying.
-		w.WriteHeader(http.StatusOK)
-
-		// Run the actual processing in a goroutine.
-		go func() {
-			innerEvent := eventsAPIEvent.InnerEvent
-			switch ev := innerEvent.Data.(type) {
-			case *slackevents.AppMentionEvent:
-				// Ignore messages from the bot itself
-				if ev.User == h.botUserID {
-					return
-				}
-
-				lowerMessage := strings.ToLower(ev.Text)
-				if strings.Contains(lowerMessage, "summary") || strings.Contains(lowerMessage, "summarize") {
-					h.slackClient.SendEphemeralMessage(ev.Channel, ev.User, "Processing your request to summarize the channel...")
-
-					// Generate summary
-					summary := h.agent.ProcessMessage(ev.User, ev.Channel, ev.Text)
-
-					h.slackClient.SendEphemeralMessage(ev.Channel, ev.User, summary)
-				} else {
-					// For other mentions, just a direct response.
-					response := h.agent.ProcessMessage(ev.User, ev.Channel, ev.Text)
-					h.slackClient.SendMessage(ev.Channel, response)
-				}
-
-			case *slackevents.MessageEvent:
-				// Handle direct messages to the bot
-				if ev.ChannelType == "im" {
-					// Ignore messages from the bot itself to prevent loops
-					if ev.User == h.botUserID {
-						return
-					}
-
-					// Retrieve conversation history
-					history := h.conversationHistory[ev.User]
-
-					// Get the AI's response
-					response := h.agent.ProcessDM(ev.User, history, ev.Text)
-
-					// Update history with the new turn
-					history = append(history, "User: "+ev.Text)
-					history = append(history, "Assistant: "+response)
-
-					// Trim history to keep it from growing indefinitely
-					if len(history) > maxHistory {
-						history = history[len(history)-maxHistory:]
-					}
-					h.conversationHistory[ev.User] = history
-
-					h.slackClient.SendMessage(ev.Channel, response)
-				}
-			}
-		}()
+	// Acknowledge the event immediately to prevent Slack from retrying.
+	w.WriteHeader(http.StatusOK)
+	h.ProcessEvent(eventsAPIEvent, r.Header.Get("X-Slack-Retry-Num"), r.Header.Get("X-Slack-Retry-Reason"))
+}
+
+// ProcessEvent handles an Events API event that has already been acknowledged,
+// whether it arrived over HTTP or Socket Mode. retryNum and retryReason describe
+// Slack's redelivery attempt, if any.
+func (h *SlackEventHandler) ProcessEvent(eventsAPIEvent slackevents.EventsAPIEvent, retryNum, retryReason string) {
+	if eventsAPIEvent.Type != slackevents.CallbackEvent {
+		return
+	}
+	if h.isDuplicate(eventsAPIEvent, retryNum, retryReason) {
+		return
+	}
+
+	// Run the actual processing on the job pool.
+	switch ev := eventsAPIEvent.InnerEvent.Data.(type) {
+	case *slackevents.AppMentionEvent:
+		// Ignore messages from the bot itself
+		if ev.User == h.botUserID {
+			return
+		}
+		enqueue(h.jobs, h.slackClient, ev.Channel, queue.Job{
+			Name:        "mention",
+			UserID:      ev.User,
+			WorkspaceID: eventsAPIEvent.TeamID,
+			Run:         func(ctx context.Context) { h.handleMention(ctx, ev) },
+		})
+
+	case *slackevents.MessageEvent:
+		// Handle direct messages to the bot, ignoring its own messages to prevent loops
+		if ev.ChannelType != "im" || ev.User == h.botUserID {
+			return
+		}
+		enqueue(h.jobs, h.slackClient, ev.Channel, queue.Job{
+			Name:        "dm",
+			UserID:      ev.User,
+			WorkspaceID: eventsAPIEvent.TeamID,
+			Run:         func(ctx context.Context) { h.handleDM(ctx, ev) },
+		})
+	}
+}
+
+// handleMention answers an @mention in a channel. Mentions inside a thread are
+// answered in that thread.
+func (h *SlackEventHandler) handleMention(ctx context.Context, ev *slackevents.AppMentionEvent) {
+	text := h.stripBotMention(ev.Text)
+	req := intent.Request{UserID: ev.User, ChannelID: ev.Channel, Text: text, MessageTS: ev.TimeStamp, ThreadTS: ev.ThreadTimeStamp}
+	result := h.agent.Classify(ctx, text)
+
+	reply := func(message string) {
+		if ev.ThreadTimeStamp != "" {
+			h.slackClient.SendMessageInThread(ev.Channel, ev.ThreadTimeStamp, message)
+			return
+		}
+		h.slackClient.SendMessage(ev.Channel, message)
+	}
+	replyEphemeral := func(message string) {
+		if ev.ThreadTimeStamp != "" {
+			h.slackClient.SendEphemeralMessageInThread(ev.Channel, ev.ThreadTimeStamp, ev.User, message)
+			return
+		}
+		h.slackClient.SendEphemeralMessage(ev.Channel, ev.User, message)
+	}
+
+	// Answers that may quote private conversations, Jira issues or drafts are
+	// only shown to the requester, as are answers about a summary they were shown.
+	if !h.agent.IsPrivate(result.Intent) && !h.agent.HasSession(ctx, ev.User, ev.Channel) {
+		reply(h.agent.Handle(ctx, req, result))
+		return
+	}
+
+	switch {
+	case result.Intent == agent.IntentSummarize && result.Slots.Scope == intent.ScopeThread:
+		replyEphemeral("Processing your request to summarize this thread...")
+	case result.Intent == agent.IntentSummarize:
+		replyEphemeral("Processing your request to summarize the channel...")
+	case result.Intent == agent.IntentMentions:
+		replyEphemeral("Working on your catch-up. This might take a moment...")
+	}
+	replyEphemeral(h.agent.Handle(ctx, req, result))
+}
+
+// handleDM answers a direct message, keeping a short conversation history per user.
+func (h *SlackEventHandler) handleDM(ctx context.Context, ev *slackevents.MessageEvent) {
+	// Retrieve conversation history
+	var history []string
+	if data, ok, err := h.store.Get(ctx, historyBucket, ev.User); err != nil {
+		log.Printf("Error loading DM history for user %s: %v", ev.User, err)
+	} else if ok {
+		if err := json.Unmarshal(data, &history); err != nil {
+			log.Printf("Discarding unreadable DM history for user %s: %v", ev.User, err)
+		}
+	}
+
+	// Get the AI's response
+	response := h.agent.ProcessDM(ctx, ev.User, history, ev.Text)
+
+	// Append the new turn to the stored history, which may have changed meanwhile.
+	err := h.store.Update(ctx, historyBucket, ev.User, h.historyTTL, func(old []byte) ([]byte, error) {
+		var stored []string
+		if old != nil {
+			json.Unmarshal(old, &stored)
+		}
+		stored = append(stored, "User: "+ev.Text, "Assistant: "+response)
+
+		// Trim history to keep it from growing indefinitely
+		if len(stored) > maxHistory {
+			stored = stored[len(stored)-maxHistory:]
+		}
+		return json.Marshal(stored)
+	})
+	if err != nil {
+		log.Printf("Error saving DM history for user %s: %v", ev.User, err)
+	}
+
+	h.slackClient.SendMessage(ev.Channel, response)
+}
+
+// isDuplicate claims the event's ID and reports whether it was already handled,
+// typically because this delivery is a Slack retry. If the store fails the event
+// is processed, since a duplicate answer is better than none.
+func (h *SlackEventHandler) isDuplicate(event slackevents.EventsAPIEvent, retryNum, retryReason string) bool {
+	callback, ok := event.Data.(*slackevents.EventsAPICallbackEvent)
+	if !ok || callback.EventID == "" {
+		return false
+	}
+
+	first, err := h.dedupStore.Claim(context.Background(), "slack-event:"+callback.EventID, h.dedupTTL)
+	if err != nil {
+		log.Printf("Error checking event %s for duplicates: %v", callback.EventID, err)
+		return false
+	}
+	if !first {
+		log.Printf("Dropping duplicate event %s (retry %s, reason %q)", callback.EventID, retryNum, retryReason)
+		return true
 	}
+	if retryNum != "" {
+		log.Printf("Processing retry %s of event %s that was not handled before (reason %q)", retryNum, callback.EventID, retryReason)
+	}
+	return false
+}
+
+// stripBotMention removes the bot's own @mention so it isn't mistaken for a user slot.
+func (h *SlackEventHandler) stripBotMention(text string) string {
+	return strings.TrimSpace(strings.ReplaceAll(text, "<@"+h.botUserID+">", ""))
 }
@@ -0,0 +1,310 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gemini/go-service-communicator/internal/agent"
+	"github.com/gemini/go-service-communicator/internal/llm"
+	"github.com/gemini/go-service-communicator/internal/services/jira"
+	"github.com/gemini/go-service-communicator/internal/services/slack"
+	"github.com/slack-go/slack/slackevents"
+)
+
+// secret only appears in the private channel G1, whose single member is U1.
+const secret = "the launch code is hunter2"
+
+// unavailableLLM fails every call, so the agent falls back to its rule-based
+// classifier and plain-text answers.
+type unavailableLLM struct{}
+
+func (unavailableLLM) Generate(ctx context.Context, prompt string, opts ...llm.Option) (string, error) {
+	return "", errors.New("no model in tests")
+}
+
+// quotingLLM stands in for a model that quotes everything it was given: it
+// answers with a section block that repeats the secret whenever the prompt
+// contains it. Classification falls back to the rules, as its answer isn't JSON.
+type quotingLLM struct{}
+
+func (quotingLLM) Generate(ctx context.Context, prompt string, opts ...llm.Option) (string, error) {
+	text := "Nothing to report."
+	if strings.Contains(prompt, secret) {
+		text = "Someone said: " + secret
+	}
+	return fmt.Sprintf(`[{"type":"section","text":{"type":"mrkdwn","text":%q}}]`, text), nil
+}
+
+// slackRequest is a call received by the fake Slack API.
+type slackRequest struct {
+	Method string
+	Form   url.Values
+}
+
+// fakeWorkspace serves a public channel C1 with members U1 and U2 and a private
+// channel G1 with the single member U1, and records every request. UADMIN is a
+// workspace admin who is in neither channel.
+type fakeWorkspace struct {
+	mu       sync.Mutex
+	requests []slackRequest
+}
+
+func newFakeWorkspace(t *testing.T) (*slack.Client, *fakeWorkspace) {
+	t.Helper()
+	ws := &fakeWorkspace{}
+	recent := fmt.Sprintf("%d.000100", time.Now().Add(-time.Hour).Unix())
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		method := strings.TrimPrefix(r.URL.Path, "/")
+		ws.mu.Lock()
+		ws.requests = append(ws.requests, slackRequest{Method: method, Form: r.Form})
+		ws.mu.Unlock()
+
+		var body string
+		switch method {
+		case "conversations.info":
+			if r.FormValue("channel") == "G1" {
+				body = `{"ok":true,"channel":{"id":"G1","name":"secret","is_private":true}}`
+			} else {
+				body = `{"ok":true,"channel":{"id":"C1","name":"general"}}`
+			}
+		case "conversations.members":
+			if r.FormValue("channel") == "G1" {
+				body = `{"ok":true,"members":["U1"],"response_metadata":{"next_cursor":""}}`
+			} else {
+				body = `{"ok":true,"members":["U1","U2"],"response_metadata":{"next_cursor":""}}`
+			}
+		case "users.conversations":
+			if r.FormValue("user") == "U2" {
+				body = `{"ok":true,"channels":[{"id":"C1","name":"general"}],"response_metadata":{"next_cursor":""}}`
+			} else {
+				body = `{"ok":true,"channels":[{"id":"C1","name":"general"},{"id":"G1","name":"secret","is_private":true}],"response_metadata":{"next_cursor":""}}`
+			}
+		case "conversations.history":
+			if r.FormValue("channel") == "G1" {
+				body = fmt.Sprintf(`{"ok":true,"messages":[{"type":"message","user":"U3","text":"<@U1> <@U2> %s","ts":%q}]}`, secret, recent)
+			} else {
+				body = fmt.Sprintf(`{"ok":true,"messages":[{"type":"message","user":"U3","text":"<@U1> <@U2> lunch is at noon","ts":%q}]}`, recent)
+			}
+		case "conversations.replies":
+			body = `{"ok":true,"messages":[]}`
+		case "usergroups.list":
+			body = `{"ok":true,"usergroups":[]}`
+		case "users.info":
+			body = fmt.Sprintf(`{"ok":true,"user":{"id":%q,"name":"someone","tz":"UTC","is_admin":%t}}`, r.FormValue("user"), r.FormValue("user") == "UADMIN")
+		case "chat.getPermalink":
+			body = `{"ok":true,"permalink":"https://example.slack.com/archives/p1"}`
+		case "chat.postMessage", "chat.postEphemeral":
+			body = `{"ok":true,"channel":"C1","ts":"1.000001","message_ts":"1.000001"}`
+		default:
+			t.Errorf("unexpected call to %s", method)
+			body = `{"ok":false,"error":"unknown_method"}`
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+
+	client := slack.New("xoxb-test")
+	client.SetAPIURL(server.URL)
+	return client, ws
+}
+
+// posts returns the chat.postMessage and chat.postEphemeral requests.
+func (ws *fakeWorkspace) posts() []slackRequest {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	var posts []slackRequest
+	for _, req := range ws.requests {
+		if req.Method == "chat.postMessage" || req.Method == "chat.postEphemeral" {
+			posts = append(posts, req)
+		}
+	}
+	return posts
+}
+
+// read reports whether method was called for channel.
+func (ws *fakeWorkspace) read(method, channel string) bool {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	for _, req := range ws.requests {
+		if req.Method == method && req.Form.Get("channel") == channel {
+			return true
+		}
+	}
+	return false
+}
+
+// content returns everything a post would show.
+func content(req slackRequest) string {
+	return req.Form.Get("text") + req.Form.Get("blocks") + req.Form.Get("attachments")
+}
+
+func newTestHandler(t *testing.T) (*SlackEventHandler, *fakeWorkspace) {
+	t.Helper()
+	client, ws := newFakeWorkspace(t)
+	processor := agent.New(unavailableLLM{}, client, jira.New(jira.Config{}))
+	return NewSlackEventHandler(client, processor, "UBOT", nil), ws
+}
+
+func mention(user, text string) *slackevents.AppMentionEvent {
+	return &slackevents.AppMentionEvent{User: user, Channel: "C1", Text: "<@UBOT> " + text, TimeStamp: "2.000001"}
+}
+
+func TestMentionCatchUpIsOnlyShownToTheRequester(t *testing.T) {
+	h, ws := newTestHandler(t)
+	h.handleMention(context.Background(), mention("U1", "what did I miss?"))
+
+	shown := false
+	for _, post := range ws.posts() {
+		if post.Method != "chat.postEphemeral" {
+			t.Errorf("catch-up posted publicly: %s", content(post))
+			continue
+		}
+		if post.Form.Get("user") != "U1" {
+			t.Errorf("catch-up shown to %s, want U1", post.Form.Get("user"))
+		}
+		if strings.Contains(content(post), "hunter2") {
+			shown = true
+		}
+	}
+	if !shown {
+		t.Error("the private mention was not part of the member's catch-up")
+	}
+}
+
+func TestMentionCatchUpSkipsChannelsTheUserIsNotIn(t *testing.T) {
+	h, ws := newTestHandler(t)
+	h.handleMention(context.Background(), mention("U2", "what did I miss?"))
+
+	if ws.read("conversations.history", "G1") {
+		t.Error("read the history of a private channel for a non-member")
+	}
+	for _, post := range ws.posts() {
+		if strings.Contains(content(post), "hunter2") {
+			t.Errorf("non-member saw private content via %s: %s", post.Method, content(post))
+		}
+	}
+}
+
+func TestMentionSummaryOfPrivateChannelIsRefusedForNonMembers(t *testing.T) {
+	h, ws := newTestHandler(t)
+	h.handleMention(context.Background(), mention("U2", "summarize <#G1|secret>"))
+
+	if ws.read("conversations.history", "G1") {
+		t.Error("read the history of a private channel for a non-member")
+	}
+	refused := false
+	for _, post := range ws.posts() {
+		if post.Method != "chat.postEphemeral" {
+			t.Errorf("summary request answered publicly: %s", content(post))
+		}
+		if strings.Contains(content(post), "hunter2") {
+			t.Errorf("non-member saw private content: %s", content(post))
+		}
+		if strings.Contains(content(post), "member of") {
+			refused = true
+		}
+	}
+	if !refused {
+		t.Error("the non-member was not told why nothing was summarized")
+	}
+}
+
+func TestMentionWithoutLLMKeyExplainsSetup(t *testing.T) {
+	client, ws := newFakeWorkspace(t)
+	gemini, err := llm.NewGemini(context.Background(), "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := NewSlackEventHandler(client, agent.New(gemini, client, jira.New(jira.Config{})), "UBOT", nil)
+	h.handleMention(context.Background(), mention("U1", "how do I rotate the API keys?"))
+
+	posts := ws.posts()
+	if len(posts) == 0 {
+		t.Fatal("nothing was posted")
+	}
+	if got := content(posts[len(posts)-1]); !strings.Contains(got, "API key for the LLM provider") {
+		t.Errorf("reply = %q, want the setup hint", got)
+	}
+}
+
+func TestFollowUpToCrossChannelSummaryStaysPrivate(t *testing.T) {
+	client, ws := newFakeWorkspace(t)
+	h := NewSlackEventHandler(client, agent.New(quotingLLM{}, client, jira.New(jira.Config{})), "UBOT", nil)
+	h.handleMention(context.Background(), mention("U1", "summarize <#C1|general> <#G1|secret>"))
+	summarized := len(ws.posts())
+	if summarized == 0 {
+		t.Fatal("nothing was posted")
+	}
+
+	h.handleMention(context.Background(), mention("U1", "who said that?"))
+	followUp := ws.posts()[summarized:]
+	if len(followUp) == 0 {
+		t.Fatal("the follow-up was not answered")
+	}
+	for _, post := range followUp {
+		if post.Method == "chat.postMessage" && strings.Contains(content(post), "hunter2") {
+			t.Errorf("follow-up in #general quoted the private channel publicly: %s", content(post))
+		}
+	}
+}
+
+func TestChatFollowUpsNeverPostSessionContentPublicly(t *testing.T) {
+	tests := []struct {
+		name    string
+		summary *slackevents.AppMentionEvent
+		// followUp is the chat turn after the summary.
+		followUp *slackevents.AppMentionEvent
+	}{
+		{"same channel", &slackevents.AppMentionEvent{User: "U1", Channel: "G1", Text: "<@UBOT> summarize this channel"},
+			&slackevents.AppMentionEvent{User: "U1", Channel: "G1", Text: "<@UBOT> who said that?"}},
+		{"same channel in a thread", &slackevents.AppMentionEvent{User: "U1", Channel: "G1", Text: "<@UBOT> summarize this channel"},
+			&slackevents.AppMentionEvent{User: "U1", Channel: "G1", Text: "<@UBOT> who said that?", ThreadTimeStamp: "1.000001"}},
+		{"named channel asked elsewhere", mention("U1", "summarize <#G1|secret>"), mention("U1", "who said that?")},
+		{"another user in the channel", &slackevents.AppMentionEvent{User: "U1", Channel: "G1", Text: "<@UBOT> summarize this channel"},
+			&slackevents.AppMentionEvent{User: "U2", Channel: "G1", Text: "<@UBOT> who said that?"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, ws := newFakeWorkspace(t)
+			h := NewSlackEventHandler(client, agent.New(quotingLLM{}, client, jira.New(jira.Config{})), "UBOT", nil)
+			h.handleMention(context.Background(), tt.summary)
+			summarized := len(ws.posts())
+
+			h.handleMention(context.Background(), tt.followUp)
+			followUp := ws.posts()[summarized:]
+			if len(followUp) == 0 {
+				t.Fatal("the follow-up was not answered")
+			}
+			for _, post := range followUp {
+				if !strings.Contains(content(post), "hunter2") {
+					continue
+				}
+				if post.Method != "chat.postEphemeral" || post.Form.Get("user") != "U1" {
+					t.Errorf("session content reached %s via %s: %s", post.Form.Get("user"), post.Method, content(post))
+				}
+			}
+		})
+	}
+}
+
+func TestDMFollowUpUsesCrossChannelSession(t *testing.T) {
+	client, _ := newFakeWorkspace(t)
+	processor := agent.New(quotingLLM{}, client, jira.New(jira.Config{}))
+	h := NewSlackEventHandler(client, processor, "UBOT", nil)
+	h.handleMention(context.Background(), mention("U1", "summarize <#C1|general> <#G1|secret>"))
+
+	answer := processor.ProcessDM(context.Background(), "U1", nil, "who said that?")
+	if !strings.Contains(answer, "hunter2") {
+		t.Errorf("DM answer = %q, want it to use the summary session", answer)
+	}
+}
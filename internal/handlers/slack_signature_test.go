@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// The example request from Slack's "Verifying requests from Slack" guide.
+const (
+	exampleSecret    = "8f742231b10e8888abcd99yyyzzz85a5"
+	exampleTimestamp = "1531420618"
+	exampleBody      = "token=xyzz0WbapA4vBCDEFasx0q6G&team_id=T1DC2JH3J&team_domain=testteamnow&channel_id=G8PSS9T3V&channel_name=foobar&user_id=U2CERLKJA&user_name=roadrunner&command=%2Fwebhook-collect&text=&response_url=https%3A%2F%2Fhooks.slack.com%2Fcommands%2FT1DC2JH3J%2F397700885554%2F96rGlfmibIGlgcZRskXaIFfN&trigger_id=398738663015.47445629121.803a0bc887a14d10d2c447fce8b6703c"
+	exampleSignature = "v0=a2114d57b48eac39b9ad189dd8316235a7b4a8d21a10bd27519666489c69b503"
+)
+
+func exampleHeader() http.Header {
+	header := http.Header{}
+	header.Set("X-Slack-Request-Timestamp", exampleTimestamp)
+	header.Set("X-Slack-Signature", exampleSignature)
+	return header
+}
+
+func TestCheckSlackSignature(t *testing.T) {
+	sent := time.Unix(1531420618, 0)
+	tests := []struct {
+		name   string
+		secret string
+		header func(http.Header)
+		body   string
+		now    time.Time
+		want   error
+	}{
+		{name: "valid"},
+		{name: "slightly early clock", now: sent.Add(-time.Minute)},
+		{name: "tampered body", body: strings.Replace(exampleBody, "roadrunner", "coyote", 1), want: errSignatureMismatch},
+		{name: "wrong secret", secret: "another-secret", want: errSignatureMismatch},
+		{name: "stale timestamp", now: sent.Add(DefaultSignatureMaxSkew + time.Second), want: errTimestampSkew},
+		{name: "future timestamp", now: sent.Add(-DefaultSignatureMaxSkew - time.Second), want: errTimestampSkew},
+		{name: "missing timestamp", header: func(h http.Header) { h.Del("X-Slack-Request-Timestamp") }, want: errSignatureHeaders},
+		{name: "missing signature", header: func(h http.Header) { h.Del("X-Slack-Signature") }, want: errSignatureHeaders},
+		{name: "other version", header: func(h http.Header) { h.Set("X-Slack-Signature", "v1="+exampleSignature[3:]) }, want: errSignatureHeaders},
+		{name: "malformed signature", header: func(h http.Header) { h.Set("X-Slack-Signature", "v0=not-hex") }, want: errSignatureMismatch},
+		{name: "no secret configured", secret: "-", want: errNoSigningSecret},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			secret, body, now := exampleSecret, exampleBody, sent
+			switch tt.secret {
+			case "":
+			case "-":
+				secret = ""
+			default:
+				secret = tt.secret
+			}
+			if tt.body != "" {
+				body = tt.body
+			}
+			if !tt.now.IsZero() {
+				now = tt.now
+			}
+			header := exampleHeader()
+			if tt.header != nil {
+				tt.header(header)
+			}
+			err := checkSlackSignature(secret, header, []byte(body), now, DefaultSignatureMaxSkew)
+			if (tt.want == nil && err != nil) || !errors.Is(err, tt.want) {
+				t.Errorf("checkSlackSignature = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+// signedRequest builds a request to the events endpoint signed at time at.
+func signedRequest(secret, body string, at time.Time) *http.Request {
+	timestamp := strconv.FormatInt(at.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("v0:" + timestamp + ":" + body))
+	req := httptest.NewRequest(http.MethodPost, "/slack/events", strings.NewReader(body))
+	req.Header.Set("X-Slack-Request-Timestamp", timestamp)
+	req.Header.Set("X-Slack-Signature", "v0="+hex.EncodeToString(mac.Sum(nil)))
+	return req
+}
+
+func TestVerifySlackSignature(t *testing.T) {
+	const secret = "test-secret"
+	var received string
+	handler := VerifySlackSignature(secret, 0)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		received = string(data)
+	}))
+
+	tampered := signedRequest(secret, `{"type":"event_callback"}`, time.Now())
+	tampered.Body = io.NopCloser(strings.NewReader(`{"type":"url_verification"}`))
+	unsigned := signedRequest(secret, `{"type":"event_callback"}`, time.Now())
+	unsigned.Header.Del("X-Slack-Signature")
+	big := strings.Repeat("a", maxSlackBody+1)
+
+	tests := []struct {
+		name string
+		req  *http.Request
+		want int
+	}{
+		{"valid", signedRequest(secret, `{"type":"event_callback"}`, time.Now()), http.StatusOK},
+		{"tampered body", tampered, http.StatusUnauthorized},
+		{"stale timestamp", signedRequest(secret, `{"type":"event_callback"}`, time.Now().Add(-DefaultSignatureMaxSkew-time.Minute)), http.StatusUnauthorized},
+		{"missing signature header", unsigned, http.StatusUnauthorized},
+		{"oversized body", signedRequest(secret, big, time.Now()), http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			received = ""
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, tt.req)
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.want)
+			}
+			if tt.want == http.StatusOK && received != `{"type":"event_callback"}` {
+				t.Errorf("wrapped handler read %q, want the original body", received)
+			}
+			if tt.want != http.StatusOK && received != "" {
+				t.Error("wrapped handler ran for a rejected request")
+			}
+		})
+	}
+}
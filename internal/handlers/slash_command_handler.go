@@ -1,72 +1,94 @@
 package handlers
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"io"
 	"net/http"
 	"strings"
 	"time"
 
 	"github.com/gemini/go-service-communicator/internal/agent"
+	"github.com/gemini/go-service-communicator/internal/digest"
+	"github.com/gemini/go-service-communicator/internal/queue"
 	"github.com/gemini/go-service-communicator/internal/services/jira"
 	slackclient "github.com/gemini/go-service-communicator/internal/services/slack"
 	"github.com/gemini/go-service-communicator/internal/util"
 	"github.com/slack-go/slack"
 )
 
+// ErrUnsupportedCommand is returned for slash commands the bot doesn't handle.
+var ErrUnsupportedCommand = errors.New("unsupported command")
+
 // SlashCommandHandler handles slash command requests from Slack.
 type SlashCommandHandler struct {
-	slackClient   *slackclient.Client
-	jiraClient    *jira.Client
-	agent         *agent.Processor
-	signingSecret string
+	slackClient *slackclient.Client
+	jiraClient  *jira.Client
+	agent       *agent.Processor
+	jiraQueries jira.QueryMapping
+	jobs        *queue.Pool
+	digests     *digest.Scheduler
 }
 
-// NewSlashCommandHandler creates a new SlashCommandHandler.
-func NewSlashCommandHandler(slackClient *slackclient.Client, jiraClient *jira.Client, agent *agent.Processor, signingSecret string) *SlashCommandHandler {
+// NewSlashCommandHandler creates a new SlashCommandHandler. jiraQueries selects
+// the Jira issues included in each channel's summary. Requests must already be
+// verified by VerifySlackSignature. Commands are processed on jobs.
+func NewSlashCommandHandler(slackClient *slackclient.Client, jiraClient *jira.Client, agent *agent.Processor, jiraQueries jira.QueryMapping, jobs *queue.Pool) *SlashCommandHandler {
 	return &SlashCommandHandler{
-		slackClient:   slackClient,
-		jiraClient:    jiraClient,
-		agent:         agent,
-		signingSecret: signingSecret,
+		slackClient: slackClient,
+		jiraClient:  jiraClient,
+		agent:       agent,
+		jiraQueries: jiraQueries,
+		jobs:        jobs,
 	}
 }
 
+// SetDigests enables "/summary subscribe" and its related subcommands, which
+// store subscriptions in digests. Deliveries should be handed to DeliverDigest.
+func (h *SlashCommandHandler) SetDigests(digests *digest.Scheduler) {
+	h.digests = digests
+}
+
 // HandleCommand handles the slash command.
 func (h *SlashCommandHandler) HandleCommand(w http.ResponseWriter, r *http.Request) {
-	verifier, err := slack.NewSecretsVerifier(r.Header, h.signingSecret)
-	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
-
-	r.Body = io.NopCloser(io.TeeReader(r.Body, &verifier))
 	s, err := slack.SlashCommandParse(r)
 	if err != nil {
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
 
-	if err = verifier.Ensure(); err != nil {
-		w.WriteHeader(http.StatusUnauthorized)
+	if err := h.ProcessCommand(s); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("Unsupported command"))
 		return
 	}
+	w.WriteHeader(http.StatusOK)
+}
 
+// ProcessCommand queues a slash command, whether it arrived over HTTP or Socket
+// Mode. It returns ErrUnsupportedCommand for commands the bot doesn't know.
+func (h *SlashCommandHandler) ProcessCommand(s slack.SlashCommand) error {
 	switch s.Command {
 	case "/summary":
-		// Acknowledge the command immediately to avoid timeouts.
-		w.WriteHeader(http.StatusOK)
-
-		// Run the actual logic in a goroutine to avoid blocking.
-		go h.processSummaryCommand(s.UserID, s.ChannelID, s.Text)
+		// Run the actual logic on the job pool to avoid blocking the acknowledgement.
+		enqueue(h.jobs, h.slackClient, s.ChannelID, queue.Job{
+			Name:        "summary",
+			UserID:      s.UserID,
+			WorkspaceID: s.TeamID,
+			Run:         func(ctx context.Context) { h.processSummaryCommand(ctx, s.UserID, s.ChannelID, s.Text) },
+		})
+		return nil
 
 	default:
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte("Unsupported command"))
+		return ErrUnsupportedCommand
 	}
 }
 
-func (h *SlashCommandHandler) processSummaryCommand(userID, requestChannelID, commandText string) {
+func (h *SlashCommandHandler) processSummaryCommand(ctx context.Context, userID, requestChannelID, commandText string) {
+	if h.processDigestCommand(ctx, userID, requestChannelID, commandText) {
+		return
+	}
+
 	h.slackClient.SendEphemeralMessage(requestChannelID, userID, "Processing your request to summarize the channel...")
 
 	duration := 24 * time.Hour // Default to 24 hours
@@ -82,30 +104,56 @@ func (h *SlashCommandHandler) processSummaryCommand(userID, requestChannelID, co
 	}
 
 	endTime := time.Now()
-	startTime := endTime.Add(-duration)
-	jiraQuery := "status=new"
-
-	rawMessages, err := h.slackClient.GetConversationHistory(requestChannelID, startTime, endTime)
-	if err != nil {
-		// Log the error, and optionally send an error message to the user.
+	summary, rawMessages, err := h.channelSummary(ctx, userID, requestChannelID, endTime.Add(-duration), endTime)
+	switch {
+	case errors.Is(err, errHistoryUnavailable):
 		h.slackClient.SendEphemeralMessage(requestChannelID, userID, "Error: Could not fetch message history for this channel. Make sure I have been invited by using '/invite @<bot-name>'.")
 		return
-	}
-	for i := range rawMessages {
-		rawMessages[i].Channel = requestChannelID
-	}
-
-	jiraIssues, err := h.jiraClient.FetchIssues(jiraQuery)
-	if err != nil {
-		// Log the error.
+	case errors.Is(err, errJiraUnavailable):
 		h.slackClient.SendEphemeralMessage(requestChannelID, userID, "Error: Could not fetch Jira issues.")
 		return
 	}
 
-	summary := h.agent.ConsolidateInfo(userID, rawMessages, jiraIssues)
-
-	// Store the summary for potential follow-up questions in a DM.
-	h.agent.SetLastSummary(userID, requestChannelID, summary, rawMessages)
+	// Keep the summary for follow-up questions in this channel or a DM.
+	summary = h.agent.StartSession(ctx, userID, requestChannelID, summary, rawMessages)
 
 	h.slackClient.SendEphemeralMessage(requestChannelID, userID, summary)
 }
+
+var (
+	errHistoryUnavailable = errors.New("could not fetch message history")
+	errJiraUnavailable    = errors.New("could not fetch Jira issues")
+)
+
+// channelSummary summarizes the messages posted in channelID between start and
+// end together with the Jira issues that changed in the same window. It returns
+// the raw messages for follow-up questions.
+func (h *SlashCommandHandler) channelSummary(ctx context.Context, userID, channelID string, start, end time.Time) (string, []slack.Message, error) {
+	// Show the Jira issues that changed during the same window as the messages,
+	// if the channel is mapped to any.
+	jiraQuery, hasJira := h.jiraQueries.Query(channelID, h.slackClient.GetChannelName(channelID), end.Sub(start))
+
+	history, err := h.slackClient.GetConversationHistory(ctx, channelID, start, end)
+	if err != nil {
+		return "", nil, fmt.Errorf("%w: %v", errHistoryUnavailable, err)
+	}
+	var truncated []string
+	if history.Truncated {
+		truncated = []string{channelID}
+	}
+	rawMessages := history.Messages
+	for i := range rawMessages {
+		rawMessages[i].Channel = channelID
+	}
+	rawMessages = h.slackClient.ExpandThreads(ctx, channelID, rawMessages, start, end)
+
+	var jiraIssues []jira.Issue
+	if hasJira {
+		jiraIssues, err = h.jiraClient.FetchIssues(jiraQuery)
+		if err != nil {
+			return "", nil, fmt.Errorf("%w: %v", errJiraUnavailable, err)
+		}
+	}
+
+	return h.agent.ConsolidateInfo(ctx, userID, rawMessages, truncated, jiraIssues), rawMessages, nil
+}
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"strconv"
+
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+	"github.com/slack-go/slack/socketmode"
+)
+
+// SocketModeRunner receives Slack events, slash commands and interactive payloads
+// over a Socket Mode connection and hands them to the same handlers as the HTTP
+// routes, so the bot can run without a public URL.
+type SocketModeRunner struct {
+	client socketClient
+	// incoming is the client's event channel.
+	incoming     <-chan socketmode.Event
+	events       *SlackEventHandler
+	commands     *SlashCommandHandler
+	interactions *InteractionHandler
+}
+
+// socketClient is the part of *socketmode.Client the runner uses.
+type socketClient interface {
+	RunContext(ctx context.Context) error
+	Ack(req socketmode.Request, payload ...interface{})
+}
+
+// NewSocketModeRunner creates a new SocketModeRunner.
+func NewSocketModeRunner(client *socketmode.Client, events *SlackEventHandler, commands *SlashCommandHandler, interactions *InteractionHandler) *SocketModeRunner {
+	return &SocketModeRunner{
+		client:       client,
+		incoming:     client.Events,
+		events:       events,
+		commands:     commands,
+		interactions: interactions,
+	}
+}
+
+// Run connects to Slack and dispatches requests until ctx is canceled or the
+// connection fails permanently.
+func (s *SocketModeRunner) Run(ctx context.Context) error {
+	go s.dispatch(ctx)
+	return s.client.RunContext(ctx)
+}
+
+func (s *SocketModeRunner) dispatch(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case evt := <-s.incoming:
+			s.handle(evt)
+		}
+	}
+}
+
+// handle acknowledges and processes a single Socket Mode event.
+func (s *SocketModeRunner) handle(evt socketmode.Event) {
+	switch evt.Type {
+	case socketmode.EventTypeConnecting:
+		log.Println("Connecting to Slack with Socket Mode...")
+	case socketmode.EventTypeConnected:
+		log.Println("Connected to Slack with Socket Mode")
+	case socketmode.EventTypeConnectionError, socketmode.EventTypeInvalidAuth:
+		log.Printf("Socket Mode connection error: %v", evt.Data)
+
+	case socketmode.EventTypeEventsAPI:
+		event, ok := evt.Data.(slackevents.EventsAPIEvent)
+		if !ok || evt.Request == nil {
+			return
+		}
+		// Acknowledge the event immediately to prevent Slack from retrying.
+		s.client.Ack(*evt.Request)
+
+		retryNum := ""
+		if evt.Request.RetryAttempt > 0 {
+			retryNum = strconv.Itoa(evt.Request.RetryAttempt)
+		}
+		s.events.ProcessEvent(event, retryNum, evt.Request.RetryReason)
+
+	case socketmode.EventTypeSlashCommand:
+		cmd, ok := evt.Data.(slack.SlashCommand)
+		if !ok || evt.Request == nil {
+			return
+		}
+		if err := s.commands.ProcessCommand(cmd); err != nil {
+			s.client.Ack(*evt.Request, map[string]string{"text": "Unsupported command"})
+			return
+		}
+		s.client.Ack(*evt.Request)
+
+	case socketmode.EventTypeInteractive:
+		callback, ok := evt.Data.(slack.InteractionCallback)
+		if !ok || evt.Request == nil {
+			return
+		}
+		if response := s.interactions.ProcessInteraction(callback); response != nil {
+			s.client.Ack(*evt.Request, response)
+			return
+		}
+		s.client.Ack(*evt.Request)
+	}
+}
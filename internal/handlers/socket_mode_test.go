@@ -0,0 +1,234 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gemini/go-service-communicator/internal/agent"
+	"github.com/gemini/go-service-communicator/internal/queue"
+	"github.com/gemini/go-service-communicator/internal/services/jira"
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+	"github.com/slack-go/slack/socketmode"
+)
+
+// socketAck is an acknowledgement sent through fakeSocketClient.
+type socketAck struct {
+	envelopeID string
+	payload    interface{}
+}
+
+// fakeSocketClient stands in for the Socket Mode connection: RunContext blocks
+// until ctx is cancelled and acknowledgements are recorded.
+type fakeSocketClient struct {
+	mu   sync.Mutex
+	acks []socketAck
+}
+
+func (f *fakeSocketClient) RunContext(ctx context.Context) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (f *fakeSocketClient) Ack(req socketmode.Request, payload ...interface{}) {
+	var pld interface{}
+	if len(payload) > 0 {
+		pld = payload[0]
+	}
+	f.mu.Lock()
+	f.acks = append(f.acks, socketAck{req.EnvelopeID, pld})
+	f.mu.Unlock()
+}
+
+func (f *fakeSocketClient) acked() []socketAck {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]socketAck(nil), f.acks...)
+}
+
+// waitFor polls cond until it holds or the test times out.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// posted reports whether a post to user contains text.
+func (ws *fakeWorkspace) posted(user, text string) bool {
+	for _, post := range ws.posts() {
+		if post.Form.Get("user") == user && strings.Contains(content(post), text) {
+			return true
+		}
+	}
+	return false
+}
+
+func newTestRunner(t *testing.T) (*SocketModeRunner, *fakeSocketClient, chan socketmode.Event, *fakeWorkspace) {
+	t.Helper()
+	client, ws := newFakeWorkspace(t)
+	// Closing the pool waits for running jobs, so none outlive the fake workspace.
+	jobs := queue.New(context.Background(), queue.Config{})
+	t.Cleanup(jobs.Close)
+
+	jiraClient := jira.New(jira.Config{})
+	processor := agent.New(unavailableLLM{}, client, jiraClient)
+	socket := &fakeSocketClient{}
+	incoming := make(chan socketmode.Event)
+	runner := &SocketModeRunner{
+		client:       socket,
+		incoming:     incoming,
+		events:       NewSlackEventHandler(client, processor, "UBOT", jobs),
+		commands:     NewSlashCommandHandler(client, jiraClient, processor, jira.QueryMapping{}, jobs),
+		interactions: NewInteractionHandler(client, processor, jobs, "PAY", "Task"),
+	}
+	return runner, socket, incoming, ws
+}
+
+func mentionEvent(eventID, user, text string) slackevents.EventsAPIEvent {
+	return slackevents.EventsAPIEvent{
+		Type:       slackevents.CallbackEvent,
+		TeamID:     "T1",
+		Data:       &slackevents.EventsAPICallbackEvent{EventID: eventID},
+		InnerEvent: slackevents.EventsAPIInnerEvent{Type: "app_mention", Data: mention(user, text)},
+	}
+}
+
+func endSessionClick() slack.InteractionCallback {
+	callback := slack.InteractionCallback{Type: slack.InteractionTypeBlockActions}
+	callback.User.ID = "U1"
+	callback.Channel.ID = "C1"
+	callback.ActionCallback.BlockActions = []*slack.BlockAction{{ActionID: agent.ActionEndSession, Value: "no-such-session"}}
+	return callback
+}
+
+func TestSocketModeDispatch(t *testing.T) {
+	request := &socketmode.Request{EnvelopeID: "env-1"}
+	incompleteModal := slack.InteractionCallback{Type: slack.InteractionTypeViewSubmission}
+	incompleteModal.View.CallbackID = callbackCreateIssueModal
+
+	tests := []struct {
+		name  string
+		event socketmode.Event
+		// acked is the expected acknowledgement; nil means none.
+		acked *socketAck
+		// shown is text that the handler must eventually show to U1.
+		shown string
+	}{
+		{
+			name:  "mention",
+			event: socketmode.Event{Type: socketmode.EventTypeEventsAPI, Data: mentionEvent("Ev1", "U1", "what did I miss?"), Request: request},
+			acked: &socketAck{envelopeID: "env-1"},
+			shown: "hunter2",
+		},
+		{
+			name:  "slash command",
+			event: socketmode.Event{Type: socketmode.EventTypeSlashCommand, Data: slack.SlashCommand{Command: "/summary", UserID: "U1", ChannelID: "C1", TeamID: "T1"}, Request: request},
+			acked: &socketAck{envelopeID: "env-1"},
+			shown: "Processing your request",
+		},
+		{
+			name:  "unsupported slash command",
+			event: socketmode.Event{Type: socketmode.EventTypeSlashCommand, Data: slack.SlashCommand{Command: "/weather", UserID: "U1", ChannelID: "C1"}, Request: request},
+			acked: &socketAck{envelopeID: "env-1", payload: map[string]string{"text": "Unsupported command"}},
+		},
+		{
+			name:  "button click",
+			event: socketmode.Event{Type: socketmode.EventTypeInteractive, Data: endSessionClick(), Request: request},
+			acked: &socketAck{envelopeID: "env-1"},
+			shown: "already ended",
+		},
+		{
+			name:  "modal submission with errors",
+			event: socketmode.Event{Type: socketmode.EventTypeInteractive, Data: incompleteModal, Request: request},
+			acked: &socketAck{envelopeID: "env-1", payload: slack.NewErrorsViewSubmissionResponse(map[string]string{
+				"project":    "Please enter a project key.",
+				"issue_type": "Please enter an issue type.",
+				"summary":    "Please enter a summary.",
+			})},
+		},
+		{
+			name:  "connection status",
+			event: socketmode.Event{Type: socketmode.EventTypeConnected},
+		},
+		{
+			name:  "event without a request",
+			event: socketmode.Event{Type: socketmode.EventTypeEventsAPI, Data: mentionEvent("Ev1", "U1", "what did I miss?")},
+		},
+		{
+			name:  "unexpected payload",
+			event: socketmode.Event{Type: socketmode.EventTypeSlashCommand, Data: "not a command", Request: request},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runner, socket, _, ws := newTestRunner(t)
+			runner.handle(tt.event)
+
+			acks := socket.acked()
+			switch {
+			case tt.acked == nil && len(acks) > 0:
+				t.Errorf("acks = %+v, want none", acks)
+			case tt.acked != nil && (len(acks) != 1 || !reflect.DeepEqual(acks[0], *tt.acked)):
+				t.Errorf("acks = %+v, want [%+v]", acks, *tt.acked)
+			}
+			if tt.shown != "" {
+				waitFor(t, "the handler's reply", func() bool { return ws.posted("U1", tt.shown) })
+			}
+		})
+	}
+}
+
+func TestSocketModeAcksRetriesButHandlesThemOnce(t *testing.T) {
+	runner, socket, _, ws := newTestRunner(t)
+	event := mentionEvent("Ev1", "U1", "what did I miss?")
+
+	runner.handle(socketmode.Event{Type: socketmode.EventTypeEventsAPI, Data: event, Request: &socketmode.Request{EnvelopeID: "env-1"}})
+	waitFor(t, "the catch-up", func() bool { return ws.posted("U1", "hunter2") })
+
+	retry := &socketmode.Request{EnvelopeID: "env-2", RetryAttempt: 1, RetryReason: "timeout"}
+	runner.handle(socketmode.Event{Type: socketmode.EventTypeEventsAPI, Data: event, Request: retry})
+
+	if acks := socket.acked(); len(acks) != 2 || acks[1].envelopeID != "env-2" {
+		t.Errorf("acks = %+v, want the retry acknowledged too", acks)
+	}
+	if n := runner.events.jobs.Stats().Submitted; n != 1 {
+		t.Errorf("%d jobs submitted, want the retry dropped", n)
+	}
+}
+
+func TestSocketModeRun(t *testing.T) {
+	runner, socket, incoming, ws := newTestRunner(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- runner.Run(ctx) }()
+
+	incoming <- socketmode.Event{Type: socketmode.EventTypeConnected}
+	incoming <- socketmode.Event{Type: socketmode.EventTypeInteractive, Data: endSessionClick(), Request: &socketmode.Request{EnvelopeID: "env-1"}}
+	waitFor(t, "the button click to be acknowledged", func() bool { return len(socket.acked()) > 0 })
+	if acks := socket.acked(); len(acks) != 1 || acks[0].envelopeID != "env-1" {
+		t.Errorf("acks = %+v, want env-1", acks)
+	}
+	if !ws.posted("U1", "already ended") {
+		t.Error("the button click was not handled")
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Run = %v, want context.Canceled", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after ctx was cancelled")
+	}
+}
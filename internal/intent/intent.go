@@ -0,0 +1,198 @@
+package intent
+
+import (
+	"context"
+	"log"
+	"sync"
+)
+
+// Slots holds the parameters extracted from a request.
+type Slots struct {
+	// Channels are Slack channel IDs referenced by the request.
+	Channels []string `json:"channels,omitempty"`
+	// TimeRange is a duration such as "7d" or "3 days", parsable by util.ParseDuration.
+	TimeRange string `json:"time_range,omitempty"`
+	// User is a Slack user ID the request is about.
+	User string `json:"user,omitempty"`
+	// Scope is ScopeThread when the request is about the thread it was posted in.
+	Scope string `json:"scope,omitempty"`
+}
+
+// ScopeThread limits a request to the thread it was posted in.
+const ScopeThread = "thread"
+
+// Result is the outcome of classifying a message.
+type Result struct {
+	Intent     string  `json:"intent"`
+	Slots      Slots   `json:"slots"`
+	Confidence float64 `json:"confidence"`
+}
+
+// Request describes the message being routed.
+type Request struct {
+	UserID    string
+	ChannelID string
+	Text      string
+	// MessageTS is the timestamp of the message itself, and ThreadTS the parent
+	// timestamp when the message was posted inside a thread.
+	MessageTS string
+	ThreadTS  string
+	// History holds the previous turns of a DM conversation, if any.
+	History []string
+	// DM is true when the message was sent in a direct message with the bot.
+	DM bool
+}
+
+// Handler handles a request that was classified as a particular intent and
+// returns the response to send back to the user.
+type Handler func(ctx context.Context, req Request, slots Slots) string
+
+// Intent describes a capability the bot offers.
+type Intent struct {
+	// Name identifies the intent in classifier output.
+	Name string
+	// Description tells the LLM classifier when to pick this intent.
+	Description string
+	// Keywords are used by the rule-based fallback classifier.
+	Keywords []string
+	// Private marks intents whose answers may contain conversations or issues
+	// only the requester may see. They are never posted publicly.
+	Private bool
+	Handler Handler
+}
+
+// Classifier maps a message to one of the registered intents.
+type Classifier interface {
+	Classify(ctx context.Context, text string, intents []Intent) (Result, error)
+}
+
+// Router holds the registered intents and dispatches requests to their handlers.
+type Router struct {
+	mu            sync.RWMutex
+	intents       []Intent
+	classifier    Classifier
+	fallback      Classifier
+	defaultIntent string
+}
+
+// NewRouter creates a Router that classifies with classifier and falls back to
+// keyword rules when it fails. Messages that match nothing go to defaultIntent.
+// classifier may be nil to use the rules only.
+func NewRouter(classifier Classifier, defaultIntent string) *Router {
+	return &Router{
+		classifier:    classifier,
+		fallback:      NewRuleClassifier(defaultIntent),
+		defaultIntent: defaultIntent,
+	}
+}
+
+// Register adds an intent, replacing any existing intent with the same name.
+func (r *Router) Register(in Intent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range r.intents {
+		if r.intents[i].Name == in.Name {
+			r.intents[i] = in
+			return
+		}
+	}
+	r.intents = append(r.intents, in)
+}
+
+// Classify determines the intent of text. Slots the classifier missed are filled
+// in from the Slack markup in the text.
+func (r *Router) Classify(ctx context.Context, text string) Result {
+	intents := r.snapshot()
+
+	var result Result
+	var err error
+	if r.classifier != nil {
+		result, err = r.classifier.Classify(ctx, text, intents)
+		if err == nil && !r.known(intents, result.Intent) {
+			log.Printf("Classifier returned unknown intent %q; using rules instead", result.Intent)
+			err = errUnknownIntent
+		}
+	}
+	if r.classifier == nil || err != nil {
+		if err != nil {
+			log.Printf("Intent classification failed, falling back to rules: %v", err)
+		}
+		result, _ = r.fallback.Classify(ctx, text, intents)
+	}
+
+	result.Slots = mergeSlots(result.Slots, ExtractSlots(text))
+	log.Printf("Classified message as intent %q (confidence %.2f)", result.Intent, result.Confidence)
+	return result
+}
+
+// Route sends req to the handler of an already classified intent.
+func (r *Router) Route(ctx context.Context, req Request, result Result) string {
+	intents := r.snapshot()
+	for _, in := range intents {
+		if in.Name == result.Intent {
+			return in.Handler(ctx, req, result.Slots)
+		}
+	}
+	for _, in := range intents {
+		if in.Name == r.defaultIntent {
+			return in.Handler(ctx, req, result.Slots)
+		}
+	}
+	return "Sorry, I don't know how to help with that yet."
+}
+
+// Private reports whether answers to the named intent are private. Unknown
+// intents fall back to the default intent, like Route.
+func (r *Router) Private(name string) bool {
+	intents := r.snapshot()
+	for _, in := range intents {
+		if in.Name == name {
+			return in.Private
+		}
+	}
+	for _, in := range intents {
+		if in.Name == r.defaultIntent {
+			return in.Private
+		}
+	}
+	return false
+}
+
+// Dispatch classifies req.Text and routes the request to the matching handler.
+func (r *Router) Dispatch(ctx context.Context, req Request) string {
+	return r.Route(ctx, req, r.Classify(ctx, req.Text))
+}
+
+func (r *Router) snapshot() []Intent {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	intents := make([]Intent, len(r.intents))
+	copy(intents, r.intents)
+	return intents
+}
+
+func (r *Router) known(intents []Intent, name string) bool {
+	for _, in := range intents {
+		if in.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// mergeSlots fills the empty fields of primary with values from secondary.
+func mergeSlots(primary, secondary Slots) Slots {
+	if len(primary.Channels) == 0 {
+		primary.Channels = secondary.Channels
+	}
+	if primary.TimeRange == "" {
+		primary.TimeRange = secondary.TimeRange
+	}
+	if primary.User == "" {
+		primary.User = secondary.User
+	}
+	if primary.Scope == "" {
+		primary.Scope = secondary.Scope
+	}
+	return primary
+}
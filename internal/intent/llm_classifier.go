@@ -0,0 +1,66 @@
+package intent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/gemini/go-service-communicator/internal/llm"
+)
+
+// LLMClassifier asks a language model to classify a message and extract its slots.
+type LLMClassifier struct {
+	provider llm.Provider
+	opts     []llm.Option
+}
+
+// NewLLMClassifier creates a classifier backed by provider. opts are passed on every call.
+func NewLLMClassifier(provider llm.Provider, opts ...llm.Option) *LLMClassifier {
+	return &LLMClassifier{provider: provider, opts: opts}
+}
+
+// Classify asks the model which intent text expresses and returns its structured answer.
+func (c *LLMClassifier) Classify(ctx context.Context, text string, intents []Intent) (Result, error) {
+	var builder strings.Builder
+	builder.WriteString(`You route messages sent to a Slack bot. Decide which of the following intents the user's message expresses.
+Pay attention to negation: "don't summarize this" is NOT a request for a summary.
+
+Intents:
+`)
+	for _, in := range intents {
+		builder.WriteString(fmt.Sprintf("- %s: %s\n", in.Name, in.Description))
+	}
+	builder.WriteString(`
+Reply with only a JSON object of this shape, without markdown:
+{"intent": "<intent name>", "confidence": <0.0-1.0>, "slots": {"channels": ["<channel ID>"], "time_range": "<e.g. 7d, 3 days>", "user": "<user ID>", "scope": "thread"}}
+
+Channels appear in the message as <#C0123456789|name> and users as <@U0123456789>; return only the IDs. Set "scope" to "thread" only when the user refers to the current thread ("this thread"). Omit slots that are not mentioned.
+
+Message: `)
+	builder.WriteString(fmt.Sprintf("%q", text))
+
+	opts := append([]llm.Option{llm.WithTemperature(0)}, c.opts...)
+	response, err := c.provider.Generate(ctx, builder.String(), opts...)
+	if err != nil {
+		return Result{}, err
+	}
+
+	return parseResult(response)
+}
+
+// parseResult decodes the model's JSON answer, tolerating surrounding code fences or prose.
+func parseResult(response string) (Result, error) {
+	start := strings.Index(response, "{")
+	end := strings.LastIndex(response, "}")
+	if start < 0 || end < start {
+		return Result{}, fmt.Errorf("classifier response contains no JSON object: %q", response)
+	}
+
+	var result Result
+	if err := json.Unmarshal([]byte(response[start:end+1]), &result); err != nil {
+		return Result{}, fmt.Errorf("could not decode classifier response: %w", err)
+	}
+	result.Intent = strings.TrimSpace(result.Intent)
+	return result, nil
+}
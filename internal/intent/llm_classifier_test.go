@@ -0,0 +1,92 @@
+package intent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/gemini/go-service-communicator/internal/llm"
+)
+
+// fakeProvider answers every prompt with a fixed response or error.
+type fakeProvider struct {
+	response string
+	err      error
+	prompt   string
+}
+
+func (f *fakeProvider) Generate(ctx context.Context, prompt string, opts ...llm.Option) (string, error) {
+	f.prompt = prompt
+	return f.response, f.err
+}
+
+func TestParseResult(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		want     string
+		wantErr  bool
+	}{
+		{"plain JSON", `{"intent": "summarize", "confidence": 0.9}`, "summarize 0.9 {Channels:[] TimeRange: User: Scope:}", false},
+		{"code fence", "```json\n{\"intent\": \" catch_up \", \"confidence\": 1}\n```", "catch_up 1 {Channels:[] TimeRange: User: Scope:}", false},
+		{"prose around it", `Sure! {"intent": "summarize", "slots": {"channels": ["C1"], "time_range": "7d", "user": "U1", "scope": "thread"}} Hope that helps.`, "summarize 0 {Channels:[C1] TimeRange:7d User:U1 Scope:thread}", false},
+		{"no JSON", "I think they want a summary.", "", true},
+		{"only an opening brace", "{ nope", "", true},
+		{"malformed JSON", `{"intent": summarize}`, "", true},
+		{"wrong types", `{"intent": 3}`, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := parseResult(tt.response)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("result = %+v, want an error", result)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := fmt.Sprintf("%s %v %+v", result.Intent, result.Confidence, result.Slots)
+			if got != tt.want {
+				t.Errorf("result = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRouterFallsBackToRules(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider *fakeProvider
+		want     string
+	}{
+		{"classifier answer", &fakeProvider{response: `{"intent": "catch_up", "confidence": 0.8}`}, "catch_up"},
+		{"provider error", &fakeProvider{err: errors.New("timeout")}, "summarize"},
+		{"not configured", &fakeProvider{err: llm.ErrNotConfigured}, "summarize"},
+		{"unparsable answer", &fakeProvider{response: "summarize, I guess"}, "summarize"},
+		{"unknown intent", &fakeProvider{response: `{"intent": "dance"}`}, "summarize"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewRouter(NewLLMClassifier(tt.provider), "chat")
+			for _, in := range testIntents {
+				r.Register(in)
+			}
+
+			result := r.Classify(context.Background(), "summarize <#C1|general> for 2d")
+			if result.Intent != tt.want {
+				t.Errorf("intent = %q, want %q", result.Intent, tt.want)
+			}
+			// Slots the classifier missed come from the message markup.
+			if fmt.Sprint(result.Slots.Channels) != "[C1]" || result.Slots.TimeRange != "2d" {
+				t.Errorf("slots = %+v, want channel C1 and 2d", result.Slots)
+			}
+			if !strings.Contains(tt.provider.prompt, "- catch_up:") {
+				t.Errorf("prompt doesn't list the intents: %q", tt.provider.prompt)
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package intent
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+)
+
+var errUnknownIntent = errors.New("unknown intent")
+
+var (
+	channelRegex  = regexp.MustCompile(`<#([CG][A-Z0-9]+)(?:\|[^>]*)?>`)
+	userRegex     = regexp.MustCompile(`<@([UW][A-Z0-9]+)(?:\|[^>]*)?>`)
+	durationRegex = regexp.MustCompile(`\b(\d+\s*(?:hour|day|month|year)s?|\d+(?:h|d|m|y))\b`)
+	wordRegex     = regexp.MustCompile(`[a-z0-9']+`)
+	threadRegex   = regexp.MustCompile(`\b(?:this|the|that)\s+thread\b`)
+)
+
+// negations are words that, shortly before a keyword, cancel it
+// ("don't summarize this" is not a summary request).
+var negations = map[string]bool{
+	"don't":   true,
+	"dont":    true,
+	"not":     true,
+	"never":   true,
+	"no":      true,
+	"without": true,
+	"stop":    true,
+}
+
+// negationWindow is how many words before a keyword are checked for a negation.
+const negationWindow = 3
+
+// RuleClassifier is a keyword-based classifier used when the LLM classifier is
+// unavailable. Keywords match whole words and are ignored when negated.
+type RuleClassifier struct {
+	defaultIntent string
+}
+
+// NewRuleClassifier creates a RuleClassifier that returns defaultIntent when no keyword matches.
+func NewRuleClassifier(defaultIntent string) *RuleClassifier {
+	return &RuleClassifier{defaultIntent: defaultIntent}
+}
+
+// Classify returns the first intent, in registration order, with a non-negated keyword in text.
+func (c *RuleClassifier) Classify(ctx context.Context, text string, intents []Intent) (Result, error) {
+	words := wordRegex.FindAllString(strings.ToLower(strings.ReplaceAll(text, "’", "'")), -1)
+
+	for _, in := range intents {
+		for _, keyword := range in.Keywords {
+			if matchesKeyword(words, strings.ToLower(keyword)) {
+				return Result{Intent: in.Name, Slots: ExtractSlots(text), Confidence: 0.5}, nil
+			}
+		}
+	}
+	return Result{Intent: c.defaultIntent, Slots: ExtractSlots(text), Confidence: 0.1}, nil
+}
+
+// matchesKeyword reports whether keyword (one or more words) occurs in words
+// without a negation shortly before it.
+func matchesKeyword(words []string, keyword string) bool {
+	keywordWords := strings.Fields(keyword)
+	if len(keywordWords) == 0 {
+		return false
+	}
+
+	for i := 0; i+len(keywordWords) <= len(words); i++ {
+		match := true
+		for j, kw := range keywordWords {
+			if words[i+j] != kw {
+				match = false
+				break
+			}
+		}
+		if match && !negated(words, i) {
+			return true
+		}
+	}
+	return false
+}
+
+// negated reports whether one of the words just before position i is a negation.
+func negated(words []string, i int) bool {
+	start := i - negationWindow
+	if start < 0 {
+		start = 0
+	}
+	for k := start; k < i; k++ {
+		if negations[words[k]] {
+			return true
+		}
+		// Catches other contractions such as "shouldn't" or "won't".
+		if strings.HasSuffix(words[k], "n't") {
+			return true
+		}
+	}
+	return false
+}
+
+// ExtractSlots pulls channel IDs, a time range and a user ID out of Slack message
+// markup, and notices references to "this thread".
+func ExtractSlots(text string) Slots {
+	var slots Slots
+	for _, m := range channelRegex.FindAllStringSubmatch(text, -1) {
+		slots.Channels = append(slots.Channels, m[1])
+	}
+	if m := userRegex.FindStringSubmatch(text); len(m) == 2 {
+		slots.User = m[1]
+	}
+	slots.TimeRange = durationRegex.FindString(strings.ToLower(text))
+	if threadRegex.MatchString(strings.ToLower(text)) {
+		slots.Scope = ScopeThread
+	}
+	return slots
+}
@@ -0,0 +1,83 @@
+package intent
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+// testIntents are registered in this order, so earlier intents win ties.
+var testIntents = []Intent{
+	{Name: "summarize", Keywords: []string{"summarize", "summary", "recap"}},
+	{Name: "catch_up", Keywords: []string{"what did i miss"}},
+	{Name: "create_issue", Keywords: []string{"create issue", "file a bug"}},
+}
+
+func TestRuleClassifier(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"summarize #general", "summarize"},
+		{"Can you give me a SUMMARY?", "summarize"},
+		{"recap, please", "summarize"},
+		{"don't summarize this", "chat"},
+		{"Don’t summarize this", "chat"},
+		{"please do not summarize", "chat"},
+		{"never ever summarize it", "chat"},
+		{"I shouldn't need a recap", "chat"},
+		{"no recap needed", "chat"},
+		{"not now, maybe later we can summarize", "summarize"},
+		{"don't worry about it, just summarize", "summarize"},
+		{"summaries are great", "chat"},
+		{"resummarize", "chat"},
+		{"hey, what did I miss?", "catch_up"},
+		{"what did you miss", "chat"},
+		{"please file a bug for this", "create_issue"},
+		{"don't file a bug", "chat"},
+		{"create issue and summarize", "summarize"},
+		{"", "chat"},
+	}
+	c := NewRuleClassifier("chat")
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			result, err := c.Classify(context.Background(), tt.text, testIntents)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Intent != tt.want {
+				t.Errorf("intent = %q, want %q", result.Intent, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractSlots(t *testing.T) {
+	tests := []struct {
+		text string
+		want Slots
+	}{
+		{"hello", Slots{}},
+		{"summarize <#C0123ABC|general>", Slots{Channels: []string{"C0123ABC"}}},
+		{"summarize <#C1> and <#G2|secret>", Slots{Channels: []string{"C1", "G2"}}},
+		{"summarize <#D1|dm>", Slots{}},
+		{"what did <@U0123ABC> say", Slots{User: "U0123ABC"}},
+		{"what did <@W1|bob> and <@U2> say", Slots{User: "W1"}},
+		{"last 3 days", Slots{TimeRange: "3 days"}},
+		{"the last 7d", Slots{TimeRange: "7d"}},
+		{"past 24 Hours", Slots{TimeRange: "24 hours"}},
+		{"1 month", Slots{TimeRange: "1 month"}},
+		{"in 2024", Slots{}},
+		{"summarize this thread", Slots{Scope: ScopeThread}},
+		{"summarize The  Thread", Slots{Scope: ScopeThread}},
+		{"threads are nice", Slots{}},
+		{"recap <#C1|general> for <@U1> over 2d in this thread", Slots{Channels: []string{"C1"}, User: "U1", TimeRange: "2d", Scope: ScopeThread}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			if got := ExtractSlots(tt.text); fmt.Sprintf("%+v", got) != fmt.Sprintf("%+v", tt.want) {
+				t.Errorf("slots = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
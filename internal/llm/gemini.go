@@ -2,45 +2,88 @@ package llm
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"log"
 
 	"github.com/google/generative-ai-go/genai"
 	"google.golang.org/api/option"
 )
 
-// GenerateContent is a simple function that takes an API key and a prompt,
-// and returns the generated content from the Gemini API.
-func GenerateContent(ctx context.Context, apiKey, prompt string) (string, error) {
+// DefaultGeminiModel is used when no model is configured.
+const DefaultGeminiModel = "gemini-pro-latest"
+
+// Gemini is a Provider backed by the Gemini API. It holds a single client
+// that is reused for every call.
+type Gemini struct {
+	client *genai.Client
+	model  string
+}
+
+// NewGemini creates a Gemini provider. An empty or placeholder API key yields a
+// provider whose calls fail with ErrNotConfigured.
+func NewGemini(ctx context.Context, apiKey, model string) (*Gemini, error) {
+	if model == "" {
+		model = DefaultGeminiModel
+	}
 	if apiKey == "YOUR_GEMINI_API_KEY_HERE" || apiKey == "" {
-		return "AI service is not configured. Please add your Gemini API key to config.yaml.", nil
+		return &Gemini{model: model}, nil
 	}
 
 	client, err := genai.NewClient(ctx, option.WithAPIKey(apiKey))
 	if err != nil {
-		// Log the error but return a user-friendly message
-		log.Printf("Failed to create Gemini client: %v", err)
-		return "Sorry, there was an issue connecting to the AI service.", err
+		return nil, err
+	}
+	return &Gemini{client: client, model: model}, nil
+}
+
+// Close releases the underlying Gemini client.
+func (g *Gemini) Close() error {
+	if g.client == nil {
+		return nil
+	}
+	return g.client.Close()
+}
+
+// Generate sends the prompt to Gemini and returns the generated text.
+func (g *Gemini) Generate(ctx context.Context, prompt string, opts ...Option) (string, error) {
+	if g.client == nil {
+		return "", ErrNotConfigured
+	}
+
+	o := ApplyOptions(opts...)
+	modelName := g.model
+	if o.Model != "" {
+		modelName = o.Model
+	}
+
+	model := g.client.GenerativeModel(modelName)
+	if o.Temperature != nil {
+		model.SetTemperature(*o.Temperature)
+	}
+	if o.MaxTokens > 0 {
+		model.SetMaxOutputTokens(int32(o.MaxTokens))
 	}
-	defer client.Close()
 
 	log.Println("---------------------------------")
-	log.Printf("Sending prompt to Gemini:\n%s", prompt)
+	log.Printf("Sending prompt to Gemini (%s):\n%s", modelName, prompt)
 	log.Println("---------------------------------")
 
-
-	model := client.GenerativeModel("gemini-pro-latest") // Using a known stable model
 	resp, err := model.GenerateContent(ctx, genai.Text(prompt))
 	if err != nil {
 		log.Printf("Failed to generate content: %v", err)
-		return "Sorry, I had trouble generating a response.", err
+		return "", err
 	}
 
-	if len(resp.Candidates) == 0 || len(resp.Candidates[0].Content.Parts) == 0 {
+	if len(resp.Candidates) == 0 || resp.Candidates[0].Content == nil || len(resp.Candidates[0].Content.Parts) == 0 {
 		return "I don't have a response for that.", nil
 	}
 
 	var responseText string
 	for _, cand := range resp.Candidates {
+		if cand.Content == nil {
+			continue
+		}
 		for _, part := range cand.Content.Parts {
 			if txt, ok := part.(genai.Text); ok {
 				responseText += string(txt)
@@ -52,6 +95,144 @@ func GenerateContent(ctx context.Context, apiKey, prompt string) (string, error)
 	log.Printf("Received response from Gemini:\n%s", responseText)
 	log.Println("---------------------------------")
 
-
 	return responseText, nil
 }
+
+// Chat runs one turn of a tool-calling conversation with Gemini.
+func (g *Gemini) Chat(ctx context.Context, messages []Message, tools []Tool, opts ...Option) (ChatResponse, error) {
+	if g.client == nil {
+		return ChatResponse{}, ErrNotConfigured
+	}
+
+	o := ApplyOptions(opts...)
+	modelName := g.model
+	if o.Model != "" {
+		modelName = o.Model
+	}
+
+	model := g.client.GenerativeModel(modelName)
+	if o.Temperature != nil {
+		model.SetTemperature(*o.Temperature)
+	}
+	if o.MaxTokens > 0 {
+		model.SetMaxOutputTokens(int32(o.MaxTokens))
+	}
+	if len(tools) > 0 {
+		declarations := make([]*genai.FunctionDeclaration, len(tools))
+		for i, tool := range tools {
+			declarations[i] = &genai.FunctionDeclaration{
+				Name:        tool.Name,
+				Description: tool.Description,
+				Parameters:  toGeminiSchema(tool.Parameters),
+			}
+		}
+		model.Tools = []*genai.Tool{{FunctionDeclarations: declarations}}
+	}
+
+	var contents []*genai.Content
+	for _, msg := range messages {
+		switch msg.Role {
+		case RoleSystem:
+			model.SystemInstruction = genai.NewUserContent(genai.Text(msg.Content))
+		case RoleAssistant:
+			content := &genai.Content{Role: "model"}
+			if msg.Content != "" {
+				content.Parts = append(content.Parts, genai.Text(msg.Content))
+			}
+			for _, call := range msg.ToolCalls {
+				var args map[string]any
+				if len(call.Arguments) > 0 {
+					if err := json.Unmarshal(call.Arguments, &args); err != nil {
+						return ChatResponse{}, fmt.Errorf("invalid arguments for tool call %s: %w", call.Name, err)
+					}
+				}
+				content.Parts = append(content.Parts, genai.FunctionCall{Name: call.Name, Args: args})
+			}
+			contents = append(contents, content)
+		case RoleTool:
+			part := genai.FunctionResponse{Name: msg.Name, Response: map[string]any{"result": msg.Content}}
+			// Responses to calls made in the same turn belong in a single content.
+			if last := len(contents) - 1; last >= 0 && contents[last].Role == "user" && isFunctionResponse(contents[last]) {
+				contents[last].Parts = append(contents[last].Parts, part)
+				continue
+			}
+			contents = append(contents, genai.NewUserContent(part))
+		default:
+			contents = append(contents, genai.NewUserContent(genai.Text(msg.Content)))
+		}
+	}
+	if len(contents) == 0 || contents[len(contents)-1].Role != "user" {
+		return ChatResponse{}, fmt.Errorf("conversation must end with a user or tool message")
+	}
+
+	session := model.StartChat()
+	session.History = contents[:len(contents)-1]
+
+	log.Printf("Sending %d messages with %d tools to Gemini (%s)", len(messages), len(tools), modelName)
+	resp, err := session.SendMessage(ctx, contents[len(contents)-1].Parts...)
+	if err != nil {
+		log.Printf("Failed to generate content: %v", err)
+		return ChatResponse{}, err
+	}
+
+	var result ChatResponse
+	if len(resp.Candidates) == 0 || resp.Candidates[0].Content == nil {
+		return result, nil
+	}
+	for i, part := range resp.Candidates[0].Content.Parts {
+		switch p := part.(type) {
+		case genai.Text:
+			result.Content += string(p)
+		case genai.FunctionCall:
+			args, err := json.Marshal(p.Args)
+			if err != nil {
+				return ChatResponse{}, err
+			}
+			result.ToolCalls = append(result.ToolCalls, ToolCall{ID: fmt.Sprintf("%s-%d", p.Name, i), Name: p.Name, Arguments: args})
+		}
+	}
+	return result, nil
+}
+
+func isFunctionResponse(content *genai.Content) bool {
+	for _, part := range content.Parts {
+		if _, ok := part.(genai.FunctionResponse); !ok {
+			return false
+		}
+	}
+	return len(content.Parts) > 0
+}
+
+// toGeminiSchema converts a Schema into the Gemini representation.
+func toGeminiSchema(s *Schema) *genai.Schema {
+	if s == nil {
+		return nil
+	}
+	out := &genai.Schema{
+		Description: s.Description,
+		Required:    s.Required,
+		Enum:        s.Enum,
+		Items:       toGeminiSchema(s.Items),
+	}
+	switch s.Type {
+	case "object":
+		out.Type = genai.TypeObject
+	case "string":
+		out.Type = genai.TypeString
+	case "integer":
+		out.Type = genai.TypeInteger
+	case "number":
+		out.Type = genai.TypeNumber
+	case "boolean":
+		out.Type = genai.TypeBoolean
+	case "array":
+		out.Type = genai.TypeArray
+	}
+	if len(s.Properties) > 0 {
+		out.Properties = make(map[string]*genai.Schema, len(s.Properties))
+		for name, prop := range s.Properties {
+			out.Properties[name] = toGeminiSchema(prop)
+		}
+	}
+	return out
+}
@@ -0,0 +1,203 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// OpenAI is a Provider that talks to any OpenAI-compatible
+// /v1/chat/completions endpoint (vLLM, llama.cpp server, Ollama, ...).
+type OpenAI struct {
+	baseURL    string
+	apiKey     string
+	model      string
+	httpClient *http.Client
+}
+
+// NewOpenAI creates an OpenAI-compatible provider. baseURL is the API root
+// including the version segment, e.g. "http://localhost:11434/v1". apiKey may
+// be empty for servers that don't require authentication.
+func NewOpenAI(baseURL, apiKey, model string, timeout time.Duration) *OpenAI {
+	if timeout <= 0 {
+		timeout = 120 * time.Second
+	}
+	return &OpenAI{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		apiKey:     apiKey,
+		model:      model,
+		httpClient: &http.Client{Timeout: timeout},
+	}
+}
+
+type chatMessage struct {
+	Role       string           `json:"role"`
+	Content    string           `json:"content"`
+	ToolCalls  []openAIToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string           `json:"tool_call_id,omitempty"`
+	Name       string           `json:"name,omitempty"`
+}
+
+type openAIToolCall struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Function struct {
+		Name string `json:"name"`
+		// Arguments is a JSON object encoded as a string.
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+type openAITool struct {
+	Type     string `json:"type"`
+	Function struct {
+		Name        string  `json:"name"`
+		Description string  `json:"description,omitempty"`
+		Parameters  *Schema `json:"parameters,omitempty"`
+	} `json:"function"`
+}
+
+type chatCompletionRequest struct {
+	Model       string        `json:"model"`
+	Messages    []chatMessage `json:"messages"`
+	Tools       []openAITool  `json:"tools,omitempty"`
+	Temperature *float32      `json:"temperature,omitempty"`
+	MaxTokens   int           `json:"max_tokens,omitempty"`
+}
+
+type chatCompletionResponse struct {
+	Choices []struct {
+		Message chatMessage `json:"message"`
+	} `json:"choices"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+// Generate sends the prompt as a single user message and returns the reply.
+func (o *OpenAI) Generate(ctx context.Context, prompt string, opts ...Option) (string, error) {
+	options := ApplyOptions(opts...)
+	model := o.model
+	if options.Model != "" {
+		model = options.Model
+	}
+
+	reqBody := chatCompletionRequest{
+		Model:       model,
+		Messages:    []chatMessage{{Role: "user", Content: prompt}},
+		Temperature: options.Temperature,
+		MaxTokens:   options.MaxTokens,
+	}
+
+	var resp chatCompletionResponse
+	if err := o.post(ctx, "/chat/completions", reqBody, &resp); err != nil {
+		log.Printf("Failed to generate content: %v", err)
+		return "", err
+	}
+
+	if resp.Error != nil {
+		return "", fmt.Errorf("chat completion failed: %s", resp.Error.Message)
+	}
+	if len(resp.Choices) == 0 {
+		return "I don't have a response for that.", nil
+	}
+	return resp.Choices[0].Message.Content, nil
+}
+
+// Chat runs one turn of a tool-calling conversation.
+func (o *OpenAI) Chat(ctx context.Context, messages []Message, tools []Tool, opts ...Option) (ChatResponse, error) {
+	options := ApplyOptions(opts...)
+	model := o.model
+	if options.Model != "" {
+		model = options.Model
+	}
+
+	reqBody := chatCompletionRequest{
+		Model:       model,
+		Temperature: options.Temperature,
+		MaxTokens:   options.MaxTokens,
+	}
+	for _, msg := range messages {
+		cm := chatMessage{Role: msg.Role, Content: msg.Content, ToolCallID: msg.ToolCallID, Name: msg.Name}
+		for _, call := range msg.ToolCalls {
+			var tc openAIToolCall
+			tc.ID = call.ID
+			tc.Type = "function"
+			tc.Function.Name = call.Name
+			tc.Function.Arguments = string(call.Arguments)
+			cm.ToolCalls = append(cm.ToolCalls, tc)
+		}
+		reqBody.Messages = append(reqBody.Messages, cm)
+	}
+	for _, tool := range tools {
+		var t openAITool
+		t.Type = "function"
+		t.Function.Name = tool.Name
+		t.Function.Description = tool.Description
+		t.Function.Parameters = tool.Parameters
+		reqBody.Tools = append(reqBody.Tools, t)
+	}
+
+	var resp chatCompletionResponse
+	if err := o.post(ctx, "/chat/completions", reqBody, &resp); err != nil {
+		log.Printf("Failed to generate content: %v", err)
+		return ChatResponse{}, err
+	}
+	if resp.Error != nil {
+		return ChatResponse{}, fmt.Errorf("chat completion failed: %s", resp.Error.Message)
+	}
+	if len(resp.Choices) == 0 {
+		return ChatResponse{}, nil
+	}
+
+	reply := resp.Choices[0].Message
+	result := ChatResponse{Content: reply.Content}
+	for _, call := range reply.ToolCalls {
+		args := json.RawMessage(call.Function.Arguments)
+		if len(args) == 0 {
+			args = json.RawMessage("{}")
+		}
+		result.ToolCalls = append(result.ToolCalls, ToolCall{ID: call.ID, Name: call.Function.Name, Arguments: args})
+	}
+	return result, nil
+}
+
+// post sends body as JSON to path and decodes the JSON response into out.
+func (o *OpenAI) post(ctx context.Context, path string, body, out interface{}) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Calling OpenAI-compatible API: POST %s%s", o.baseURL, path)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.baseURL+path, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if o.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+o.apiKey)
+	}
+
+	httpResp, err := o.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer httpResp.Body.Close()
+
+	respBody, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		return err
+	}
+	if httpResp.StatusCode < 200 || httpResp.StatusCode >= 300 {
+		return fmt.Errorf("chat completion request failed with status %d: %s", httpResp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+
+	return json.Unmarshal(respBody, out)
+}
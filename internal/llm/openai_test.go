@@ -0,0 +1,199 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestOpenAI starts a fake chat completions server that records the last
+// request and answers with status and body.
+func newTestOpenAI(t *testing.T, apiKey string, status int, body string) (*OpenAI, *http.Request, *chatCompletionRequest) {
+	t.Helper()
+	var got http.Request
+	var gotBody chatCompletionRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = *r
+		data, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(data, &gotBody); err != nil {
+			t.Errorf("request body is not JSON: %s", data)
+		}
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	return NewOpenAI(server.URL+"/v1/", apiKey, "llama3", 0), &got, &gotBody
+}
+
+func TestOpenAIGenerate(t *testing.T) {
+	provider, req, body := newTestOpenAI(t, "sk-test", http.StatusOK, `{"choices":[{"message":{"role":"assistant","content":"Hello!"}}]}`)
+
+	reply, err := provider.Generate(context.Background(), "Say hello", WithModel("mistral"), WithTemperature(0.2), WithMaxTokens(64))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply != "Hello!" {
+		t.Errorf("reply = %q, want Hello!", reply)
+	}
+
+	if req.Method != http.MethodPost || req.URL.Path != "/v1/chat/completions" {
+		t.Errorf("request = %s %s, want POST /v1/chat/completions", req.Method, req.URL.Path)
+	}
+	if got := req.Header.Get("Authorization"); got != "Bearer sk-test" {
+		t.Errorf("Authorization = %q, want the bearer key", got)
+	}
+	if got := req.Header.Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type = %q", got)
+	}
+	if body.Model != "mistral" || body.Temperature == nil || *body.Temperature != 0.2 || body.MaxTokens != 64 {
+		t.Errorf("options = model %q, temperature %v, max tokens %d", body.Model, body.Temperature, body.MaxTokens)
+	}
+	if len(body.Messages) != 1 || body.Messages[0].Role != "user" || body.Messages[0].Content != "Say hello" {
+		t.Errorf("messages = %+v, want the prompt as one user message", body.Messages)
+	}
+	if len(body.Tools) != 0 {
+		t.Errorf("tools = %+v, want none", body.Tools)
+	}
+}
+
+func TestOpenAIDefaults(t *testing.T) {
+	provider, req, body := newTestOpenAI(t, "", http.StatusOK, `{"choices":[{"message":{"content":"ok"}}]}`)
+
+	if _, err := provider.Generate(context.Background(), "hi"); err != nil {
+		t.Fatal(err)
+	}
+	if got := req.Header.Get("Authorization"); got != "" {
+		t.Errorf("Authorization = %q, want none without an API key", got)
+	}
+	if body.Model != "llama3" || body.Temperature != nil || body.MaxTokens != 0 {
+		t.Errorf("options = model %q, temperature %v, max tokens %d; want the defaults", body.Model, body.Temperature, body.MaxTokens)
+	}
+}
+
+func TestOpenAIChat(t *testing.T) {
+	provider, _, body := newTestOpenAI(t, "", http.StatusOK, `{"choices":[{"message":{"role":"assistant","content":"","tool_calls":[
+		{"id":"call_1","type":"function","function":{"name":"search_messages","arguments":"{\"query\":\"retries\"}"}},
+		{"id":"call_2","type":"function","function":{"name":"get_channel_name","arguments":""}}
+	]}}]}`)
+
+	messages := []Message{
+		{Role: RoleSystem, Content: "Be helpful."},
+		{Role: RoleUser, Content: "Who mentioned retries?"},
+		{Role: RoleAssistant, ToolCalls: []ToolCall{{ID: "call_0", Name: "fetch_jira_issues", Arguments: json.RawMessage(`{"jql":"text ~ retries"}`)}}},
+		{Role: RoleTool, Content: "No matching issues.", ToolCallID: "call_0", Name: "fetch_jira_issues"},
+	}
+	tools := []Tool{{
+		Name:        "search_messages",
+		Description: "Search Slack messages.",
+		Parameters:  &Schema{Type: "object", Properties: map[string]*Schema{"query": {Type: "string"}}, Required: []string{"query"}},
+	}}
+
+	resp, err := provider.Chat(context.Background(), messages, tools)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(body.Messages) != 4 {
+		t.Fatalf("messages = %+v, want 4", body.Messages)
+	}
+	assistant := body.Messages[2]
+	if len(assistant.ToolCalls) != 1 || assistant.ToolCalls[0].ID != "call_0" || assistant.ToolCalls[0].Type != "function" ||
+		assistant.ToolCalls[0].Function.Name != "fetch_jira_issues" || assistant.ToolCalls[0].Function.Arguments != `{"jql":"text ~ retries"}` {
+		t.Errorf("assistant message = %+v, want its tool call with string arguments", assistant)
+	}
+	if tool := body.Messages[3]; tool.Role != "tool" || tool.ToolCallID != "call_0" || tool.Name != "fetch_jira_issues" || tool.Content != "No matching issues." {
+		t.Errorf("tool message = %+v", tool)
+	}
+	if len(body.Tools) != 1 || body.Tools[0].Type != "function" || body.Tools[0].Function.Name != "search_messages" ||
+		body.Tools[0].Function.Parameters == nil || body.Tools[0].Function.Parameters.Required[0] != "query" {
+		t.Errorf("tools = %+v", body.Tools)
+	}
+
+	if len(resp.ToolCalls) != 2 {
+		t.Fatalf("tool calls = %+v, want 2", resp.ToolCalls)
+	}
+	if call := resp.ToolCalls[0]; call.ID != "call_1" || call.Name != "search_messages" || string(call.Arguments) != `{"query":"retries"}` {
+		t.Errorf("first call = %+v", call)
+	}
+	if call := resp.ToolCalls[1]; call.Name != "get_channel_name" || string(call.Arguments) != "{}" {
+		t.Errorf("second call = %+v, want empty arguments as {}", call)
+	}
+}
+
+func TestOpenAIErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		wantErr string
+		want    string
+	}{
+		{"server error", http.StatusInternalServerError, "model crashed\n", "status 500: model crashed", ""},
+		{"rate limited", http.StatusTooManyRequests, `{"error":{"message":"slow down"}}`, "status 429", ""},
+		{"unauthorized", http.StatusUnauthorized, `{"error":{"message":"bad key"}}`, "status 401", ""},
+		{"error in a 200 response", http.StatusOK, `{"error":{"message":"context length exceeded"}}`, "chat completion failed: context length exceeded", ""},
+		{"not JSON", http.StatusOK, `<html>`, "invalid character", ""},
+		{"no choices", http.StatusOK, `{"choices":[]}`, "", "I don't have a response for that."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider, _, _ := newTestOpenAI(t, "", tt.status, tt.body)
+
+			reply, err := provider.Generate(context.Background(), "hi")
+			if tt.wantErr == "" {
+				if err != nil || reply != tt.want {
+					t.Errorf("Generate = %q, %v; want %q", reply, err, tt.want)
+				}
+			} else if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Generate err = %v, want one containing %q", err, tt.wantErr)
+			}
+
+			resp, err := provider.Chat(context.Background(), []Message{{Role: RoleUser, Content: "hi"}}, nil)
+			if tt.wantErr == "" {
+				if err != nil || resp.Content != "" || len(resp.ToolCalls) != 0 {
+					t.Errorf("Chat = %+v, %v; want an empty response", resp, err)
+				}
+			} else if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Chat err = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestOpenAITimeout(t *testing.T) {
+	// The handler never answers; release lets it return so the server can close.
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	t.Cleanup(server.Close)
+	t.Cleanup(func() { close(release) })
+
+	t.Run("client timeout", func(t *testing.T) {
+		provider := NewOpenAI(server.URL, "", "llama3", 50*time.Millisecond)
+		start := time.Now()
+		if _, err := provider.Generate(context.Background(), "hi"); err == nil {
+			t.Fatal("expected a timeout error")
+		}
+		if elapsed := time.Since(start); elapsed > 5*time.Second {
+			t.Errorf("Generate took %s, want it to stop after the timeout", elapsed)
+		}
+	})
+
+	t.Run("cancelled context", func(t *testing.T) {
+		provider := NewOpenAI(server.URL, "", "llama3", time.Minute)
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		if _, err := provider.Chat(ctx, []Message{{Role: RoleUser, Content: "hi"}}, nil); err == nil || !strings.Contains(err.Error(), "context deadline exceeded") {
+			t.Errorf("err = %v, want the context deadline", err)
+		}
+	})
+}
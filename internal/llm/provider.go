@@ -0,0 +1,57 @@
+package llm
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrNotConfigured is returned by providers that have no API key, so callers can
+// tell the user how to set one up.
+var ErrNotConfigured = errors.New("llm provider is not configured")
+
+// Provider is implemented by every LLM backend the agent can talk to.
+type Provider interface {
+	// Generate sends a single prompt to the model and returns the generated text.
+	Generate(ctx context.Context, prompt string, opts ...Option) (string, error)
+}
+
+// GenerateOptions holds the per-call settings a Provider should honour.
+// Zero values mean "use the provider default".
+type GenerateOptions struct {
+	Model       string
+	Temperature *float32
+	MaxTokens   int
+}
+
+// Option configures a single Generate call.
+type Option func(*GenerateOptions)
+
+// WithModel overrides the model used for a call.
+func WithModel(model string) Option {
+	return func(o *GenerateOptions) {
+		o.Model = model
+	}
+}
+
+// WithTemperature sets the sampling temperature for a call.
+func WithTemperature(temperature float32) Option {
+	return func(o *GenerateOptions) {
+		o.Temperature = &temperature
+	}
+}
+
+// WithMaxTokens caps the number of tokens the model may generate.
+func WithMaxTokens(maxTokens int) Option {
+	return func(o *GenerateOptions) {
+		o.MaxTokens = maxTokens
+	}
+}
+
+// ApplyOptions folds opts into a GenerateOptions value.
+func ApplyOptions(opts ...Option) GenerateOptions {
+	var o GenerateOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
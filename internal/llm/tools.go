@@ -0,0 +1,62 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// Message roles used in a tool-calling conversation.
+const (
+	RoleSystem    = "system"
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+	RoleTool      = "tool"
+)
+
+// Schema is the JSON-schema subset used to describe tool parameters.
+type Schema struct {
+	// Type is one of "object", "string", "integer", "number", "boolean" or "array".
+	Type        string             `json:"type"`
+	Description string             `json:"description,omitempty"`
+	Properties  map[string]*Schema `json:"properties,omitempty"`
+	Required    []string           `json:"required,omitempty"`
+	Items       *Schema            `json:"items,omitempty"`
+	Enum        []string           `json:"enum,omitempty"`
+}
+
+// Tool describes a function the model may ask the caller to run.
+type Tool struct {
+	Name        string
+	Description string
+	Parameters  *Schema
+}
+
+// ToolCall is a request from the model to run a tool.
+type ToolCall struct {
+	ID        string
+	Name      string
+	Arguments json.RawMessage
+}
+
+// Message is one turn of a tool-calling conversation.
+type Message struct {
+	Role    string
+	Content string
+	// ToolCalls is set on assistant messages that request tool runs.
+	ToolCalls []ToolCall
+	// ToolCallID and Name identify the call a RoleTool message answers.
+	ToolCallID string
+	Name       string
+}
+
+// ChatResponse is the model's reply in a tool-calling conversation. Either
+// ToolCalls is non-empty, or Content holds the final answer.
+type ChatResponse struct {
+	Content   string
+	ToolCalls []ToolCall
+}
+
+// ToolCaller is implemented by providers that support function calling.
+type ToolCaller interface {
+	Chat(ctx context.Context, messages []Message, tools []Tool, opts ...Option) (ChatResponse, error)
+}
@@ -0,0 +1,292 @@
+// Package queue runs background jobs on a bounded pool of workers with
+// per-user and per-workspace concurrency caps.
+package queue
+
+import (
+	"context"
+	"errors"
+	"log"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+const (
+	defaultWorkers   = 4
+	defaultQueueSize = 100
+	defaultPerUser   = 1
+)
+
+var (
+	// ErrQueueFull is returned by Submit when no more jobs can be queued.
+	ErrQueueFull = errors.New("job queue is full")
+	// ErrClosed is returned by Submit after the pool was closed.
+	ErrClosed = errors.New("job queue is closed")
+)
+
+// Config sizes the pool. Zero values use the defaults.
+type Config struct {
+	// Workers is the number of jobs that may run at once. 0 means 4.
+	Workers int
+	// QueueSize is the number of jobs that may wait. 0 means 100.
+	QueueSize int
+	// PerUser caps the running jobs of one user. 0 means 1; negative means no cap.
+	PerUser int
+	// PerWorkspace caps the running jobs of one workspace. 0 or negative means no cap.
+	PerWorkspace int
+}
+
+// Job is a unit of work submitted on behalf of a Slack user.
+type Job struct {
+	// Name describes the job in logs, e.g. "summary".
+	Name        string
+	UserID      string
+	WorkspaceID string
+	Run         func(ctx context.Context)
+
+	enqueued time.Time
+}
+
+// Ticket describes a job that was accepted.
+type Ticket struct {
+	// Position is the number of jobs waiting ahead of this one, plus one, when
+	// the job could not start straight away. It is 0 for jobs that start immediately.
+	Position int
+}
+
+// Stats is a snapshot of the pool for metrics.
+type Stats struct {
+	Workers   int `json:"workers"`
+	Running   int `json:"running"`
+	Queued    int `json:"queued"`
+	QueueSize int `json:"queue_size"`
+	// Submitted, Completed and Rejected count jobs since the pool started.
+	Submitted uint64 `json:"submitted"`
+	Completed uint64 `json:"completed"`
+	Rejected  uint64 `json:"rejected"`
+	// MaxWaitSeconds is how long the oldest queued job has been waiting.
+	MaxWaitSeconds float64 `json:"max_wait_seconds"`
+}
+
+// Pool runs jobs on a fixed number of workers.
+type Pool struct {
+	cfg  Config
+	mu   sync.Mutex
+	cond *sync.Cond
+
+	// ctx is passed to jobs; it is cancelled when the pool is closed.
+	ctx     context.Context
+	cancel  context.CancelFunc
+	closed  bool
+	workers sync.WaitGroup
+
+	queue            []*Job
+	running          int
+	runningUser      map[string]int
+	runningWorkspace map[string]int
+
+	submitted, completed, rejected uint64
+}
+
+// New creates a Pool and starts its workers. Jobs run with a context derived
+// from ctx; when ctx is cancelled the pool is closed as if by Close, without
+// waiting for running jobs.
+func New(ctx context.Context, cfg Config) *Pool {
+	if cfg.Workers <= 0 {
+		cfg.Workers = defaultWorkers
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = defaultQueueSize
+	}
+	if cfg.PerUser == 0 {
+		cfg.PerUser = defaultPerUser
+	}
+
+	p := &Pool{
+		cfg:              cfg,
+		runningUser:      make(map[string]int),
+		runningWorkspace: make(map[string]int),
+	}
+	p.cond = sync.NewCond(&p.mu)
+	p.ctx, p.cancel = context.WithCancel(ctx)
+	context.AfterFunc(p.ctx, p.stop)
+	p.workers.Add(cfg.Workers)
+	for i := 0; i < cfg.Workers; i++ {
+		go p.worker()
+	}
+	return p
+}
+
+// Close stops accepting jobs, drops the ones still waiting, cancels the context
+// of the running ones and waits for them to return.
+func (p *Pool) Close() {
+	p.stop()
+	p.cancel()
+	p.workers.Wait()
+}
+
+// stop marks the pool closed and wakes the workers so that they exit.
+func (p *Pool) stop() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return
+	}
+	p.closed = true
+	if len(p.queue) > 0 {
+		log.Printf("Job queue closed; dropping %d waiting jobs", len(p.queue))
+	}
+	p.queue = nil
+	p.cond.Broadcast()
+}
+
+// Submit queues job. It returns ErrQueueFull when the queue is saturated and
+// ErrClosed once the pool is closed.
+func (p *Pool) Submit(job Job) (Ticket, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return Ticket{}, ErrClosed
+	}
+
+	if len(p.queue) >= p.cfg.QueueSize {
+		p.rejected++
+		log.Printf("Rejected %s job for user %s: queue is full (%d waiting)", job.Name, job.UserID, len(p.queue))
+		return Ticket{}, ErrQueueFull
+	}
+
+	startsNow := p.startsNow(&job)
+
+	job.enqueued = time.Now()
+	p.queue = append(p.queue, &job)
+	p.submitted++
+	p.cond.Broadcast()
+
+	if startsNow {
+		return Ticket{}, nil
+	}
+	log.Printf("Queued %s job for user %s at position %d", job.Name, job.UserID, len(p.queue))
+	return Ticket{Position: len(p.queue)}, nil
+}
+
+// Stats returns a snapshot of the pool.
+func (p *Pool) Stats() Stats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	stats := Stats{
+		Workers:   p.cfg.Workers,
+		Running:   p.running,
+		Queued:    len(p.queue),
+		QueueSize: p.cfg.QueueSize,
+		Submitted: p.submitted,
+		Completed: p.completed,
+		Rejected:  p.rejected,
+	}
+	if len(p.queue) > 0 {
+		stats.MaxWaitSeconds = time.Since(p.queue[0].enqueued).Seconds()
+	}
+	return stats
+}
+
+func (p *Pool) worker() {
+	defer p.workers.Done()
+	for {
+		job := p.next()
+		if job == nil {
+			return
+		}
+		p.run(job)
+
+		p.mu.Lock()
+		p.running--
+		p.runningUser[job.UserID]--
+		if p.runningUser[job.UserID] <= 0 {
+			delete(p.runningUser, job.UserID)
+		}
+		p.runningWorkspace[job.WorkspaceID]--
+		if p.runningWorkspace[job.WorkspaceID] <= 0 {
+			delete(p.runningWorkspace, job.WorkspaceID)
+		}
+		p.completed++
+		// A finished job may unblock a capped user or workspace.
+		p.cond.Broadcast()
+		p.mu.Unlock()
+	}
+}
+
+// next blocks until a queued job is eligible to run and removes it from the
+// queue. It returns nil once the pool is closed.
+func (p *Pool) next() *Job {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for !p.closed {
+		for i, job := range p.queue {
+			if !p.eligible(job) {
+				continue
+			}
+			p.queue = append(p.queue[:i], p.queue[i+1:]...)
+			p.running++
+			p.runningUser[job.UserID]++
+			p.runningWorkspace[job.WorkspaceID]++
+			return job
+		}
+		p.cond.Wait()
+	}
+	return nil
+}
+
+// run executes a job, keeping the worker alive if it panics.
+func (p *Pool) run(job *Job) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Job %s for user %s panicked: %v\n%s", job.Name, job.UserID, r, debug.Stack())
+		}
+	}()
+	log.Printf("Starting %s job for user %s after %s in the queue", job.Name, job.UserID, time.Since(job.enqueued).Round(time.Millisecond))
+	job.Run(p.ctx)
+}
+
+// eligible reports whether job's user and workspace are under their caps.
+func (p *Pool) eligible(job *Job) bool {
+	if p.cfg.PerUser > 0 && p.runningUser[job.UserID] >= p.cfg.PerUser {
+		return false
+	}
+	if p.cfg.PerWorkspace > 0 && p.runningWorkspace[job.WorkspaceID] >= p.cfg.PerWorkspace {
+		return false
+	}
+	return true
+}
+
+// startsNow reports whether job would start right away: it replays how idle
+// workers will pick up the jobs already waiting and checks that a worker is left
+// for job with its user and workspace under their caps.
+func (p *Pool) startsNow(job *Job) bool {
+	idle := p.cfg.Workers - p.running
+	users := make(map[string]int, len(p.runningUser))
+	for k, v := range p.runningUser {
+		users[k] = v
+	}
+	workspaces := make(map[string]int, len(p.runningWorkspace))
+	for k, v := range p.runningWorkspace {
+		workspaces[k] = v
+	}
+
+	fits := func(j *Job) bool {
+		return (p.cfg.PerUser <= 0 || users[j.UserID] < p.cfg.PerUser) &&
+			(p.cfg.PerWorkspace <= 0 || workspaces[j.WorkspaceID] < p.cfg.PerWorkspace)
+	}
+	for _, queued := range p.queue {
+		if idle == 0 {
+			return false
+		}
+		if fits(queued) {
+			idle--
+			users[queued.UserID]++
+			workspaces[queued.WorkspaceID]++
+		}
+	}
+	return idle > 0 && fits(job)
+}
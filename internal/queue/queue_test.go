@@ -0,0 +1,241 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// waitFor polls cond until it holds or the test times out.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// blocker returns a job run function that blocks until release is closed.
+func blocker(release chan struct{}) func(context.Context) {
+	return func(ctx context.Context) { <-release }
+}
+
+// Positions depend on how quickly workers pick up jobs, but whether a job
+// starts right away does not.
+func TestSubmitStartsNow(t *testing.T) {
+	p := New(context.Background(), Config{Workers: 2, PerWorkspace: 2})
+	release := make(chan struct{})
+	t.Cleanup(func() { close(release); p.Close() })
+
+	tests := []struct {
+		name      string
+		user      string
+		workspace string
+		waits     bool
+	}{
+		{"idle pool", "U1", "T1", false},
+		{"user at their cap waits", "U1", "T1", true},
+		{"another user takes the idle worker past a capped job", "U2", "T1", false},
+		{"no idle worker", "U3", "T2", true},
+	}
+	for _, tt := range tests {
+		ticket, err := p.Submit(Job{Name: tt.name, UserID: tt.user, WorkspaceID: tt.workspace, Run: blocker(release)})
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if waits := ticket.Position > 0; waits != tt.waits {
+			t.Errorf("%s: position = %d, want waiting %v", tt.name, ticket.Position, tt.waits)
+		}
+	}
+	waitFor(t, "two running jobs", func() bool { return p.Stats().Running == 2 })
+	if stats := p.Stats(); stats.Queued != 2 || stats.Submitted != 4 {
+		t.Errorf("stats = %+v, want 2 queued of 4 submitted", stats)
+	}
+}
+
+func TestSubmitStartsNowWorkspaceCap(t *testing.T) {
+	p := New(context.Background(), Config{Workers: 3, PerWorkspace: 1})
+	release := make(chan struct{})
+	t.Cleanup(func() { close(release); p.Close() })
+
+	for _, tt := range []struct {
+		user, workspace string
+		waits           bool
+	}{
+		{"U1", "T1", false},
+		{"U2", "T1", true},
+		{"U3", "T2", false},
+		{"U4", "T3", false},
+		{"U5", "T4", true},
+	} {
+		ticket, err := p.Submit(Job{Name: "summary", UserID: tt.user, WorkspaceID: tt.workspace, Run: blocker(release)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if waits := ticket.Position > 0; waits != tt.waits {
+			t.Errorf("%s in %s: position = %d, want waiting %v", tt.user, tt.workspace, ticket.Position, tt.waits)
+		}
+	}
+}
+
+func TestCaps(t *testing.T) {
+	type job struct{ user, workspace string }
+	tests := []struct {
+		name          string
+		cfg           Config
+		jobs          []job
+		wantUser      int
+		wantWorkspace int
+	}{
+		{
+			name:          "one job per user by default",
+			cfg:           Config{Workers: 4},
+			jobs:          []job{{"U1", "T1"}, {"U1", "T1"}, {"U1", "T1"}, {"U2", "T1"}},
+			wantUser:      1,
+			wantWorkspace: 2,
+		},
+		{
+			name:          "per-user cap",
+			cfg:           Config{Workers: 4, PerUser: 2},
+			jobs:          []job{{"U1", "T1"}, {"U1", "T1"}, {"U1", "T1"}, {"U1", "T1"}},
+			wantUser:      2,
+			wantWorkspace: 2,
+		},
+		{
+			name:          "per-workspace cap",
+			cfg:           Config{Workers: 4, PerWorkspace: 2},
+			jobs:          []job{{"U1", "T1"}, {"U2", "T1"}, {"U3", "T1"}, {"U4", "T1"}, {"U5", "T2"}},
+			wantUser:      1,
+			wantWorkspace: 2,
+		},
+		{
+			name:          "no caps",
+			cfg:           Config{Workers: 3, PerUser: -1},
+			jobs:          []job{{"U1", "T1"}, {"U1", "T1"}, {"U1", "T1"}},
+			wantUser:      3,
+			wantWorkspace: 3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := New(context.Background(), tt.cfg)
+			defer p.Close()
+
+			var mu sync.Mutex
+			users, workspaces := make(map[string]int), make(map[string]int)
+			maxUser, maxWorkspace := 0, 0
+			var wg sync.WaitGroup
+			for _, j := range tt.jobs {
+				j := j
+				wg.Add(1)
+				_, err := p.Submit(Job{Name: "summary", UserID: j.user, WorkspaceID: j.workspace, Run: func(ctx context.Context) {
+					defer wg.Done()
+					mu.Lock()
+					users[j.user]++
+					workspaces[j.workspace]++
+					maxUser = max(maxUser, users[j.user])
+					maxWorkspace = max(maxWorkspace, workspaces[j.workspace])
+					mu.Unlock()
+
+					time.Sleep(20 * time.Millisecond)
+
+					mu.Lock()
+					users[j.user]--
+					workspaces[j.workspace]--
+					mu.Unlock()
+				}})
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			wg.Wait()
+
+			if maxUser != tt.wantUser || maxWorkspace != tt.wantWorkspace {
+				t.Errorf("most concurrent jobs per user = %d, per workspace = %d; want %d and %d", maxUser, maxWorkspace, tt.wantUser, tt.wantWorkspace)
+			}
+			waitFor(t, "all jobs to complete", func() bool { return p.Stats().Completed == uint64(len(tt.jobs)) })
+		})
+	}
+}
+
+func TestQueueFull(t *testing.T) {
+	p := New(context.Background(), Config{Workers: 1, QueueSize: 2, PerUser: -1})
+	release := make(chan struct{})
+	t.Cleanup(func() { close(release); p.Close() })
+
+	if _, err := p.Submit(Job{Name: "running", UserID: "U1", Run: blocker(release)}); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "the first job to start", func() bool { return p.Stats().Running == 1 })
+	for i := 0; i < 2; i++ {
+		if _, err := p.Submit(Job{Name: "waiting", UserID: "U1", Run: blocker(release)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := p.Submit(Job{Name: "rejected", UserID: "U2", Run: blocker(release)}); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("err = %v, want ErrQueueFull", err)
+	}
+	if stats := p.Stats(); stats.Rejected != 1 || stats.Queued != 2 || stats.Submitted != 3 {
+		t.Errorf("stats = %+v, want 1 rejected, 2 queued and 3 submitted", stats)
+	}
+}
+
+func TestPanickingJobKeepsWorker(t *testing.T) {
+	p := New(context.Background(), Config{Workers: 1})
+	defer p.Close()
+
+	done := make(chan struct{})
+	p.Submit(Job{Name: "panics", UserID: "U1", Run: func(ctx context.Context) { panic("boom") }})
+	p.Submit(Job{Name: "next", UserID: "U1", Run: func(ctx context.Context) { close(done) }})
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the job after a panic never ran")
+	}
+}
+
+func TestClose(t *testing.T) {
+	p := New(context.Background(), Config{Workers: 1})
+
+	started, cancelled := make(chan struct{}), make(chan struct{})
+	p.Submit(Job{Name: "running", UserID: "U1", Run: func(ctx context.Context) {
+		close(started)
+		<-ctx.Done()
+		close(cancelled)
+	}})
+	ran := false
+	p.Submit(Job{Name: "waiting", UserID: "U2", Run: func(ctx context.Context) { ran = true }})
+	<-started
+
+	p.Close()
+	select {
+	case <-cancelled:
+	default:
+		t.Fatal("Close returned before the running job saw its context cancelled")
+	}
+	if ran {
+		t.Error("a waiting job ran after Close")
+	}
+	if _, err := p.Submit(Job{Name: "late", UserID: "U1", Run: func(ctx context.Context) {}}); !errors.Is(err, ErrClosed) {
+		t.Errorf("err = %v, want ErrClosed", err)
+	}
+	p.Close() // Closing twice is harmless.
+}
+
+func TestParentContextClosesPool(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	p := New(ctx, Config{})
+	cancel()
+
+	waitFor(t, "the pool to close", func() bool {
+		_, err := p.Submit(Job{Name: "late", UserID: "U1", Run: func(ctx context.Context) {}})
+		return errors.Is(err, ErrClosed)
+	})
+	p.Close()
+}
@@ -0,0 +1,255 @@
+package jira
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// WebhookRule routes matching webhook events to a Slack channel. Empty lists
+// match anything; values are compared case-insensitively.
+type WebhookRule struct {
+	// Channel is the Slack channel ID or name that receives the events.
+	Channel    string
+	Events     []string
+	Projects   []string
+	IssueTypes []string
+	Priorities []string
+	// Filter is a JQL-like expression of clauses joined by AND, e.g.
+	// `status != Done AND labels in (incident, outage) AND summary ~ "payment"`.
+	// Supported operators are =, !=, ~, !~, in, not in, is EMPTY and is not EMPTY
+	// on the fields event, project, issuetype, priority, status, assignee,
+	// reporter, labels and summary.
+	Filter string
+}
+
+// WebhookRouter decides which channels receive a webhook event.
+type WebhookRouter struct {
+	rules   []WebhookRule
+	filters []filter
+}
+
+// NewWebhookRouter compiles the rules. It fails if a filter can't be parsed.
+func NewWebhookRouter(rules []WebhookRule) (*WebhookRouter, error) {
+	router := &WebhookRouter{rules: rules, filters: make([]filter, len(rules))}
+	for i, rule := range rules {
+		f, err := parseFilter(rule.Filter)
+		if err != nil {
+			return nil, fmt.Errorf("webhook rule for %s: %w", rule.Channel, err)
+		}
+		router.filters[i] = f
+	}
+	return router, nil
+}
+
+// Channels returns the channels whose rules match event, without duplicates,
+// in rule order.
+func (r *WebhookRouter) Channels(event *WebhookEvent) []string {
+	var channels []string
+	seen := make(map[string]bool)
+	for i, rule := range r.rules {
+		if seen[rule.Channel] {
+			continue
+		}
+		if !anyEqual(rule.Events, event.Type) ||
+			!anyEqual(rule.Projects, event.Issue.Project) ||
+			!anyEqual(rule.IssueTypes, event.Issue.IssueType) ||
+			!anyEqual(rule.Priorities, event.Issue.Priority) ||
+			!r.filters[i].match(event) {
+			continue
+		}
+		seen[rule.Channel] = true
+		channels = append(channels, rule.Channel)
+	}
+	return channels
+}
+
+// anyEqual reports whether value is in allowed, or allowed is empty.
+func anyEqual(allowed []string, value string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	for _, a := range allowed {
+		if strings.EqualFold(strings.TrimPrefix(a, "jira:"), value) {
+			return true
+		}
+	}
+	return false
+}
+
+// filter is a conjunction of clauses.
+type filter []clause
+
+type clause struct {
+	field  string
+	op     string
+	values []string
+}
+
+var clausePattern = regexp.MustCompile(`(?is)^([a-z]+)\s*(!=|!~|=|~|not\s+in\b|in\b|is\s+not\b|is\b)\s*(.*)$`)
+
+var filterFields = map[string]bool{
+	"event": true, "project": true, "issuetype": true, "type": true, "priority": true,
+	"status": true, "assignee": true, "reporter": true, "labels": true, "summary": true,
+}
+
+// parseFilter parses a JQL-like filter expression. An empty expression matches everything.
+func parseFilter(expr string) (filter, error) {
+	var f filter
+	for _, part := range splitAnd(expr) {
+		if part == "" {
+			return nil, fmt.Errorf("empty clause next to AND in %q", expr)
+		}
+		m := clausePattern.FindStringSubmatch(part)
+		if m == nil {
+			return nil, fmt.Errorf("invalid filter clause %q", part)
+		}
+		c := clause{
+			field: strings.ToLower(m[1]),
+			op:    strings.ToLower(strings.Join(strings.Fields(m[2]), " ")),
+		}
+		if !filterFields[c.field] {
+			return nil, fmt.Errorf("unknown filter field %q", m[1])
+		}
+
+		value := strings.TrimSpace(m[3])
+		switch c.op {
+		case "in", "not in":
+			if !strings.HasPrefix(value, "(") || !strings.HasSuffix(value, ")") {
+				return nil, fmt.Errorf("%s needs a parenthesized list in %q", c.op, part)
+			}
+			for _, v := range strings.Split(value[1:len(value)-1], ",") {
+				c.values = append(c.values, unquote(v))
+			}
+		case "is", "is not":
+			if !strings.EqualFold(value, "empty") && !strings.EqualFold(value, "null") {
+				return nil, fmt.Errorf("%s only supports EMPTY in %q", c.op, part)
+			}
+		default:
+			if value == "" {
+				return nil, fmt.Errorf("missing value in %q", part)
+			}
+			c.values = []string{unquote(value)}
+		}
+		f = append(f, c)
+	}
+	return f, nil
+}
+
+// splitAnd splits expr on the AND keyword outside of quotes and parentheses.
+// A dangling or repeated AND yields an empty part.
+func splitAnd(expr string) []string {
+	var parts []string
+	var quote rune
+	depth, start := 0, 0
+	for i, r := range expr {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == '(':
+			depth++
+		case r == ')':
+			depth--
+		case depth == 0 && (i == 0 || isSpace(expr[i-1])) && i+3 <= len(expr) && strings.EqualFold(expr[i:i+3], "and") && (i+3 == len(expr) || isSpace(expr[i+3])):
+			parts = append(parts, strings.TrimSpace(expr[start:i]))
+			start = i + 3
+		}
+	}
+	if strings.TrimSpace(expr) == "" {
+		return nil
+	}
+	return append(parts, strings.TrimSpace(expr[start:]))
+}
+
+func isSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\n'
+}
+
+func unquote(value string) string {
+	value = strings.TrimSpace(value)
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		return value[1 : len(value)-1]
+	}
+	return value
+}
+
+func (f filter) match(event *WebhookEvent) bool {
+	for _, c := range f {
+		if !c.match(fieldValues(event, c.field)) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c clause) match(actual []string) bool {
+	switch c.op {
+	case "is":
+		return len(actual) == 0
+	case "is not":
+		return len(actual) > 0
+	case "=", "in":
+		return intersects(actual, c.values)
+	case "!=", "not in":
+		return !intersects(actual, c.values)
+	case "~":
+		return containsText(actual, c.values[0])
+	case "!~":
+		return !containsText(actual, c.values[0])
+	}
+	return false
+}
+
+// fieldValues returns the values of a filter field; multi-valued fields such as
+// labels match when any value matches.
+func fieldValues(event *WebhookEvent, field string) []string {
+	var value string
+	switch field {
+	case "labels":
+		return event.Issue.Labels
+	case "event":
+		value = event.Type
+	case "project":
+		value = event.Issue.Project
+	case "issuetype", "type":
+		value = event.Issue.IssueType
+	case "priority":
+		value = event.Issue.Priority
+	case "status":
+		value = event.Issue.Status
+	case "assignee":
+		value = event.Issue.Assignee
+	case "reporter":
+		value = event.Issue.Reporter
+	case "summary":
+		value = event.Issue.Summary
+	}
+	if value == "" {
+		return nil
+	}
+	return []string{value}
+}
+
+func intersects(actual, wanted []string) bool {
+	for _, a := range actual {
+		for _, w := range wanted {
+			if strings.EqualFold(a, w) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func containsText(actual []string, text string) bool {
+	for _, a := range actual {
+		if strings.Contains(strings.ToLower(a), strings.ToLower(text)) {
+			return true
+		}
+	}
+	return false
+}
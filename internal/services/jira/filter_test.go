@@ -0,0 +1,160 @@
+package jira
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func testEvent() *WebhookEvent {
+	return &WebhookEvent{
+		Type: EventIssueUpdated,
+		Issue: WebhookIssue{
+			Key:       "PAY-1",
+			Summary:   "Payment page times out",
+			Project:   "PAY",
+			IssueType: "Bug",
+			Priority:  "High",
+			Status:    "In Progress",
+			Assignee:  "Alice",
+			Labels:    []string{"incident", "checkout"},
+		},
+	}
+}
+
+func TestFilterMatch(t *testing.T) {
+	tests := []struct {
+		filter string
+		want   bool
+	}{
+		{"", true},
+		{"project = PAY", true},
+		{"project = pay", true},
+		{"project = OPS", false},
+		{"project != OPS", true},
+		{"project != PAY", false},
+		{"summary ~ payment", true},
+		{"summary ~ refund", false},
+		{"summary !~ refund", true},
+		{"summary !~ PAYMENT", false},
+		{"status in (Open, \"In Progress\")", true},
+		{"status in (Open, Done)", false},
+		{"status not in (Done, Closed)", true},
+		{"status NOT IN (Done, 'In Progress')", false},
+		{"labels = incident", true},
+		{"labels in (outage, checkout)", true},
+		{"labels not in (incident)", false},
+		{"reporter is EMPTY", true},
+		{"reporter is null", true},
+		{"assignee is EMPTY", false},
+		{"assignee is not EMPTY", true},
+		{"reporter IS NOT empty", false},
+		{"type = Bug", true},
+		{"issuetype = Story", false},
+		{"event = issue_updated", true},
+		{"summary ~ \"times out\"", true},
+		{"summary ~ 'times  out'", false},
+		{"project = PAY AND priority = High", true},
+		{"project = PAY and priority = Low", false},
+		{"project = PAY AND labels in (incident, outage) AND status != Done", true},
+		{"summary ~ \"sand and gravel\"", false},
+		{"status in (Done, \"Review AND QA\") AND project = PAY", false},
+		{"project=PAY AND priority=High", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.filter, func(t *testing.T) {
+			f, err := parseFilter(tt.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := f.match(testEvent()); got != tt.want {
+				t.Errorf("match = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseFilterErrors(t *testing.T) {
+	tests := []struct {
+		filter  string
+		wantErr string
+	}{
+		{"project", "invalid filter clause"},
+		{"project PAY", "invalid filter clause"},
+		{"component = API", "unknown filter field"},
+		{"status in Done", "needs a parenthesized list"},
+		{"status not in (Done", "needs a parenthesized list"},
+		{"assignee is Alice", "only supports EMPTY"},
+		{"summary ~ ", "missing value"},
+		{"project = PAY AND", "empty clause"},
+		{"AND project = PAY", "empty clause"},
+		{"project = PAY AND AND priority = High", "empty clause"},
+		{"= PAY", "invalid filter clause"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.filter, func(t *testing.T) {
+			_, err := parseFilter(tt.filter)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("err = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseFilterClauses(t *testing.T) {
+	f, err := parseFilter(`status NOT  IN ("In Progress", 'Done') and summary ~ "a AND b"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `[{status not in [In Progress Done]} {summary ~ [a AND b]}]`
+	if got := fmt.Sprint(f); got != want {
+		t.Errorf("clauses = %s, want %s", got, want)
+	}
+}
+
+func TestSplitAnd(t *testing.T) {
+	tests := []struct {
+		expr string
+		want []string
+	}{
+		{"", nil},
+		{"a = 1", []string{"a = 1"}},
+		{"a = 1 AND b = 2", []string{"a = 1", "b = 2"}},
+		{"a = 1\tand\nb = 2", []string{"a = 1", "b = 2"}},
+		{"brand = x", []string{"brand = x"}},
+		{"a = \"x AND y\" AND b = 2", []string{"a = \"x AND y\"", "b = 2"}},
+		{"a = 'x and y'", []string{"a = 'x and y'"}},
+		{"a in (x AND y) AND b = 2", []string{"a in (x AND y)", "b = 2"}},
+		{"a = 1 AND  AND b = 2", []string{"a = 1", "", "b = 2"}},
+		{"a = 1 AND", []string{"a = 1", ""}},
+		{"  ", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			if got := splitAnd(tt.expr); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("splitAnd = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWebhookRouterChannels(t *testing.T) {
+	router, err := NewWebhookRouter([]WebhookRule{
+		{Channel: "#incidents", Filter: "labels = incident"},
+		{Channel: "#payments", Projects: []string{"PAY"}, Events: []string{"jira:issue_created"}},
+		{Channel: "#payments", Projects: []string{"PAY"}},
+		{Channel: "#ops", Projects: []string{"OPS"}},
+		{Channel: "#incidents", Priorities: []string{"High"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := router.Channels(testEvent())
+	if want := []string{"#incidents", "#payments"}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("channels = %v, want %v", got, want)
+	}
+
+	if _, err := NewWebhookRouter([]WebhookRule{{Channel: "#x", Filter: "nope"}}); err == nil {
+		t.Error("expected an error for an invalid filter")
+	}
+}
@@ -1,34 +1,506 @@
 package jira
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
 )
 
-// Client is a simple Jira client.
+// Deployment types supported by the client.
+const (
+	DeploymentCloud      = "cloud"
+	DeploymentDataCenter = "datacenter"
+)
+
+const (
+	defaultTimeout   = 30 * time.Second
+	defaultMaxIssues = 200
+	pageSize         = 100
+	// jiraTimeLayout is the timestamp format used by the Jira REST API.
+	jiraTimeLayout = "2006-01-02T15:04:05.000-0700"
+)
+
+// ErrNotConfigured is returned by write operations when no Jira base URL is configured.
+var ErrNotConfigured = errors.New("jira is not configured")
+
+// Config holds the connection settings for a Jira site.
+type Config struct {
+	// BaseURL is the site root, e.g. "https://example.atlassian.net".
+	BaseURL string
+	// Deployment is DeploymentCloud (default) or DeploymentDataCenter.
+	Deployment string
+	// Username and APIToken are used for basic auth. On Jira Cloud the
+	// username is the account email address.
+	Username string
+	APIToken string
+	// BearerToken is a personal access token; it takes precedence over basic auth.
+	BearerToken string
+	// Timeout bounds each HTTP request. 0 means 30 seconds.
+	Timeout time.Duration
+	// MaxIssues caps the number of issues a search returns. 0 means 200.
+	MaxIssues int
+}
+
+// Issue is a Jira issue as returned by a search.
+type Issue struct {
+	Key      string
+	Summary  string
+	Status   string
+	Assignee string
+	Priority string
+	Updated  time.Time
+	// UpdatedBy identifies who made the latest change or comment, in the form
+	// FindUser returns. It is only set by FetchIssueActivity.
+	UpdatedBy string
+}
+
+// String formats the issue as a single line for summaries and LLM prompts.
+func (i Issue) String() string {
+	assignee := i.Assignee
+	if assignee == "" {
+		assignee = "unassigned"
+	}
+	line := fmt.Sprintf("%s: %s [%s, %s, %s]", i.Key, i.Summary, i.Status, i.Priority, assignee)
+	if !i.Updated.IsZero() {
+		line += " (updated " + i.Updated.Format("2006-01-02 15:04") + ")"
+	}
+	return line
+}
+
+// Client is a Jira Cloud and Data Center REST client.
 type Client struct {
-	// In a real application, this would hold Jira API credentials and other relevant data.
+	cfg        Config
+	baseURL    string
+	httpClient *http.Client
 }
 
 // New creates a new Jira client.
-func New() *Client {
-	return &Client{}
+func New(cfg Config) *Client {
+	if cfg.Deployment == "" {
+		cfg.Deployment = DeploymentCloud
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultTimeout
+	}
+	if cfg.MaxIssues <= 0 {
+		cfg.MaxIssues = defaultMaxIssues
+	}
+	return &Client{
+		cfg:        cfg,
+		baseURL:    strings.TrimRight(cfg.BaseURL, "/"),
+		httpClient: &http.Client{Timeout: cfg.Timeout},
+	}
 }
 
-// SendMessage sends a message to Jira (e.g., creates a comment on an issue).
-// This is a placeholder and does not actually interact with Jira.
+// Configured reports whether a Jira site has been configured.
+func (c *Client) Configured() bool {
+	return c.baseURL != ""
+}
+
+// BrowseURL returns the web URL of an issue.
+func (c *Client) BrowseURL(issueKey string) string {
+	return c.baseURL + "/browse/" + issueKey
+}
+
+// SendMessage adds a comment to a Jira issue.
 func (c *Client) SendMessage(issueKey, comment string) error {
-	fmt.Printf("Adding comment to Jira issue %s: %s\n", issueKey, comment)
-	// Here you would use the Jira API to add a comment to an issue.
-	return nil
+	return c.AddComment(context.Background(), issueKey, comment)
+}
+
+// AddComment adds a plain-text comment to an issue.
+func (c *Client) AddComment(ctx context.Context, issueKey, comment string) error {
+	if !c.Configured() {
+		return ErrNotConfigured
+	}
+	log.Printf("Calling Jira API: add comment to %s", issueKey)
+
+	var body interface{}
+	if c.isCloud() {
+		body = map[string]interface{}{"body": toADF(comment)}
+	} else {
+		body = map[string]string{"body": comment}
+	}
+	return c.do(ctx, http.MethodPost, c.apiPath("/issue/"+url.PathEscape(issueKey)+"/comment"), nil, body, nil)
+}
+
+// FindUser returns the identifier JQL uses for the user with the given email
+// address: the account ID on Cloud and the username on Data Center. It returns
+// "" if there is no such user or Jira is not configured.
+func (c *Client) FindUser(ctx context.Context, email string) (string, error) {
+	if !c.Configured() || email == "" {
+		return "", nil
+	}
+	log.Printf("Calling Jira API: user search for %s", email)
+
+	query := url.Values{}
+	if c.isCloud() {
+		query.Set("query", email)
+	} else {
+		query.Set("username", email)
+	}
+	var users []struct {
+		AccountID string `json:"accountId"`
+		Name      string `json:"name"`
+	}
+	if err := c.do(ctx, http.MethodGet, c.apiPath("/user/search"), query, nil, &users); err != nil {
+		return "", err
+	}
+	if len(users) == 0 {
+		return "", nil
+	}
+	if c.isCloud() {
+		return users[0].AccountID, nil
+	}
+	return users[0].Name, nil
+}
+
+// IssueInput holds the fields needed to create an issue.
+type IssueInput struct {
+	ProjectKey  string
+	IssueType   string
+	Summary     string
+	Description string
+}
+
+// CreateIssue creates an issue and returns its key.
+func (c *Client) CreateIssue(ctx context.Context, input IssueInput) (string, error) {
+	if !c.Configured() {
+		return "", ErrNotConfigured
+	}
+	log.Printf("Calling Jira API: create %s in project %s", input.IssueType, input.ProjectKey)
+
+	fields := map[string]interface{}{
+		"project":   map[string]string{"key": input.ProjectKey},
+		"issuetype": map[string]string{"name": input.IssueType},
+		"summary":   input.Summary,
+	}
+	if c.isCloud() {
+		fields["description"] = toADF(input.Description)
+	} else {
+		fields["description"] = input.Description
+	}
+
+	var created struct {
+		Key string `json:"key"`
+	}
+	if err := c.do(ctx, http.MethodPost, c.apiPath("/issue"), nil, map[string]interface{}{"fields": fields}, &created); err != nil {
+		return "", err
+	}
+	return created.Key, nil
+}
+
+// FetchIssues fetches issues matching a JQL query.
+func (c *Client) FetchIssues(jql string) ([]Issue, error) {
+	return c.FetchIssuesContext(context.Background(), jql)
+}
+
+// FetchIssuesContext fetches issues matching a JQL query, following pagination up to
+// the configured maximum. An unconfigured client returns no issues.
+func (c *Client) FetchIssuesContext(ctx context.Context, jql string) ([]Issue, error) {
+	if !c.Configured() {
+		log.Printf("Jira is not configured; skipping search for %q", jql)
+		return nil, nil
+	}
+	log.Printf("Calling Jira API: search with JQL %q", jql)
+
+	if c.isCloud() {
+		return c.searchCloud(ctx, jql, searchFields, "")
+	}
+	return c.searchDataCenter(ctx, jql, searchFields, "")
+}
+
+// FetchIssueActivity is like FetchIssuesContext, but also reads each issue's
+// change history and comments to fill in Issue.UpdatedBy.
+func (c *Client) FetchIssueActivity(ctx context.Context, jql string) ([]Issue, error) {
+	if !c.Configured() {
+		log.Printf("Jira is not configured; skipping search for %q", jql)
+		return nil, nil
+	}
+	log.Printf("Calling Jira API: search with changelog, JQL %q", jql)
+
+	if c.isCloud() {
+		return c.searchCloud(ctx, jql, searchFields+",comment", "changelog")
+	}
+	return c.searchDataCenter(ctx, jql, searchFields+",comment", "changelog")
 }
 
-// FetchIssues fetches issues from Jira.
-// This is a placeholder and returns mock data.
-func (c *Client) FetchIssues(query string) ([]string, error) {
-	fmt.Printf("Fetching Jira issues with query: %s\n", query)
-	return []string{
-		"PROJ-123: Implement the new feature",
-		"PROJ-456: Fix the bug in the login page",
-	}, nil
+// jiraUser is a user as embedded in issues: accountId on Cloud, name on Data Center.
+type jiraUser struct {
+	AccountID string `json:"accountId"`
+	Name      string `json:"name"`
 }
 
+func (u *jiraUser) id() string {
+	if u == nil {
+		return ""
+	}
+	if u.AccountID != "" {
+		return u.AccountID
+	}
+	return u.Name
+}
+
+type searchIssue struct {
+	Key       string `json:"key"`
+	Changelog *struct {
+		Histories []struct {
+			Author  *jiraUser `json:"author"`
+			Created string    `json:"created"`
+		} `json:"histories"`
+	} `json:"changelog"`
+	Fields struct {
+		Summary string `json:"summary"`
+		Status  *struct {
+			Name string `json:"name"`
+		} `json:"status"`
+		Assignee *struct {
+			DisplayName string `json:"displayName"`
+		} `json:"assignee"`
+		Priority *struct {
+			Name string `json:"name"`
+		} `json:"priority"`
+		Updated string `json:"updated"`
+		Comment *struct {
+			Comments []struct {
+				UpdateAuthor *jiraUser `json:"updateAuthor"`
+				Updated      string    `json:"updated"`
+			} `json:"comments"`
+		} `json:"comment"`
+	} `json:"fields"`
+}
+
+func (si searchIssue) toIssue() Issue {
+	issue := Issue{Key: si.Key, Summary: si.Fields.Summary}
+	if si.Fields.Status != nil {
+		issue.Status = si.Fields.Status.Name
+	}
+	if si.Fields.Assignee != nil {
+		issue.Assignee = si.Fields.Assignee.DisplayName
+	}
+	if si.Fields.Priority != nil {
+		issue.Priority = si.Fields.Priority.Name
+	}
+	if updated, err := time.Parse(jiraTimeLayout, si.Fields.Updated); err == nil {
+		issue.Updated = updated
+	}
+
+	// The latest history entry or comment edit tells who touched the issue last.
+	var latest time.Time
+	touched := func(author *jiraUser, at string) {
+		if t, err := time.Parse(jiraTimeLayout, at); err == nil && author.id() != "" && t.After(latest) {
+			latest = t
+			issue.UpdatedBy = author.id()
+		}
+	}
+	if si.Changelog != nil {
+		for _, history := range si.Changelog.Histories {
+			touched(history.Author, history.Created)
+		}
+	}
+	if si.Fields.Comment != nil {
+		for _, comment := range si.Fields.Comment.Comments {
+			touched(comment.UpdateAuthor, comment.Updated)
+		}
+	}
+	return issue
+}
+
+const searchFields = "summary,status,assignee,priority,updated"
+
+// searchCloud uses the token-paginated /rest/api/3/search/jql endpoint.
+func (c *Client) searchCloud(ctx context.Context, jql, fields, expand string) ([]Issue, error) {
+	var issues []Issue
+	nextPageToken := ""
+
+	for {
+		query := url.Values{}
+		query.Set("jql", jql)
+		query.Set("fields", fields)
+		query.Set("maxResults", fmt.Sprint(pageSize))
+		if expand != "" {
+			query.Set("expand", expand)
+		}
+		if nextPageToken != "" {
+			query.Set("nextPageToken", nextPageToken)
+		}
+
+		var page struct {
+			Issues        []searchIssue `json:"issues"`
+			NextPageToken string        `json:"nextPageToken"`
+			IsLast        bool          `json:"isLast"`
+		}
+		if err := c.do(ctx, http.MethodGet, c.apiPath("/search/jql"), query, nil, &page); err != nil {
+			return nil, err
+		}
+
+		for _, si := range page.Issues {
+			issues = append(issues, si.toIssue())
+			if len(issues) >= c.cfg.MaxIssues {
+				return issues, nil
+			}
+		}
+		if page.IsLast || page.NextPageToken == "" || len(page.Issues) == 0 {
+			return issues, nil
+		}
+		nextPageToken = page.NextPageToken
+	}
+}
+
+// searchDataCenter uses the offset-paginated /rest/api/2/search endpoint.
+func (c *Client) searchDataCenter(ctx context.Context, jql, fields, expand string) ([]Issue, error) {
+	var issues []Issue
+	startAt := 0
+
+	for {
+		query := url.Values{}
+		query.Set("jql", jql)
+		query.Set("fields", fields)
+		query.Set("maxResults", fmt.Sprint(pageSize))
+		if expand != "" {
+			query.Set("expand", expand)
+		}
+		query.Set("startAt", fmt.Sprint(startAt))
+
+		var page struct {
+			StartAt int           `json:"startAt"`
+			Total   int           `json:"total"`
+			Issues  []searchIssue `json:"issues"`
+		}
+		if err := c.do(ctx, http.MethodGet, c.apiPath("/search"), query, nil, &page); err != nil {
+			return nil, err
+		}
+
+		for _, si := range page.Issues {
+			issues = append(issues, si.toIssue())
+			if len(issues) >= c.cfg.MaxIssues {
+				return issues, nil
+			}
+		}
+		startAt = page.StartAt + len(page.Issues)
+		if len(page.Issues) == 0 || startAt >= page.Total {
+			return issues, nil
+		}
+	}
+}
+
+func (c *Client) isCloud() bool {
+	return c.cfg.Deployment != DeploymentDataCenter
+}
+
+// apiPath returns the REST path for the configured deployment (v3 on Cloud, v2 on Data Center).
+func (c *Client) apiPath(path string) string {
+	if c.isCloud() {
+		return "/rest/api/3" + path
+	}
+	return "/rest/api/2" + path
+}
+
+// do performs an authenticated JSON request and decodes the response into out, if given.
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body, out interface{}) error {
+	endpoint := c.baseURL + path
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+
+	var reader io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.cfg.BearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+c.cfg.BearerToken)
+	} else if c.cfg.Username != "" {
+		req.SetBasicAuth(c.cfg.Username, c.cfg.APIToken)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return newAPIError(resp.StatusCode, respBody)
+	}
+	if out == nil || len(respBody) == 0 {
+		return nil
+	}
+	return json.Unmarshal(respBody, out)
+}
+
+// APIError is returned when Jira responds with a non-2xx status.
+type APIError struct {
+	StatusCode int
+	Messages   []string
+}
+
+func (e *APIError) Error() string {
+	if len(e.Messages) == 0 {
+		return fmt.Sprintf("jira request failed with status %d", e.StatusCode)
+	}
+	return fmt.Sprintf("jira request failed with status %d: %s", e.StatusCode, strings.Join(e.Messages, "; "))
+}
+
+func newAPIError(status int, body []byte) error {
+	apiErr := &APIError{StatusCode: status}
+	var payload struct {
+		ErrorMessages []string          `json:"errorMessages"`
+		Errors        map[string]string `json:"errors"`
+	}
+	if err := json.Unmarshal(body, &payload); err == nil {
+		apiErr.Messages = append(apiErr.Messages, payload.ErrorMessages...)
+		fields := make([]string, 0, len(payload.Errors))
+		for field := range payload.Errors {
+			fields = append(fields, field)
+		}
+		sort.Strings(fields)
+		for _, field := range fields {
+			apiErr.Messages = append(apiErr.Messages, field+": "+payload.Errors[field])
+		}
+	}
+	return apiErr
+}
+
+// toADF wraps plain text in an Atlassian Document Format document, one paragraph per line.
+func toADF(text string) map[string]interface{} {
+	var paragraphs []interface{}
+	for _, line := range strings.Split(text, "\n") {
+		paragraph := map[string]interface{}{"type": "paragraph"}
+		if line != "" {
+			paragraph["content"] = []interface{}{
+				map[string]interface{}{"type": "text", "text": line},
+			}
+		}
+		paragraphs = append(paragraphs, paragraph)
+	}
+	return map[string]interface{}{
+		"type":    "doc",
+		"version": 1,
+		"content": paragraphs,
+	}
+}
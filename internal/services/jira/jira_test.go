@@ -0,0 +1,276 @@
+package jira
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+// issuesJSON returns n search results with keys prefix-from to prefix-(from+n-1).
+func issuesJSON(prefix string, from, n int) string {
+	var issues []map[string]interface{}
+	for i := from; i < from+n; i++ {
+		issues = append(issues, map[string]interface{}{
+			"key": fmt.Sprintf("%s-%d", prefix, i),
+			"fields": map[string]interface{}{
+				"summary":  fmt.Sprintf("Issue %d", i),
+				"status":   map[string]string{"name": "Open"},
+				"priority": map[string]string{"name": "High"},
+				"updated":  "2024-05-01T10:00:00.000+0000",
+			},
+		})
+	}
+	data, _ := json.Marshal(issues)
+	return string(data)
+}
+
+func newTestClient(t *testing.T, cfg Config, handler http.HandlerFunc) *Client {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	cfg.BaseURL = server.URL
+	return New(cfg)
+}
+
+func TestSearchCloudFollowsPageTokens(t *testing.T) {
+	var tokens []string
+	client := newTestClient(t, Config{}, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/rest/api/3/search/jql" {
+			t.Errorf("path = %s, want /rest/api/3/search/jql", r.URL.Path)
+		}
+		if got := r.URL.Query().Get("jql"); got != "project = PAY" {
+			t.Errorf("jql = %q", got)
+		}
+		token := r.URL.Query().Get("nextPageToken")
+		tokens = append(tokens, token)
+		switch token {
+		case "":
+			fmt.Fprintf(w, `{"issues":%s,"nextPageToken":"page2","isLast":false}`, issuesJSON("PAY", 1, 2))
+		case "page2":
+			fmt.Fprintf(w, `{"issues":%s,"isLast":true}`, issuesJSON("PAY", 3, 1))
+		default:
+			t.Errorf("unexpected page token %q", token)
+		}
+	})
+
+	issues, err := client.FetchIssuesContext(context.Background(), "project = PAY")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(issues) != 3 || issues[2].Key != "PAY-3" {
+		t.Fatalf("issues = %+v, want PAY-1 to PAY-3", issues)
+	}
+	if issues[0].Status != "Open" || issues[0].Priority != "High" || issues[0].Updated.IsZero() {
+		t.Errorf("fields not decoded: %+v", issues[0])
+	}
+	if len(tokens) != 2 || tokens[1] != "page2" {
+		t.Errorf("page tokens = %q, want [\"\" \"page2\"]", tokens)
+	}
+}
+
+func TestSearchDataCenterFollowsStartAt(t *testing.T) {
+	var offsets []int
+	client := newTestClient(t, Config{Deployment: DeploymentDataCenter}, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/rest/api/2/search" {
+			t.Errorf("path = %s, want /rest/api/2/search", r.URL.Path)
+		}
+		startAt, _ := strconv.Atoi(r.URL.Query().Get("startAt"))
+		offsets = append(offsets, startAt)
+		// The server returns fewer issues than requested, as Jira may.
+		n := 2
+		if startAt+n > 5 {
+			n = 5 - startAt
+		}
+		fmt.Fprintf(w, `{"startAt":%d,"total":5,"issues":%s}`, startAt, issuesJSON("OPS", startAt+1, n))
+	})
+
+	issues, err := client.FetchIssuesContext(context.Background(), "project = OPS")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(issues) != 5 || issues[4].Key != "OPS-5" {
+		t.Fatalf("issues = %+v, want OPS-1 to OPS-5", issues)
+	}
+	if want := []int{0, 2, 4}; fmt.Sprint(offsets) != fmt.Sprint(want) {
+		t.Errorf("startAt offsets = %v, want %v", offsets, want)
+	}
+}
+
+func TestSearchStopsAtMaxIssues(t *testing.T) {
+	requests := 0
+	client := newTestClient(t, Config{MaxIssues: 3}, func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		fmt.Fprintf(w, `{"issues":%s,"nextPageToken":"more","isLast":false}`, issuesJSON("PAY", requests*10, 2))
+	})
+
+	issues, err := client.FetchIssuesContext(context.Background(), "project = PAY")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(issues) != 3 {
+		t.Errorf("got %d issues, want the cap of 3", len(issues))
+	}
+	if requests != 2 {
+		t.Errorf("made %d requests, want 2", requests)
+	}
+}
+
+func TestAddComment(t *testing.T) {
+	tests := []struct {
+		deployment string
+		path       string
+		plain      bool
+	}{
+		{DeploymentCloud, "/rest/api/3/issue/PAY-1/comment", false},
+		{DeploymentDataCenter, "/rest/api/2/issue/PAY-1/comment", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.deployment, func(t *testing.T) {
+			var body map[string]interface{}
+			client := newTestClient(t, Config{Deployment: tt.deployment}, func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodPost || r.URL.Path != tt.path {
+					t.Errorf("request = %s %s, want POST %s", r.Method, r.URL.Path, tt.path)
+				}
+				json.NewDecoder(r.Body).Decode(&body)
+				w.WriteHeader(http.StatusCreated)
+				io.WriteString(w, `{"id":"10000"}`)
+			})
+
+			if err := client.AddComment(context.Background(), "PAY-1", "Deployed\nto prod"); err != nil {
+				t.Fatal(err)
+			}
+			if tt.plain {
+				if body["body"] != "Deployed\nto prod" {
+					t.Errorf("body = %v, want the plain comment", body["body"])
+				}
+				return
+			}
+			doc, ok := body["body"].(map[string]interface{})
+			if !ok || doc["type"] != "doc" {
+				t.Fatalf("body = %v, want an ADF document", body["body"])
+			}
+			if paragraphs := doc["content"].([]interface{}); len(paragraphs) != 2 {
+				t.Errorf("got %d paragraphs, want one per line", len(paragraphs))
+			}
+		})
+	}
+}
+
+func TestCreateIssue(t *testing.T) {
+	var fields map[string]interface{}
+	client := newTestClient(t, Config{Deployment: DeploymentDataCenter}, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/rest/api/2/issue" {
+			t.Errorf("request = %s %s, want POST /rest/api/2/issue", r.Method, r.URL.Path)
+		}
+		var body struct {
+			Fields map[string]interface{} `json:"fields"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		fields = body.Fields
+		w.WriteHeader(http.StatusCreated)
+		io.WriteString(w, `{"id":"10001","key":"OPS-42"}`)
+	})
+
+	key, err := client.CreateIssue(context.Background(), IssueInput{ProjectKey: "OPS", IssueType: "Bug", Summary: "Checkout fails", Description: "Steps"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key != "OPS-42" {
+		t.Errorf("key = %q, want OPS-42", key)
+	}
+	if fields["summary"] != "Checkout fails" || fields["description"] != "Steps" {
+		t.Errorf("fields = %v", fields)
+	}
+	if project := fields["project"].(map[string]interface{}); project["key"] != "OPS" {
+		t.Errorf("project = %v, want OPS", project)
+	}
+	if issueType := fields["issuetype"].(map[string]interface{}); issueType["name"] != "Bug" {
+		t.Errorf("issuetype = %v, want Bug", issueType)
+	}
+}
+
+func TestCreateIssueReportsAPIErrors(t *testing.T) {
+	client := newTestClient(t, Config{}, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		io.WriteString(w, `{"errorMessages":[],"errors":{"summary":"Summary is required"}}`)
+	})
+
+	_, err := client.CreateIssue(context.Background(), IssueInput{ProjectKey: "PAY", IssueType: "Task"})
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest {
+		t.Fatalf("err = %v, want an APIError with status 400", err)
+	}
+	if len(apiErr.Messages) != 1 || apiErr.Messages[0] != "summary: Summary is required" {
+		t.Errorf("messages = %q", apiErr.Messages)
+	}
+}
+
+func TestWritesRequireConfiguration(t *testing.T) {
+	client := New(Config{})
+	if err := client.AddComment(context.Background(), "PAY-1", "hi"); !errors.Is(err, ErrNotConfigured) {
+		t.Errorf("AddComment err = %v, want ErrNotConfigured", err)
+	}
+	if _, err := client.CreateIssue(context.Background(), IssueInput{}); !errors.Is(err, ErrNotConfigured) {
+		t.Errorf("CreateIssue err = %v, want ErrNotConfigured", err)
+	}
+}
+
+func TestAuthorizationHeader(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  Config
+		want string
+	}{
+		{"bearer", Config{BearerToken: "pat"}, "Bearer pat"},
+		{"bearer wins over basic", Config{BearerToken: "pat", Username: "me@example.com", APIToken: "secret"}, "Bearer pat"},
+		{"basic", Config{Username: "me@example.com", APIToken: "secret"}, "Basic bWVAZXhhbXBsZS5jb206c2VjcmV0"},
+		{"none", Config{}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			client := newTestClient(t, tt.cfg, func(w http.ResponseWriter, r *http.Request) {
+				got = r.Header.Get("Authorization")
+				io.WriteString(w, `{"issues":[],"isLast":true}`)
+			})
+			if _, err := client.FetchIssuesContext(context.Background(), "project = PAY"); err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("Authorization = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFetchIssueActivityReportsLastUpdater(t *testing.T) {
+	var query map[string]string
+	client := newTestClient(t, Config{}, func(w http.ResponseWriter, r *http.Request) {
+		query = map[string]string{"fields": r.URL.Query().Get("fields"), "expand": r.URL.Query().Get("expand")}
+		io.WriteString(w, `{"isLast":true,"issues":[
+			{"key":"PAY-1","fields":{"summary":"Edited by bob","updated":"2024-05-01T10:00:00.000+0000"},
+			 "changelog":{"histories":[
+				{"author":{"accountId":"alice"},"created":"2024-05-01T08:00:00.000+0000"},
+				{"author":{"accountId":"bob"},"created":"2024-05-01T10:00:00.000+0000"}]}},
+			{"key":"PAY-2","fields":{"summary":"Commented by alice","updated":"2024-05-01T11:00:00.000+0000",
+			 "comment":{"comments":[{"updateAuthor":{"accountId":"alice"},"updated":"2024-05-01T11:00:00.000+0000"}]}},
+			 "changelog":{"histories":[{"author":{"accountId":"bob"},"created":"2024-05-01T09:00:00.000+0000"}]}}]}`)
+	})
+
+	issues, err := client.FetchIssueActivity(context.Background(), "watcher = alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if query["expand"] != "changelog" || query["fields"] != searchFields+",comment" {
+		t.Errorf("query = %v, want the changelog and comments", query)
+	}
+	if len(issues) != 2 || issues[0].UpdatedBy != "bob" || issues[1].UpdatedBy != "alice" {
+		t.Errorf("issues = %+v, want PAY-1 by bob and PAY-2 by alice", issues)
+	}
+}
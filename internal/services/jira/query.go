@@ -0,0 +1,90 @@
+package jira
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ChannelQuery maps a Slack channel to the Jira issues that belong in its summaries.
+type ChannelQuery struct {
+	// Projects are the Jira project keys for the channel, e.g. ["PAY"].
+	Projects []string
+	// JQL is a query template. {{since}} is replaced with the summary window as a
+	// relative JQL date (e.g. "-7d") and {{projects}} with the quoted project keys.
+	// Empty means "project in ({{projects}}) AND updated >= {{since}}".
+	JQL string
+}
+
+// QueryMapping builds the JQL for a channel summary.
+type QueryMapping struct {
+	// Channels is keyed by Slack channel ID or by channel name without the leading "#".
+	Channels map[string]ChannelQuery
+	// Default is the template for unmapped channels, e.g.
+	// "updated >= {{since}} ORDER BY updated DESC". Empty means unmapped channels
+	// get no Jira issues.
+	Default string
+}
+
+// Query returns the JQL for a summary of the given channel covering the last window.
+// The channel is looked up by ID first, then by name. ok is false when the channel
+// has no mapping and there is no default, in which case the summary has no Jira section.
+func (m QueryMapping) Query(channelID, channelName string, window time.Duration) (jql string, ok bool) {
+	mapping, ok := m.lookup(channelID)
+	if !ok && channelName != "" {
+		mapping, ok = m.lookup(strings.TrimPrefix(channelName, "#"))
+	}
+
+	template := m.Default
+	if ok {
+		switch {
+		case mapping.JQL != "":
+			template = mapping.JQL
+		case len(mapping.Projects) > 0:
+			template = "project in ({{projects}}) AND updated >= {{since}} ORDER BY updated DESC"
+		}
+	}
+	if template == "" {
+		return "", false
+	}
+
+	quoted := make([]string, len(mapping.Projects))
+	for i, project := range mapping.Projects {
+		quoted[i] = fmt.Sprintf("%q", project)
+	}
+	return strings.NewReplacer(
+		"{{since}}", RelativeDate(window),
+		"{{projects}}", strings.Join(quoted, ", "),
+	).Replace(template), true
+}
+
+// lookup finds a channel mapping. Keys are compared case-insensitively because
+// config loaders such as viper lower-case map keys.
+func (m QueryMapping) lookup(key string) (ChannelQuery, bool) {
+	if mapping, ok := m.Channels[key]; ok {
+		return mapping, true
+	}
+	for name, mapping := range m.Channels {
+		if strings.EqualFold(name, key) {
+			return mapping, true
+		}
+	}
+	return ChannelQuery{}, false
+}
+
+// RelativeDate formats a look-back window as a JQL relative date such as "-7d",
+// "-36h" or "-90m", using the largest unit that represents it exactly.
+func RelativeDate(window time.Duration) string {
+	minutes := int64(window / time.Minute)
+	if minutes < 1 {
+		minutes = 1
+	}
+	switch {
+	case minutes%(24*60) == 0:
+		return fmt.Sprintf("-%dd", minutes/(24*60))
+	case minutes%60 == 0:
+		return fmt.Sprintf("-%dh", minutes/60)
+	default:
+		return fmt.Sprintf("-%dm", minutes)
+	}
+}
@@ -0,0 +1,119 @@
+package jira
+
+import (
+	"testing"
+	"time"
+)
+
+func TestQueryMapping(t *testing.T) {
+	mapping := QueryMapping{Channels: map[string]ChannelQuery{
+		"payments":    {Projects: []string{"PAY", "BILL"}},
+		"C0123456789": {JQL: "project = OPS AND labels = incident AND updated >= {{since}}"},
+		"platform":    {Projects: []string{"PLAT"}, JQL: "project in ({{projects}}) AND status != Done AND updated >= {{since}}"},
+		"empty":       {},
+	}}
+	withDefault := mapping
+	withDefault.Default = "updated >= {{since}} ORDER BY updated DESC"
+
+	tests := []struct {
+		name        string
+		mapping     QueryMapping
+		channelID   string
+		channelName string
+		window      time.Duration
+		want        string
+		wantOK      bool
+	}{
+		{
+			name:      "projects",
+			mapping:   mapping,
+			channelID: "C1", channelName: "payments",
+			window: 7 * 24 * time.Hour,
+			want:   `project in ("PAY", "BILL") AND updated >= -7d ORDER BY updated DESC`, wantOK: true,
+		},
+		{
+			name:      "JQL by channel ID",
+			mapping:   mapping,
+			channelID: "C0123456789", channelName: "incidents",
+			window: 36 * time.Hour,
+			want:   "project = OPS AND labels = incident AND updated >= -36h", wantOK: true,
+		},
+		{
+			name:      "JQL with projects",
+			mapping:   mapping,
+			channelID: "C2", channelName: "platform",
+			window: 90 * time.Minute,
+			want:   `project in ("PLAT") AND status != Done AND updated >= -90m`, wantOK: true,
+		},
+		{
+			name:      "name with a leading # in another case",
+			mapping:   mapping,
+			channelID: "C1", channelName: "#Payments",
+			window: 24 * time.Hour,
+			want:   `project in ("PAY", "BILL") AND updated >= -1d ORDER BY updated DESC`, wantOK: true,
+		},
+		{
+			name:      "unmapped channel",
+			mapping:   mapping,
+			channelID: "C3", channelName: "random",
+			window: 24 * time.Hour,
+		},
+		{
+			name:      "unknown channel name",
+			mapping:   mapping,
+			channelID: "C3",
+			window:    24 * time.Hour,
+		},
+		{
+			name:      "mapping without projects or JQL",
+			mapping:   mapping,
+			channelID: "C4", channelName: "empty",
+			window: 24 * time.Hour,
+		},
+		{
+			name:      "unmapped channel with a default",
+			mapping:   withDefault,
+			channelID: "C3", channelName: "random",
+			window: 24 * time.Hour,
+			want:   "updated >= -1d ORDER BY updated DESC", wantOK: true,
+		},
+		{
+			name:      "mapped channel with a default",
+			mapping:   withDefault,
+			channelID: "C1", channelName: "payments",
+			window: 24 * time.Hour,
+			want:   `project in ("PAY", "BILL") AND updated >= -1d ORDER BY updated DESC`, wantOK: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := tt.mapping.Query(tt.channelID, tt.channelName, tt.window)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("Query = %q, %v; want %q, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestRelativeDate(t *testing.T) {
+	tests := []struct {
+		window time.Duration
+		want   string
+	}{
+		{7 * 24 * time.Hour, "-7d"},
+		{24 * time.Hour, "-1d"},
+		{36 * time.Hour, "-36h"},
+		{time.Hour, "-1h"},
+		{90 * time.Minute, "-90m"},
+		{90*time.Minute + 30*time.Second, "-90m"},
+		{30 * time.Second, "-1m"},
+		{0, "-1m"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.window.String(), func(t *testing.T) {
+			if got := RelativeDate(tt.window); got != tt.want {
+				t.Errorf("RelativeDate(%s) = %q, want %q", tt.window, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,198 @@
+package jira
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Webhook event types the bot understands. Jira prefixes issue events with
+// "jira:"; ParseWebhook strips it.
+const (
+	EventIssueCreated   = "issue_created"
+	EventIssueUpdated   = "issue_updated"
+	EventCommentCreated = "comment_created"
+)
+
+// WebhookEvent is a parsed Jira webhook delivery.
+type WebhookEvent struct {
+	// Type is one of the Event constants.
+	Type string
+	// Actor is the display name of the user who triggered the event.
+	Actor     string
+	Issue     WebhookIssue
+	Changes   []Change
+	Comment   *Comment
+	Timestamp time.Time
+}
+
+// WebhookIssue holds the issue fields carried by a webhook.
+type WebhookIssue struct {
+	Key       string
+	Summary   string
+	Project   string
+	IssueType string
+	Priority  string
+	Status    string
+	Assignee  string
+	Reporter  string
+	Labels    []string
+}
+
+// Change is a single field change from an issue_updated changelog.
+type Change struct {
+	Field string
+	From  string
+	To    string
+}
+
+// Comment is the comment added by a comment_created event.
+type Comment struct {
+	Author string
+	Body   string
+}
+
+type webhookUser struct {
+	DisplayName string `json:"displayName"`
+}
+
+type webhookPayload struct {
+	Timestamp    int64        `json:"timestamp"`
+	WebhookEvent string       `json:"webhookEvent"`
+	User         *webhookUser `json:"user"`
+	Issue        *struct {
+		Key    string `json:"key"`
+		Fields struct {
+			Summary string `json:"summary"`
+			Project *struct {
+				Key string `json:"key"`
+			} `json:"project"`
+			IssueType *struct {
+				Name string `json:"name"`
+			} `json:"issuetype"`
+			Priority *struct {
+				Name string `json:"name"`
+			} `json:"priority"`
+			Status *struct {
+				Name string `json:"name"`
+			} `json:"status"`
+			Assignee *webhookUser `json:"assignee"`
+			Reporter *webhookUser `json:"reporter"`
+			Labels   []string     `json:"labels"`
+		} `json:"fields"`
+	} `json:"issue"`
+	Changelog *struct {
+		Items []struct {
+			Field      string `json:"field"`
+			FromString string `json:"fromString"`
+			ToString   string `json:"toString"`
+		} `json:"items"`
+	} `json:"changelog"`
+	Comment *struct {
+		Author *webhookUser    `json:"author"`
+		Body   json.RawMessage `json:"body"`
+	} `json:"comment"`
+}
+
+// ParseWebhook parses a webhook body. It returns an error for event types other
+// than issue_created, issue_updated and comment_created.
+func ParseWebhook(body []byte) (*WebhookEvent, error) {
+	var payload webhookPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, fmt.Errorf("invalid webhook payload: %w", err)
+	}
+
+	event := &WebhookEvent{Type: strings.TrimPrefix(payload.WebhookEvent, "jira:")}
+	switch event.Type {
+	case EventIssueCreated, EventIssueUpdated, EventCommentCreated:
+	default:
+		return nil, fmt.Errorf("unsupported webhook event %q", payload.WebhookEvent)
+	}
+	if payload.Issue == nil {
+		return nil, fmt.Errorf("webhook event %q has no issue", payload.WebhookEvent)
+	}
+
+	if payload.Timestamp > 0 {
+		event.Timestamp = time.UnixMilli(payload.Timestamp)
+	}
+	if payload.User != nil {
+		event.Actor = payload.User.DisplayName
+	}
+
+	fields := payload.Issue.Fields
+	event.Issue = WebhookIssue{Key: payload.Issue.Key, Summary: fields.Summary, Labels: fields.Labels}
+	if fields.Project != nil {
+		event.Issue.Project = fields.Project.Key
+	}
+	if fields.IssueType != nil {
+		event.Issue.IssueType = fields.IssueType.Name
+	}
+	if fields.Priority != nil {
+		event.Issue.Priority = fields.Priority.Name
+	}
+	if fields.Status != nil {
+		event.Issue.Status = fields.Status.Name
+	}
+	if fields.Assignee != nil {
+		event.Issue.Assignee = fields.Assignee.DisplayName
+	}
+	if fields.Reporter != nil {
+		event.Issue.Reporter = fields.Reporter.DisplayName
+	}
+
+	if payload.Changelog != nil {
+		for _, item := range payload.Changelog.Items {
+			event.Changes = append(event.Changes, Change{Field: item.Field, From: item.FromString, To: item.ToString})
+		}
+	}
+	if payload.Comment != nil {
+		event.Comment = &Comment{Body: commentText(payload.Comment.Body)}
+		if payload.Comment.Author != nil {
+			event.Comment.Author = payload.Comment.Author.DisplayName
+		}
+		if event.Actor == "" {
+			event.Actor = event.Comment.Author
+		}
+	}
+	return event, nil
+}
+
+// commentText returns a comment body as plain text. Bodies are plain strings on
+// Data Center and in most Cloud webhooks, but may be Atlassian Document Format.
+func commentText(raw json.RawMessage) string {
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		return text
+	}
+	var doc interface{}
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return ""
+	}
+	var builder strings.Builder
+	fromADF(doc, &builder)
+	return strings.TrimSpace(builder.String())
+}
+
+// fromADF collects the text nodes of an ADF document, ending each block with a newline.
+func fromADF(node interface{}, builder *strings.Builder) {
+	switch n := node.(type) {
+	case map[string]interface{}:
+		if text, ok := n["text"].(string); ok {
+			builder.WriteString(text)
+		}
+		if content, ok := n["content"].([]interface{}); ok {
+			for _, child := range content {
+				fromADF(child, builder)
+			}
+		}
+		switch n["type"] {
+		case "paragraph", "heading", "listItem", "codeBlock":
+			builder.WriteString("\n")
+		}
+	case []interface{}:
+		for _, child := range n {
+			fromADF(child, builder)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package jira
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestParseWebhook(t *testing.T) {
+	body := `{
+		"timestamp": 1714557600000,
+		"webhookEvent": "jira:issue_updated",
+		"user": {"displayName": "Bob"},
+		"issue": {"key": "PAY-1", "fields": {
+			"summary": "Payment page times out",
+			"project": {"key": "PAY"},
+			"issuetype": {"name": "Bug"},
+			"priority": {"name": "High"},
+			"status": {"name": "In Progress"},
+			"assignee": {"displayName": "Alice"},
+			"labels": ["incident"]
+		}},
+		"changelog": {"items": [{"field": "status", "fromString": "Open", "toString": "In Progress"}]}
+	}`
+
+	event, err := ParseWebhook([]byte(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.Type != EventIssueUpdated || event.Actor != "Bob" || !event.Timestamp.Equal(time.UnixMilli(1714557600000)) {
+		t.Errorf("event = %+v", event)
+	}
+	want := WebhookIssue{Key: "PAY-1", Summary: "Payment page times out", Project: "PAY", IssueType: "Bug", Priority: "High", Status: "In Progress", Assignee: "Alice", Labels: []string{"incident"}}
+	if fmt.Sprint(event.Issue) != fmt.Sprint(want) {
+		t.Errorf("issue = %+v, want %+v", event.Issue, want)
+	}
+	if len(event.Changes) != 1 || event.Changes[0] != (Change{Field: "status", From: "Open", To: "In Progress"}) {
+		t.Errorf("changes = %+v", event.Changes)
+	}
+	if event.Comment != nil {
+		t.Errorf("comment = %+v, want none", event.Comment)
+	}
+}
+
+func TestParseWebhookComments(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"plain text", `"Deployed to prod"`, "Deployed to prod"},
+		{"ADF", `{"type": "doc", "version": 1, "content": [
+			{"type": "paragraph", "content": [{"type": "text", "text": "Deployed "}, {"type": "text", "text": "to prod", "marks": [{"type": "strong"}]}]},
+			{"type": "bulletList", "content": [
+				{"type": "listItem", "content": [{"type": "paragraph", "content": [{"type": "text", "text": "eu"}]}]}]},
+			{"type": "codeBlock", "content": [{"type": "text", "text": "make deploy"}]}
+		]}`, "Deployed to prod\neu\n\nmake deploy"},
+		{"empty ADF", `{"type": "doc", "version": 1, "content": []}`, ""},
+		{"unexpected", `42`, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := fmt.Sprintf(`{"webhookEvent": "comment_created", "issue": {"key": "PAY-1", "fields": {}},
+				"comment": {"author": {"displayName": "Carol"}, "body": %s}}`, tt.body)
+			event, err := ParseWebhook([]byte(body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if event.Comment == nil || event.Comment.Body != tt.want {
+				t.Fatalf("comment = %+v, want body %q", event.Comment, tt.want)
+			}
+			if event.Actor != "Carol" {
+				t.Errorf("actor = %q, want the comment author", event.Actor)
+			}
+		})
+	}
+}
+
+func TestParseWebhookErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"not JSON", `<html>`},
+		{"unsupported event", `{"webhookEvent": "jira:issue_deleted", "issue": {"key": "PAY-1"}}`},
+		{"no issue", `{"webhookEvent": "jira:issue_created"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseWebhook([]byte(tt.body)); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
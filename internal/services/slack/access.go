@@ -0,0 +1,94 @@
+package slack
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/slack-go/slack"
+)
+
+// nonPublicTypes are the conversation types only their members may read.
+var nonPublicTypes = []string{"private_channel", "mpim", "im"}
+
+// isPublic reports whether everyone in the workspace can read channel.
+func isPublic(channel slack.Channel) bool {
+	return !channel.IsPrivate && !channel.IsMpIM && !channel.IsIM
+}
+
+// CanRead reports whether userID may read channelID: public channels are open to
+// everyone in the workspace, while private channels, group DMs and DMs are open
+// only to their members. Membership is checked through conversations.members.
+func (c *Client) CanRead(ctx context.Context, userID, channelID string) (bool, error) {
+	log.Printf("Calling Slack API: conversations.info for channel %s", channelID)
+	var channel *slack.Channel
+	err := c.call(ctx, "conversations.info", func(ctx context.Context) (err error) {
+		channel, err = c.api.GetConversationInfoContext(ctx, &slack.GetConversationInfoInput{ChannelID: channelID})
+		return err
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to get channel info: %w", err)
+	}
+	if isPublic(*channel) {
+		return true, nil
+	}
+	if channel.IsIM {
+		return channel.User == userID, nil
+	}
+	return c.IsMember(ctx, userID, channelID)
+}
+
+// FilterReadable returns the channels of channelIDs that userID may read and the
+// number that were left out. Channels whose access can't be checked are left out.
+func (c *Client) FilterReadable(ctx context.Context, userID string, channelIDs []string) ([]string, int) {
+	var readable []string
+	hidden := 0
+	for _, channelID := range channelIDs {
+		ok, err := c.CanRead(ctx, userID, channelID)
+		if err != nil {
+			log.Printf("Could not check whether user %s may read channel %s: %v", userID, channelID, err)
+		}
+		if !ok {
+			hidden++
+			continue
+		}
+		readable = append(readable, channelID)
+	}
+	return readable, hidden
+}
+
+// IsMember reports whether userID is a member of channelID. It pages through
+// conversations.members, which also works for public channels.
+func (c *Client) IsMember(ctx context.Context, userID, channelID string) (bool, error) {
+	params := &slack.GetUsersInConversationParameters{ChannelID: channelID, Limit: pageSize}
+	for {
+		log.Printf("Calling Slack API: conversations.members for channel %s", channelID)
+		var members []string
+		var nextCursor string
+		err := c.call(ctx, "conversations.members", func(ctx context.Context) (err error) {
+			members, nextCursor, err = c.api.GetUsersInConversationContext(ctx, params)
+			return err
+		})
+		if err != nil {
+			return false, fmt.Errorf("failed to get channel members: %w", err)
+		}
+		for _, member := range members {
+			if member == userID {
+				return true, nil
+			}
+		}
+		if nextCursor == "" {
+			return false, nil
+		}
+		params.Cursor = nextCursor
+	}
+}
+
+// IsAdmin reports whether userID is an admin or owner of the workspace.
+func (c *Client) IsAdmin(ctx context.Context, userID string) (bool, error) {
+	user, err := c.getUser(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	return user.IsAdmin || user.IsOwner || user.IsPrimaryOwner, nil
+}
@@ -0,0 +1,160 @@
+package slack
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeHandler answers a fake Slack API request with a status and a JSON body.
+// It may set response headers on w.
+type fakeHandler func(w http.ResponseWriter, r *http.Request) (int, string)
+
+// fakeSlack is a Slack Web API server that answers each method with a canned
+// JSON body and records the requests it received.
+type fakeSlack struct {
+	mu       sync.Mutex
+	handlers map[string]fakeHandler
+	calls    map[string]int
+}
+
+// newFakeSlack starts a fake Slack API and returns a client pointed at it.
+func newFakeSlack(t *testing.T, handlers map[string]fakeHandler) (*Client, *fakeSlack) {
+	t.Helper()
+	fake := &fakeSlack{handlers: handlers, calls: make(map[string]int)}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		method := strings.TrimPrefix(r.URL.Path, "/")
+		fake.mu.Lock()
+		fake.calls[method]++
+		fake.mu.Unlock()
+
+		handler, ok := fake.handlers[method]
+		if !ok {
+			t.Errorf("unexpected call to %s", method)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		status, body := handler(w, r)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+
+	client := New("xoxb-test")
+	client.SetAPIURL(server.URL)
+	return client, fake
+}
+
+// count returns how often method was called.
+func (f *fakeSlack) count(method string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls[method]
+}
+
+// ok answers every request with body.
+func ok(body string) fakeHandler {
+	return func(w http.ResponseWriter, r *http.Request) (int, string) { return http.StatusOK, body }
+}
+
+// accessHandlers describe a public channel C1, a private channel G1 with the
+// single member U1, and U1's DM D1.
+func accessHandlers() map[string]fakeHandler {
+	return map[string]fakeHandler{
+		"conversations.info": func(w http.ResponseWriter, r *http.Request) (int, string) {
+			switch r.FormValue("channel") {
+			case "C1":
+				return http.StatusOK, `{"ok":true,"channel":{"id":"C1","name":"general"}}`
+			case "G1":
+				return http.StatusOK, `{"ok":true,"channel":{"id":"G1","name":"secret","is_private":true}}`
+			case "D1":
+				return http.StatusOK, `{"ok":true,"channel":{"id":"D1","is_im":true,"user":"U1"}}`
+			}
+			return http.StatusOK, `{"ok":false,"error":"channel_not_found"}`
+		},
+		"conversations.members": ok(`{"ok":true,"members":["U1"],"response_metadata":{"next_cursor":""}}`),
+		"users.conversations": func(w http.ResponseWriter, r *http.Request) (int, string) {
+			if r.FormValue("user") == "U2" {
+				return http.StatusOK, `{"ok":true,"channels":[{"id":"C1","name":"general"}],"response_metadata":{"next_cursor":""}}`
+			}
+			return http.StatusOK, `{"ok":true,"channels":[{"id":"C1","name":"general"},{"id":"G1","name":"secret","is_private":true}],"response_metadata":{"next_cursor":""}}`
+		},
+	}
+}
+
+func TestCanRead(t *testing.T) {
+	client, _ := newFakeSlack(t, accessHandlers())
+	tests := []struct {
+		user, channel string
+		want          bool
+	}{
+		{"U1", "C1", true},
+		{"U2", "C1", true},
+		{"U1", "G1", true},
+		{"U2", "G1", false},
+		{"U1", "D1", true},
+		{"U2", "D1", false},
+	}
+	for _, tt := range tests {
+		got, err := client.CanRead(context.Background(), tt.user, tt.channel)
+		if err != nil {
+			t.Fatalf("CanRead(%s, %s): %v", tt.user, tt.channel, err)
+		}
+		if got != tt.want {
+			t.Errorf("CanRead(%s, %s) = %v, want %v", tt.user, tt.channel, got, tt.want)
+		}
+	}
+}
+
+func TestCanReadFailsClosed(t *testing.T) {
+	client, _ := newFakeSlack(t, accessHandlers())
+	ok, err := client.CanRead(context.Background(), "U1", "G404")
+	if err == nil || ok {
+		t.Errorf("CanRead of an unknown channel = %v, %v; want false and an error", ok, err)
+	}
+}
+
+func TestFilterReadable(t *testing.T) {
+	client, _ := newFakeSlack(t, accessHandlers())
+	readable, hidden := client.FilterReadable(context.Background(), "U2", []string{"C1", "G1", "D1", "G404"})
+	if len(readable) != 1 || readable[0] != "C1" {
+		t.Errorf("readable = %v, want [C1]", readable)
+	}
+	if hidden != 3 {
+		t.Errorf("hidden = %d, want 3", hidden)
+	}
+}
+
+func TestSelectChannelsHidesPrivateChannelsFromNonMembers(t *testing.T) {
+	client, _ := newFakeSlack(t, accessHandlers())
+	scope := ChannelScope{Types: []string{"public_channel", "private_channel"}}
+
+	selection, err := client.SelectChannels(context.Background(), scope, "U2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(selection.Channels) != 1 || selection.Channels[0] != "C1" {
+		t.Errorf("channels = %v, want [C1]", selection.Channels)
+	}
+	if selection.Hidden != 1 {
+		t.Errorf("hidden = %d, want 1", selection.Hidden)
+	}
+	for _, skipped := range selection.Skipped {
+		if skipped.ID == "G1" || skipped.Name == "secret" {
+			t.Errorf("private channel leaked in skipped list: %+v", skipped)
+		}
+	}
+
+	selection, err = client.SelectChannels(context.Background(), scope, "U1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(selection.Channels) != 2 {
+		t.Errorf("member channels = %v, want [C1 G1]", selection.Channels)
+	}
+}
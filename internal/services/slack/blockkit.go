@@ -0,0 +1,266 @@
+package slack
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/slack-go/slack"
+)
+
+// Block Kit limits enforced by Slack for chat.postMessage.
+const (
+	MaxBlocks            = 50
+	MaxSectionTextLength = 3000
+	MaxSectionFields     = 10
+	MaxFieldTextLength   = 2000
+	MaxHeaderTextLength  = 150
+	MaxContextElements   = 10
+	MaxActionsElements   = 25
+	MaxButtonTextLength  = 75
+)
+
+// blockTypes lists the block types Slack accepts in messages.
+var blockTypes = []string{"section", "header", "divider", "context", "actions", "image", "rich_text", "file", "video"}
+
+// messageBlockTypes is blockTypes as a set.
+var messageBlockTypes = make(map[string]bool)
+
+// actionElementTypes are the interactive elements an actions block may hold.
+var actionElementTypes = map[string]bool{
+	"button":                     true,
+	"overflow":                   true,
+	"datepicker":                 true,
+	"timepicker":                 true,
+	"datetimepicker":             true,
+	"checkboxes":                 true,
+	"radio_buttons":              true,
+	"static_select":              true,
+	"external_select":            true,
+	"users_select":               true,
+	"conversations_select":       true,
+	"channels_select":            true,
+	"multi_static_select":        true,
+	"multi_external_select":      true,
+	"multi_users_select":         true,
+	"multi_conversations_select": true,
+	"multi_channels_select":      true,
+	"workflow_button":            true,
+}
+
+func init() {
+	for _, t := range blockTypes {
+		messageBlockTypes[t] = true
+	}
+}
+
+// MessageBlockTypes returns the block types Slack accepts in messages, e.g. to
+// tell a model which ones it may use.
+func MessageBlockTypes() []string {
+	return append([]string(nil), blockTypes...)
+}
+
+type textObject struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// rawElement is an element of a context or actions block. In context blocks
+// Text is a string; in buttons it is a text object, so it's decoded later.
+type rawElement struct {
+	Type     string          `json:"type"`
+	Text     json.RawMessage `json:"text,omitempty"`
+	ActionID string          `json:"action_id,omitempty"`
+	ImageURL string          `json:"image_url,omitempty"`
+	AltText  string          `json:"alt_text,omitempty"`
+}
+
+type rawBlock struct {
+	Type     string       `json:"type"`
+	Text     *textObject  `json:"text,omitempty"`
+	Fields   []textObject `json:"fields,omitempty"`
+	Elements []rawElement `json:"elements,omitempty"`
+}
+
+// ValidateBlocks checks that message is a JSON array of Block Kit blocks that
+// Slack will accept: at most MaxBlocks blocks of known types, within the text
+// length limits, whose context and actions elements are well formed. The
+// returned error describes the first problem found.
+func ValidateBlocks(message string) error {
+	var blocks []rawBlock
+	if err := json.Unmarshal([]byte(message), &blocks); err != nil {
+		return fmt.Errorf("response is not a JSON array of blocks: %w", err)
+	}
+	if len(blocks) == 0 {
+		return fmt.Errorf("block array is empty")
+	}
+	if len(blocks) > MaxBlocks {
+		return fmt.Errorf("found %d blocks, but a message may contain at most %d", len(blocks), MaxBlocks)
+	}
+
+	for i, block := range blocks {
+		if !messageBlockTypes[block.Type] {
+			return fmt.Errorf("block %d has invalid type %q", i, block.Type)
+		}
+
+		switch block.Type {
+		case "section":
+			if block.Text == nil && len(block.Fields) == 0 {
+				return fmt.Errorf("section block %d needs either text or fields", i)
+			}
+			if block.Text != nil {
+				if err := validateTextObject(block.Text, MaxSectionTextLength); err != nil {
+					return fmt.Errorf("section block %d: %w", i, err)
+				}
+			}
+			if len(block.Fields) > MaxSectionFields {
+				return fmt.Errorf("section block %d has %d fields, at most %d are allowed", i, len(block.Fields), MaxSectionFields)
+			}
+			for j := range block.Fields {
+				if err := validateTextObject(&block.Fields[j], MaxFieldTextLength); err != nil {
+					return fmt.Errorf("section block %d field %d: %w", i, j, err)
+				}
+			}
+		case "header":
+			if block.Text == nil || block.Text.Type != "plain_text" {
+				return fmt.Errorf("header block %d needs a plain_text text object", i)
+			}
+			if err := validateTextObject(block.Text, MaxHeaderTextLength); err != nil {
+				return fmt.Errorf("header block %d: %w", i, err)
+			}
+		case "context":
+			if len(block.Elements) == 0 || len(block.Elements) > MaxContextElements {
+				return fmt.Errorf("context block %d must have between 1 and %d elements", i, MaxContextElements)
+			}
+			for j := range block.Elements {
+				if err := validateContextElement(&block.Elements[j]); err != nil {
+					return fmt.Errorf("context block %d element %d: %w", i, j, err)
+				}
+			}
+		case "actions":
+			if len(block.Elements) == 0 || len(block.Elements) > MaxActionsElements {
+				return fmt.Errorf("actions block %d must have between 1 and %d elements", i, MaxActionsElements)
+			}
+			actionIDs := make(map[string]bool)
+			for j := range block.Elements {
+				element := &block.Elements[j]
+				if err := validateActionElement(element); err != nil {
+					return fmt.Errorf("actions block %d element %d: %w", i, j, err)
+				}
+				if element.ActionID != "" {
+					if actionIDs[element.ActionID] {
+						return fmt.Errorf("actions block %d element %d repeats action_id %q", i, j, element.ActionID)
+					}
+					actionIDs[element.ActionID] = true
+				}
+			}
+		}
+	}
+
+	// Finally make sure the Slack library can decode the blocks, as SendMessage does.
+	var decoded slack.Blocks
+	if err := json.Unmarshal([]byte(message), &decoded); err != nil {
+		return fmt.Errorf("blocks could not be decoded: %w", err)
+	}
+	return nil
+}
+
+func validateTextObject(t *textObject, maxLength int) error {
+	if t.Type != "plain_text" && t.Type != "mrkdwn" {
+		return fmt.Errorf("text type must be plain_text or mrkdwn, got %q", t.Type)
+	}
+	if strings.TrimSpace(t.Text) == "" {
+		return fmt.Errorf("text must not be empty")
+	}
+	if n := len([]rune(t.Text)); n > maxLength {
+		return fmt.Errorf("text is %d characters long, the limit is %d", n, maxLength)
+	}
+	return nil
+}
+
+// validateContextElement checks that e is a text object or an image.
+func validateContextElement(e *rawElement) error {
+	switch e.Type {
+	case "plain_text", "mrkdwn":
+		var text string
+		if err := json.Unmarshal(e.Text, &text); err != nil {
+			return fmt.Errorf("%s element needs a text string", e.Type)
+		}
+		return validateTextObject(&textObject{Type: e.Type, Text: text}, MaxSectionTextLength)
+	case "image":
+		if e.ImageURL == "" || e.AltText == "" {
+			return fmt.Errorf("image element needs image_url and alt_text")
+		}
+		return nil
+	default:
+		return fmt.Errorf("type must be plain_text, mrkdwn or image, got %q", e.Type)
+	}
+}
+
+// validateActionElement checks that e is an interactive element and, for
+// buttons, that it has a short plain_text label.
+func validateActionElement(e *rawElement) error {
+	if !actionElementTypes[e.Type] {
+		return fmt.Errorf("%q is not an interactive element", e.Type)
+	}
+	if e.Type != "button" {
+		return nil
+	}
+	var text textObject
+	if err := json.Unmarshal(e.Text, &text); err != nil || text.Type != "plain_text" {
+		return fmt.Errorf("button needs a plain_text text object")
+	}
+	return validateTextObject(&text, MaxButtonTextLength)
+}
+
+// RenderFallbackBlocks deterministically turns a message into valid Block Kit JSON.
+// If message is JSON (for example blocks that failed validation) its text values are
+// extracted first, so the user never sees raw JSON.
+func RenderFallbackBlocks(message string) string {
+	text := message
+	var parsed interface{}
+	if err := json.Unmarshal([]byte(message), &parsed); err == nil {
+		var texts []string
+		collectTexts(parsed, &texts)
+		if len(texts) > 0 {
+			text = strings.Join(texts, "\n")
+		}
+	}
+
+	blocks := formatText(text)
+	if len(blocks) == 0 {
+		blocks = []slack.Block{slack.NewSectionBlock(slack.NewTextBlockObject("mrkdwn", "I don't have a response for that.", false, false), nil, nil)}
+	}
+
+	out, err := json.Marshal(blocks)
+	if err != nil {
+		return message
+	}
+	return string(out)
+}
+
+// collectTexts walks a decoded JSON value and appends every "text" string it finds.
+func collectTexts(v interface{}, texts *[]string) {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		if text, ok := value["text"].(string); ok {
+			*texts = append(*texts, text)
+		}
+		keys := make([]string, 0, len(value))
+		for key := range value {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if _, ok := value[key].(string); ok && key == "text" {
+				continue
+			}
+			collectTexts(value[key], texts)
+		}
+	case []interface{}:
+		for _, child := range value {
+			collectTexts(child, texts)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package slack
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateBlocks(t *testing.T) {
+	tests := []struct {
+		name    string
+		blocks  string
+		wantErr string
+	}{
+		{"section", `[{"type":"section","text":{"type":"mrkdwn","text":"*Hi*"}}]`, ""},
+		{"rich text", `[{"type":"rich_text","elements":[{"type":"rich_text_section","elements":[{"type":"text","text":"Hi"}]}]}]`, ""},
+		{"unknown block type", `[{"type":"table"}]`, `invalid type "table"`},
+		{"context", `[{"type":"context","elements":[{"type":"mrkdwn","text":"Updated today"},{"type":"image","image_url":"https://example.com/a.png","alt_text":"avatar"}]}]`, ""},
+		{"context without elements", `[{"type":"context","elements":[]}]`, "between 1 and 10 elements"},
+		{"context with a button", `[{"type":"context","elements":[{"type":"button","text":{"type":"plain_text","text":"Go"}}]}]`, `context block 0 element 0: type must be plain_text, mrkdwn or image, got "button"`},
+		{"context with empty text", `[{"type":"context","elements":[{"type":"mrkdwn","text":" "}]}]`, "text must not be empty"},
+		{"context with a text object", `[{"type":"context","elements":[{"type":"mrkdwn","text":{"type":"mrkdwn","text":"hi"}}]}]`, "needs a text string"},
+		{"context image without alt text", `[{"type":"context","elements":[{"type":"image","image_url":"https://example.com/a.png"}]}]`, "needs image_url and alt_text"},
+		{"actions", `[{"type":"actions","elements":[{"type":"button","action_id":"a","text":{"type":"plain_text","text":"Open"},"url":"https://example.com"},{"type":"static_select","action_id":"b","options":[{"text":{"type":"plain_text","text":"One"},"value":"1"}]}]}]`, ""},
+		{"actions without elements", `[{"type":"actions","elements":[]}]`, "between 1 and 25 elements"},
+		{"actions with text", `[{"type":"actions","elements":[{"type":"mrkdwn","text":"hi"}]}]`, `actions block 0 element 0: "mrkdwn" is not an interactive element`},
+		{"button with mrkdwn label", `[{"type":"actions","elements":[{"type":"button","text":{"type":"mrkdwn","text":"*Go*"}}]}]`, "plain_text text object"},
+		{"button label too long", `[{"type":"actions","elements":[{"type":"button","text":{"type":"plain_text","text":"` + strings.Repeat("a", 76) + `"}}]}]`, "the limit is 75"},
+		{"repeated action_id", `[{"type":"actions","elements":[{"type":"button","action_id":"a","text":{"type":"plain_text","text":"A"}},{"type":"button","action_id":"a","text":{"type":"plain_text","text":"B"}}]}]`, `repeats action_id "a"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateBlocks(tt.blocks)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("ValidateBlocks = %v, want no error", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ValidateBlocks = %v, want an error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMessageBlockTypesMatchValidation(t *testing.T) {
+	types := MessageBlockTypes()
+	for _, want := range []string{"rich_text", "file", "video"} {
+		if !strings.Contains(strings.Join(types, ","), want) {
+			t.Errorf("MessageBlockTypes() = %v, missing %s", types, want)
+		}
+	}
+	for _, blockType := range types {
+		if !messageBlockTypes[blockType] {
+			t.Errorf("%s is listed but not accepted by ValidateBlocks", blockType)
+		}
+	}
+	if len(types) != len(messageBlockTypes) {
+		t.Errorf("MessageBlockTypes() lists %d types, ValidateBlocks accepts %d", len(types), len(messageBlockTypes))
+	}
+}
@@ -0,0 +1,191 @@
+package slack
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/slack-go/slack"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	defaultCacheSize        = 5000
+	defaultCacheTTL         = time.Hour
+	defaultCacheNegativeTTL = 5 * time.Minute
+)
+
+// errCachedFailure is returned for keys whose last lookup failed recently.
+var errCachedFailure = errors.New("lookup failed recently")
+
+// nameCache is a size-bounded LRU cache of names with per-entry expiry. Failed
+// lookups are remembered for a shorter time so that an unknown ID doesn't cause
+// an API call on every message, and concurrent lookups of the same key share one call.
+type nameCache struct {
+	mu          sync.Mutex
+	entries     map[string]*list.Element
+	order       *list.List // most recently used first
+	size        int
+	ttl         time.Duration
+	negativeTTL time.Duration
+	// now returns the current time; tests replace it.
+	now func() time.Time
+
+	group singleflight.Group
+}
+
+type cacheEntry struct {
+	key     string
+	value   string
+	failed  bool
+	expires time.Time
+}
+
+func newNameCache(size int, ttl, negativeTTL time.Duration) *nameCache {
+	if size <= 0 {
+		size = defaultCacheSize
+	}
+	if ttl <= 0 {
+		ttl = defaultCacheTTL
+	}
+	if negativeTTL <= 0 {
+		negativeTTL = defaultCacheNegativeTTL
+	}
+	return &nameCache{
+		entries:     make(map[string]*list.Element),
+		order:       list.New(),
+		size:        size,
+		ttl:         ttl,
+		negativeTTL: negativeTTL,
+		now:         time.Now,
+	}
+}
+
+// get returns the cached name for key, calling fetch on a miss. The cache's
+// lock is not held while fetch runs.
+func (c *nameCache) get(key string, fetch func() (string, error)) (string, error) {
+	if entry, ok := c.lookup(key); ok {
+		if entry.failed {
+			return "", errCachedFailure
+		}
+		return entry.value, nil
+	}
+
+	value, err, _ := c.group.Do(key, func() (interface{}, error) {
+		name, err := fetch()
+		if err != nil {
+			c.store(key, "", true, c.negativeTTL)
+			return "", err
+		}
+		c.store(key, name, false, c.ttl)
+		return name, nil
+	})
+	return value.(string), err
+}
+
+// set caches a name, e.g. from a bulk listing.
+func (c *nameCache) set(key, value string) {
+	c.store(key, value, false, c.ttl)
+}
+
+func (c *nameCache) lookup(key string) (cacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		return cacheEntry{}, false
+	}
+	entry := element.Value.(*cacheEntry)
+	if c.now().After(entry.expires) {
+		c.order.Remove(element)
+		delete(c.entries, key)
+		return cacheEntry{}, false
+	}
+	c.order.MoveToFront(element)
+	return *entry, true
+}
+
+func (c *nameCache) store(key, value string, failed bool, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry := &cacheEntry{key: key, value: value, failed: failed, expires: c.now().Add(ttl)}
+	if element, ok := c.entries[key]; ok {
+		element.Value = entry
+		c.order.MoveToFront(element)
+		return
+	}
+	c.entries[key] = c.order.PushFront(entry)
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+// SetCacheOptions sizes the user and channel name caches. size is the number of
+// names kept per cache, ttl how long a name is trusted and negativeTTL how long a
+// failed lookup is remembered. Zero values keep the defaults. Existing entries are dropped.
+func (c *Client) SetCacheOptions(size int, ttl, negativeTTL time.Duration) {
+	c.users = newNameCache(size, ttl, negativeTTL)
+	c.channels = newNameCache(size, ttl, negativeTTL)
+}
+
+// WarmCaches fills the user and channel name caches through users.list and
+// conversations.list, so that the first summaries don't look up names one by one.
+func (c *Client) WarmCaches(ctx context.Context) error {
+	log.Println("Calling Slack API: users.list with pagination")
+	users := 0
+	page := c.api.GetUsersPaginated()
+	for {
+		err := c.call(ctx, "users.list", func(ctx context.Context) (err error) {
+			page, err = page.Next(ctx)
+			return err
+		})
+		if page.Done(err) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to list users: %w", err)
+		}
+		for _, user := range page.Users {
+			c.users.set(user.ID, user.Name)
+			users++
+		}
+	}
+
+	log.Println("Calling Slack API: conversations.list with pagination")
+	channels := 0
+	params := &slack.GetConversationsParameters{
+		ExcludeArchived: true,
+		Types:           []string{"public_channel", "private_channel"},
+		Limit:           pageSize,
+	}
+	for {
+		var page []slack.Channel
+		var nextCursor string
+		err := c.call(ctx, "conversations.list", func(ctx context.Context) (err error) {
+			page, nextCursor, err = c.api.GetConversationsContext(ctx, params)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("failed to list channels: %w", err)
+		}
+		for _, channel := range page {
+			c.channels.set(channel.ID, channel.Name)
+			channels++
+		}
+		if nextCursor == "" {
+			break
+		}
+		params.Cursor = nextCursor
+	}
+
+	log.Printf("Warmed Slack caches with %d users and %d channels", users, channels)
+	return nil
+}
@@ -0,0 +1,188 @@
+package slack
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeClock is a settable clock for nameCache.now.
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	c.mu.Unlock()
+}
+
+func newTestCache(size int, ttl, negativeTTL time.Duration) (*nameCache, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2024, time.May, 1, 9, 0, 0, 0, time.UTC)}
+	cache := newNameCache(size, ttl, negativeTTL)
+	cache.now = clock.Now
+	return cache, clock
+}
+
+// fetcher counts lookups per key and answers them with "name-<key>".
+type fetcher struct {
+	mu    sync.Mutex
+	calls map[string]int
+	err   error
+}
+
+func (f *fetcher) fetch(key string) func() (string, error) {
+	return func() (string, error) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		if f.calls == nil {
+			f.calls = make(map[string]int)
+		}
+		f.calls[key]++
+		if f.err != nil {
+			return "", f.err
+		}
+		return "name-" + key, nil
+	}
+}
+
+func (f *fetcher) count(key string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls[key]
+}
+
+func TestNameCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	cache, _ := newTestCache(2, time.Hour, time.Minute)
+	f := &fetcher{}
+
+	cache.get("a", f.fetch("a"))
+	cache.get("b", f.fetch("b"))
+	cache.get("a", f.fetch("a")) // a is now the most recently used.
+	cache.get("c", f.fetch("c")) // Evicts b.
+
+	for _, tt := range []struct {
+		key   string
+		calls int
+	}{
+		{"a", 1},
+		{"c", 1},
+		{"b", 2},
+	} {
+		name, err := cache.get(tt.key, f.fetch(tt.key))
+		if err != nil || name != "name-"+tt.key {
+			t.Errorf("get(%q) = %q, %v", tt.key, name, err)
+		}
+		if n := f.count(tt.key); n != tt.calls {
+			t.Errorf("%q fetched %d times, want %d", tt.key, n, tt.calls)
+		}
+	}
+	if n := cache.order.Len(); n != 2 {
+		t.Errorf("cache holds %d entries, want 2", n)
+	}
+}
+
+func TestNameCacheExpiry(t *testing.T) {
+	tests := []struct {
+		name    string
+		elapsed time.Duration
+		calls   int
+	}{
+		{"fresh", time.Minute, 1},
+		{"at the TTL", time.Hour, 1},
+		{"after the TTL", time.Hour + time.Second, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache, clock := newTestCache(10, time.Hour, time.Minute)
+			f := &fetcher{}
+			cache.get("U1", f.fetch("U1"))
+
+			clock.advance(tt.elapsed)
+			if name, err := cache.get("U1", f.fetch("U1")); err != nil || name != "name-U1" {
+				t.Fatalf("get = %q, %v", name, err)
+			}
+			if n := f.count("U1"); n != tt.calls {
+				t.Errorf("fetched %d times, want %d", n, tt.calls)
+			}
+		})
+	}
+}
+
+func TestNameCacheRemembersFailures(t *testing.T) {
+	cache, clock := newTestCache(10, time.Hour, 5*time.Minute)
+	f := &fetcher{err: errors.New("user_not_found")}
+
+	if _, err := cache.get("U404", f.fetch("U404")); err == nil || errors.Is(err, errCachedFailure) {
+		t.Fatalf("first lookup err = %v, want the fetch error", err)
+	}
+	clock.advance(5 * time.Minute)
+	if _, err := cache.get("U404", f.fetch("U404")); !errors.Is(err, errCachedFailure) {
+		t.Fatalf("err = %v, want errCachedFailure within the negative TTL", err)
+	}
+	if n := f.count("U404"); n != 1 {
+		t.Fatalf("fetched %d times within the negative TTL, want 1", n)
+	}
+
+	// After the negative TTL the key is looked up again, and a success is kept
+	// for the full TTL.
+	f.err = nil
+	clock.advance(time.Second)
+	if name, err := cache.get("U404", f.fetch("U404")); err != nil || name != "name-U404" {
+		t.Fatalf("get = %q, %v", name, err)
+	}
+	clock.advance(30 * time.Minute)
+	cache.get("U404", f.fetch("U404"))
+	if n := f.count("U404"); n != 2 {
+		t.Errorf("fetched %d times, want 2", n)
+	}
+}
+
+func TestNameCacheCollapsesConcurrentLookups(t *testing.T) {
+	cache, _ := newTestCache(10, time.Hour, time.Minute)
+	var calls atomic.Int32
+	started, release := make(chan struct{}), make(chan struct{})
+	fetch := func() (string, error) {
+		if calls.Add(1) == 1 {
+			close(started)
+		}
+		<-release
+		return "alice", nil
+	}
+
+	const lookups = 10
+	names := make(chan string, lookups)
+	var wg sync.WaitGroup
+	for i := 0; i < lookups; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			name, _ := cache.get("U1", fetch)
+			names <- name
+		}()
+	}
+	<-started
+	// Lookups that arrive while the first is running join it; later ones hit the cache.
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	close(names)
+
+	for name := range names {
+		if name != "alice" {
+			t.Errorf("name = %q, want alice", name)
+		}
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("fetched %d times, want 1", n)
+	}
+}
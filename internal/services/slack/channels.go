@@ -0,0 +1,212 @@
+package slack
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"path"
+	"strings"
+
+	"github.com/slack-go/slack"
+)
+
+// Reasons reported for channels left out of a selection.
+const (
+	SkipExcluded   = "excluded by configuration"
+	SkipNotListed  = "not in the configured channel list"
+	SkipName       = "name doesn't match the configured patterns"
+	SkipNotMember  = "you are not a member"
+	SkipBotMissing = "I'm not a member"
+	SkipLimit      = "over the channel limit"
+)
+
+// ChannelScope selects the channels covered by a workspace-wide summary.
+type ChannelScope struct {
+	// Include lists channel IDs or names. When set, only these channels are considered.
+	Include []string
+	// Exclude lists channel IDs or names that are never summarized.
+	Exclude []string
+	// Types are the conversation types considered: public_channel, private_channel,
+	// mpim and im. Empty means public_channel only.
+	Types []string
+	// NamePatterns are glob patterns such as "team-*"; when set, a channel's name
+	// must match one of them.
+	NamePatterns []string
+	// MemberOnly keeps only channels the requesting user is a member of.
+	MemberOnly bool
+	// MaxChannels caps the number of selected channels. 0 means no cap.
+	MaxChannels int
+}
+
+// SkippedChannel is a channel left out of a selection.
+type SkippedChannel struct {
+	ID     string
+	Name   string
+	Reason string
+}
+
+// ChannelSelection is the result of SelectChannels.
+type ChannelSelection struct {
+	// Channels are the IDs of the selected channels.
+	Channels []string
+	Skipped  []SkippedChannel
+	// Hidden counts the private conversations left out because the user is not a
+	// member. They are not listed in Skipped so that their names don't leak.
+	Hidden int
+}
+
+// SelectChannels returns the channels the bot is a member of that fall within
+// scope for userID, and the channels that were left out and why. Private channels,
+// group DMs and DMs are only selected if userID is a member of them.
+func (c *Client) SelectChannels(ctx context.Context, scope ChannelScope, userID string) (*ChannelSelection, error) {
+	types := scope.Types
+	if len(types) == 0 {
+		types = []string{"public_channel"}
+	}
+
+	candidates, err := c.listConversations(ctx, "", types)
+	if err != nil {
+		return nil, err
+	}
+
+	// The user's own conversations decide access to non-public conversations
+	// and, with MemberOnly, to public channels too.
+	userTypes := types
+	if !scope.MemberOnly {
+		userTypes = nil
+		for _, t := range types {
+			for _, private := range nonPublicTypes {
+				if t == private {
+					userTypes = append(userTypes, t)
+				}
+			}
+		}
+	}
+	userChannels := make(map[string]bool)
+	if len(userTypes) > 0 {
+		channels, err := c.listConversations(ctx, userID, userTypes)
+		if err != nil {
+			return nil, err
+		}
+		for _, channel := range channels {
+			userChannels[channel.ID] = true
+		}
+	}
+
+	selection := &ChannelSelection{}
+	skip := func(channel slack.Channel, reason string) {
+		selection.Skipped = append(selection.Skipped, SkippedChannel{ID: channel.ID, Name: channel.Name, Reason: reason})
+	}
+	for _, channel := range candidates {
+		if !isPublic(channel) && !userChannels[channel.ID] {
+			selection.Hidden++
+			continue
+		}
+		if channel.Name != "" {
+			c.channels.set(channel.ID, channel.Name)
+		}
+		switch {
+		case matchesChannel(scope.Exclude, channel):
+			skip(channel, SkipExcluded)
+		case len(scope.Include) > 0 && !matchesChannel(scope.Include, channel):
+			skip(channel, SkipNotListed)
+		case len(scope.NamePatterns) > 0 && !matchesName(scope.NamePatterns, channel.Name):
+			skip(channel, SkipName)
+		case scope.MemberOnly && !userChannels[channel.ID]:
+			skip(channel, SkipNotMember)
+		case scope.MaxChannels > 0 && len(selection.Channels) >= scope.MaxChannels:
+			skip(channel, fmt.Sprintf("%s of %d", SkipLimit, scope.MaxChannels))
+		default:
+			selection.Channels = append(selection.Channels, channel.ID)
+		}
+	}
+
+	// Configured channels the bot can't read are reported rather than silently ignored.
+	for _, entry := range scope.Include {
+		found := false
+		for _, channel := range candidates {
+			if matchesChannel([]string{entry}, channel) {
+				found = true
+				break
+			}
+		}
+		if found {
+			continue
+		}
+		missing := SkippedChannel{Name: strings.TrimPrefix(entry, "#"), Reason: SkipBotMissing}
+		if isChannelID(entry) {
+			missing = SkippedChannel{ID: entry, Reason: SkipBotMissing}
+		}
+		selection.Skipped = append(selection.Skipped, missing)
+	}
+
+	log.Printf("Selected %d channels for user %s, skipped %d, hid %d private", len(selection.Channels), userID, len(selection.Skipped), selection.Hidden)
+	return selection, nil
+}
+
+// listConversations pages through users.conversations for userID, or for the bot
+// when userID is empty.
+func (c *Client) listConversations(ctx context.Context, userID string, types []string) ([]slack.Channel, error) {
+	if userID == "" {
+		log.Println("Calling Slack API: users.conversations with pagination")
+	} else {
+		log.Printf("Calling Slack API: users.conversations with pagination for user %s", userID)
+	}
+	params := &slack.GetConversationsForUserParameters{
+		UserID:          userID,
+		ExcludeArchived: true,
+		Types:           types,
+		Limit:           pageSize,
+	}
+
+	var all []slack.Channel
+	for {
+		var channels []slack.Channel
+		var nextCursor string
+		err := c.call(ctx, "users.conversations", func(ctx context.Context) (err error) {
+			channels, nextCursor, err = c.api.GetConversationsForUserContext(ctx, params)
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get user conversations: %w", err)
+		}
+		all = append(all, channels...)
+		if nextCursor == "" {
+			return all, nil
+		}
+		params.Cursor = nextCursor
+	}
+}
+
+// matchesChannel reports whether entries contain the channel's ID or name.
+// Names may be written with or without a leading '#'.
+func matchesChannel(entries []string, channel slack.Channel) bool {
+	for _, entry := range entries {
+		if entry == channel.ID || (channel.Name != "" && strings.EqualFold(strings.TrimPrefix(entry, "#"), channel.Name)) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesName reports whether name matches one of the glob patterns.
+func matchesName(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, err := path.Match(strings.ToLower(strings.TrimPrefix(pattern, "#")), strings.ToLower(name)); err == nil && ok {
+			return true
+		}
+	}
+	return false
+}
+
+// isChannelID reports whether s looks like a conversation ID rather than a name.
+func isChannelID(s string) bool {
+	if len(s) < 9 || strings.ToUpper(s) != s {
+		return false
+	}
+	switch s[0] {
+	case 'C', 'G', 'D':
+		return true
+	}
+	return false
+}
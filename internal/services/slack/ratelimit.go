@@ -0,0 +1,208 @@
+package slack
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math/rand/v2"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/slack-go/slack"
+	"golang.org/x/time/rate"
+)
+
+// Requests per minute allowed by Slack's rate-limit tiers.
+// See https://api.slack.com/apis/rate-limits.
+const (
+	tier2 = 20
+	tier3 = 50
+	tier4 = 100
+	// tierPost approximates the workspace-wide limit of chat.postMessage, which
+	// Slack documents as one message per second per channel.
+	tierPost = 300
+)
+
+const (
+	defaultMaxRetries = 5
+	backoffBase       = 500 * time.Millisecond
+	backoffMax        = 30 * time.Second
+)
+
+// methodTiers maps the Slack methods the client calls to their tier.
+// Methods not listed use tier 3.
+var methodTiers = map[string]int{
+	"auth.test":             tier4,
+	"chat.postMessage":      tierPost,
+	"chat.postEphemeral":    tier4,
+	"chat.getPermalink":     tier4,
+	"conversations.history": tier3,
+	"conversations.replies": tier3,
+	"conversations.info":    tier3,
+	"conversations.list":    tier2,
+	"users.conversations":   tier3,
+	"users.info":            tier4,
+	"users.list":            tier2,
+	"usergroups.list":       tier2,
+	"views.open":            tier4,
+	"views.update":          tier4,
+	"search.messages":       tier2,
+}
+
+// writeMethods are not retried on transient errors because Slack may already
+// have applied them; they are only retried when rate limited.
+var writeMethods = map[string]bool{
+	"chat.postMessage":   true,
+	"chat.postEphemeral": true,
+	"views.open":         true,
+	"views.update":       true,
+}
+
+// budget paces the calls of one method.
+type budget struct {
+	limiter *rate.Limiter
+
+	mu sync.Mutex
+	// until is when Slack's Retry-After for this method expires.
+	until time.Time
+}
+
+func newBudget(perMinute int) *budget {
+	burst := perMinute / 10
+	if burst < 1 {
+		burst = 1
+	}
+	return &budget{limiter: rate.NewLimiter(rate.Limit(float64(perMinute)/60), burst)}
+}
+
+// wait blocks until a call may be made or ctx is done.
+func (b *budget) wait(ctx context.Context) error {
+	b.mu.Lock()
+	pause := time.Until(b.until)
+	b.mu.Unlock()
+	if pause > 0 {
+		if err := sleep(ctx, pause); err != nil {
+			return err
+		}
+	}
+	return b.limiter.Wait(ctx)
+}
+
+// pause holds back every caller of the method for d.
+func (b *budget) pause(d time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if until := time.Now().Add(d); until.After(b.until) {
+		b.until = until
+	}
+}
+
+// SetRateLimit overrides the number of requests per minute the client makes to
+// a Slack method, e.g. for apps subject to stricter limits. Non-positive values are ignored.
+func (c *Client) SetRateLimit(method string, perMinute int) {
+	if perMinute <= 0 {
+		return
+	}
+	c.budgetMutex.Lock()
+	defer c.budgetMutex.Unlock()
+	c.budgets[method] = newBudget(perMinute)
+}
+
+// SetMaxRetries sets how often a rate-limited or failed call is retried. Negative values keep the default.
+func (c *Client) SetMaxRetries(n int) {
+	if n >= 0 {
+		c.maxRetries = n
+	}
+}
+
+func (c *Client) budgetFor(method string) *budget {
+	c.budgetMutex.Lock()
+	defer c.budgetMutex.Unlock()
+	b, ok := c.budgets[method]
+	if !ok {
+		perMinute, ok := methodTiers[method]
+		if !ok {
+			perMinute = tier3
+		}
+		b = newBudget(perMinute)
+		c.budgets[method] = b
+	}
+	return b
+}
+
+// call runs fn within method's budget. Rate-limited calls are retried after
+// Slack's Retry-After, and transient failures of read methods with jittered
+// exponential backoff, until the retries are used up or ctx is done.
+func (c *Client) call(ctx context.Context, method string, fn func(ctx context.Context) error) error {
+	b := c.budgetFor(method)
+	for attempt := 0; ; attempt++ {
+		if err := b.wait(ctx); err != nil {
+			return err
+		}
+		err := fn(ctx)
+		if err == nil {
+			return nil
+		}
+		if attempt >= c.maxRetries || ctx.Err() != nil {
+			return err
+		}
+
+		var delay time.Duration
+		var rateLimited *slack.RateLimitedError
+		switch {
+		case errors.As(err, &rateLimited):
+			delay = rateLimited.RetryAfter
+			b.pause(delay)
+		case !writeMethods[method] && transient(err):
+			delay = backoff(attempt)
+		default:
+			return err
+		}
+		log.Printf("Slack API %s failed (attempt %d of %d), retrying in %s: %v", method, attempt+1, c.maxRetries+1, delay.Round(time.Millisecond), err)
+		if err := sleep(ctx, delay); err != nil {
+			return err
+		}
+	}
+}
+
+// transient reports whether err is worth retrying: server errors, network
+// errors and the Slack error codes that indicate a temporary problem.
+func transient(err error) bool {
+	var status slack.StatusCodeError
+	if errors.As(err, &status) {
+		return status.Retryable()
+	}
+	var slackErr slack.SlackErrorResponse
+	if errors.As(err, &slackErr) {
+		switch slackErr.Err {
+		case "internal_error", "fatal_error", "service_unavailable", "request_timeout":
+			return true
+		}
+		return false
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// backoff returns the delay before retry attempt+1: it doubles with every attempt
+// and is randomized between half and the full delay so that callers spread out.
+func backoff(attempt int) time.Duration {
+	d := backoffBase << attempt
+	if d <= 0 || d > backoffMax {
+		d = backoffMax
+	}
+	return d/2 + rand.N(d/2+1)
+}
+
+// sleep waits for d or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
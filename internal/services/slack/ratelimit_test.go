@@ -0,0 +1,156 @@
+package slack
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/slack-go/slack"
+)
+
+const permalinkOK = `{"ok":true,"channel":"C1","permalink":"https://example.slack.com/archives/C1/p1"}`
+
+// failing answers the first n requests with status and later ones with body.
+func failing(n int32, status int, retryAfter string, body string) fakeHandler {
+	var calls int32
+	return func(w http.ResponseWriter, r *http.Request) (int, string) {
+		if atomic.AddInt32(&calls, 1) > n {
+			return http.StatusOK, body
+		}
+		if retryAfter != "" {
+			w.Header().Set("Retry-After", retryAfter)
+		}
+		return status, `{"ok":false}`
+	}
+}
+
+func TestCallWaitsForRetryAfter(t *testing.T) {
+	client, fake := newFakeSlack(t, map[string]fakeHandler{
+		"chat.getPermalink": failing(1, http.StatusTooManyRequests, "1", permalinkOK),
+	})
+
+	start := time.Now()
+	link, err := client.GetPermalink("C1", "1.000001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if link == "" {
+		t.Error("no permalink after the retry")
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("retried after %s, want at least the Retry-After of 1s", elapsed)
+	}
+	if n := fake.count("chat.getPermalink"); n != 2 {
+		t.Errorf("made %d calls, want 2", n)
+	}
+}
+
+func TestCallRetriesRateLimitedWrites(t *testing.T) {
+	client, fake := newFakeSlack(t, map[string]fakeHandler{
+		"chat.postMessage": failing(1, http.StatusTooManyRequests, "0", `{"ok":true,"channel":"C1","ts":"1.000001"}`),
+	})
+
+	if err := client.SendMessage("C1", "hello"); err != nil {
+		t.Fatal(err)
+	}
+	if n := fake.count("chat.postMessage"); n != 2 {
+		t.Errorf("made %d calls, want 2", n)
+	}
+}
+
+func TestCallBacksOffOnServerErrors(t *testing.T) {
+	client, fake := newFakeSlack(t, map[string]fakeHandler{
+		"chat.getPermalink": failing(2, http.StatusServiceUnavailable, "", permalinkOK),
+	})
+
+	start := time.Now()
+	if _, err := client.GetPermalink("C1", "1.000001"); err != nil {
+		t.Fatal(err)
+	}
+	if n := fake.count("chat.getPermalink"); n != 3 {
+		t.Errorf("made %d calls, want 3", n)
+	}
+	// Two backoffs of at least half of 500ms and 1s.
+	if elapsed := time.Since(start); elapsed < 750*time.Millisecond {
+		t.Errorf("retried after %s, want exponential backoff", elapsed)
+	}
+}
+
+func TestCallDoesNotRetryFailedWrites(t *testing.T) {
+	client, fake := newFakeSlack(t, map[string]fakeHandler{
+		"chat.postMessage": failing(1, http.StatusServiceUnavailable, "", `{"ok":true,"channel":"C1","ts":"1.000001"}`),
+	})
+
+	if err := client.SendMessage("C1", "hello"); err == nil {
+		t.Error("expected the failed write to be reported")
+	}
+	if n := fake.count("chat.postMessage"); n != 1 {
+		t.Errorf("made %d calls, want 1 since the message may already be posted", n)
+	}
+}
+
+func TestCallGivesUpAfterMaxRetries(t *testing.T) {
+	client, fake := newFakeSlack(t, map[string]fakeHandler{
+		"chat.getPermalink": failing(100, http.StatusTooManyRequests, "0", permalinkOK),
+	})
+	client.SetMaxRetries(2)
+
+	_, err := client.GetPermalink("C1", "1.000001")
+	var rateLimited *slack.RateLimitedError
+	if !errors.As(err, &rateLimited) {
+		t.Fatalf("err = %v, want the last rate-limit error", err)
+	}
+	if n := fake.count("chat.getPermalink"); n != 3 {
+		t.Errorf("made %d calls, want 1 plus 2 retries", n)
+	}
+}
+
+func TestCallStopsRetryingWhenContextIsDone(t *testing.T) {
+	client, fake := newFakeSlack(t, map[string]fakeHandler{
+		"conversations.info": failing(100, http.StatusTooManyRequests, "30", `{"ok":true,"channel":{"id":"C1"}}`),
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := client.CanRead(ctx, "U1", "C1"); err == nil {
+		t.Fatal("expected an error once the context is done")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("waited %s despite the deadline", elapsed)
+	}
+	if n := fake.count("conversations.info"); n != 1 {
+		t.Errorf("made %d calls, want 1", n)
+	}
+}
+
+func TestRateLimitIsPerMethod(t *testing.T) {
+	client, _ := newFakeSlack(t, map[string]fakeHandler{
+		"chat.getPermalink":  ok(permalinkOK),
+		"conversations.info": ok(`{"ok":true,"channel":{"id":"C1","name":"general"}}`),
+	})
+	// 120 a minute allows a burst of 12 and then one call every 500ms.
+	client.SetRateLimit("chat.getPermalink", 120)
+
+	start := time.Now()
+	for i := 0; i < 14; i++ {
+		if _, err := client.GetPermalink("C1", "1.000001"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 900*time.Millisecond {
+		t.Errorf("14 calls took %s, want the budget to pace them to about 1s", elapsed)
+	}
+
+	// Other methods keep their own budget.
+	start = time.Now()
+	if _, err := client.CanRead(context.Background(), "U1", "C1"); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > 200*time.Millisecond {
+		t.Errorf("conversations.info waited %s for another method's budget", elapsed)
+	}
+}
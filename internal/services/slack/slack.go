@@ -1,8 +1,8 @@
 package slack
 
 import (
+	"context"
 	"encoding/json"
-	"fmt"
 	"log"
 	"strconv"
 	"strings"
@@ -10,30 +10,93 @@ import (
 	"time"
 
 	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/socketmode"
 )
 
 // Client is a Slack client that uses the slack-go library.
 type Client struct {
-	api          *slack.Client
-	userCache    map[string]string
-	channelCache map[string]string
-	cacheMutex   sync.Mutex
+	api   *slack.Client
+	token string
+	// users and channels cache names by ID.
+	users    *nameCache
+	channels *nameCache
+	// maxThreadReplies caps the messages fetched for a single thread.
+	maxThreadReplies int
+	// maxHistoryMessages caps the messages fetched from a channel's history.
+	maxHistoryMessages int
+	// threadLookback is how far before a window ExpandThreads looks for
+	// threads with replies in the window.
+	threadLookback time.Duration
+
+	// budgets paces calls per Slack method; see call.
+	budgets     map[string]*budget
+	budgetMutex sync.Mutex
+	maxRetries  int
 }
 
-// New creates a new Slack client.
+const (
+	defaultMaxThreadReplies   = 50
+	defaultMaxHistoryMessages = 1000
+	defaultThreadLookback     = 7 * 24 * time.Hour
+	// pageSize is the number of messages requested per page; Slack recommends at most 200.
+	pageSize = 200
+)
+
+// History is the result of reading a channel's history.
+type History struct {
+	// Messages are in chronological order.
+	Messages []slack.Message
+	// Truncated is set when the window held more messages than the history cap.
+	// Messages then holds only the most recent ones.
+	Truncated bool
+}
+
+// New creates a new Slack client. API calls are paced per method according to
+// Slack's rate-limit tiers and retried when Slack rate limits them.
 func New(token string) *Client {
 	api := slack.New(token)
 	return &Client{
-		api:          api,
-		userCache:    make(map[string]string),
-		channelCache: make(map[string]string),
+		api:      api,
+		token:    token,
+		users:    newNameCache(0, 0, 0),
+		channels: newNameCache(0, 0, 0),
+
+		maxThreadReplies:   defaultMaxThreadReplies,
+		maxHistoryMessages: defaultMaxHistoryMessages,
+		threadLookback:     defaultThreadLookback,
+		budgets:            make(map[string]*budget),
+		maxRetries:         defaultMaxRetries,
+	}
+}
+
+// SetAPIURL points the client at a different Slack API base URL, such as a
+// proxy or a fake server for testing. An empty URL keeps the default.
+func (c *Client) SetAPIURL(apiURL string) {
+	if apiURL == "" {
+		return
+	}
+	if !strings.HasSuffix(apiURL, "/") {
+		apiURL += "/"
 	}
+	c.api = slack.New(c.token, slack.OptionAPIURL(apiURL))
+}
+
+// NewSocketMode creates a Socket Mode client that receives events over a
+// WebSocket instead of HTTP requests. appToken is an app-level token (xapp-...)
+// with the connections:write scope.
+func NewSocketMode(token, appToken string) *socketmode.Client {
+	return socketmode.New(slack.New(token, slack.OptionAppLevelToken(appToken)))
 }
 
 // AuthTest calls the auth.test API method to get information about the bot.
 func (c *Client) AuthTest() (*slack.AuthTestResponse, error) {
 	log.Println("Calling Slack API: auth.test")
-	return c.api.AuthTest()
+	var resp *slack.AuthTestResponse
+	err := c.call(context.Background(), "auth.test", func(ctx context.Context) (err error) {
+		resp, err = c.api.AuthTestContext(ctx)
+		return err
+	})
+	return resp, err
 }
 
 // SendMessage sends a message to a Slack channel using blocks.
@@ -45,18 +108,42 @@ func (c *Client) SendMessage(channel, message string) error {
 	err := json.Unmarshal([]byte(message), &blocks)
 	if err == nil {
 		// If unmarshalling succeeds, send the blocks.
-		_, _, postErr := c.api.PostMessage(channel, slack.MsgOptionBlocks(blocks.BlockSet...))
-		return postErr
+		return c.postMessage(channel, slack.MsgOptionBlocks(blocks.BlockSet...))
 	}
 
 	// If unmarshalling fails, assume it's a plain text message and use formatText.
 	log.Printf("Could not unmarshal message as JSON blocks, formatting as plain text: %v", err)
-	formattedBlocks := c.formatText(message)
-	_, _, postErr := c.api.PostMessage(
+	formattedBlocks := formatText(message)
+	return c.postMessage(
 		channel,
 		slack.MsgOptionBlocks(formattedBlocks...),
 	)
-	return postErr
+}
+
+// SendMessageInThread sends a message as a reply in the thread started by threadTS.
+func (c *Client) SendMessageInThread(channel, threadTS, message string) error {
+	log.Printf("Calling Slack API: chat.postMessage to thread %s in channel %s", threadTS, channel)
+
+	var blocks slack.Blocks
+	if err := json.Unmarshal([]byte(message), &blocks); err == nil {
+		return c.postMessage(channel, slack.MsgOptionTS(threadTS), slack.MsgOptionBlocks(blocks.BlockSet...))
+	}
+
+	return c.postMessage(channel, slack.MsgOptionTS(threadTS), slack.MsgOptionBlocks(formatText(message)...))
+}
+
+func (c *Client) postMessage(channel string, options ...slack.MsgOption) error {
+	return c.call(context.Background(), "chat.postMessage", func(ctx context.Context) error {
+		_, _, err := c.api.PostMessageContext(ctx, channel, options...)
+		return err
+	})
+}
+
+func (c *Client) postEphemeral(channelID, userID string, options ...slack.MsgOption) error {
+	return c.call(context.Background(), "chat.postEphemeral", func(ctx context.Context) error {
+		_, err := c.api.PostEphemeralContext(ctx, channelID, userID, options...)
+		return err
+	})
 }
 
 // SendEphemeralMessage sends an ephemeral message to a user in a channel.
@@ -68,18 +155,30 @@ func (c *Client) SendEphemeralMessage(channelID, userID, message string) error {
 	err := json.Unmarshal([]byte(message), &blocks)
 	if err == nil {
 		// If unmarshalling succeeds, send the blocks.
-		_, err := c.api.PostEphemeral(channelID, userID, slack.MsgOptionBlocks(blocks.BlockSet...))
-		return err
+		return c.postEphemeral(channelID, userID, slack.MsgOptionBlocks(blocks.BlockSet...))
 	}
 
 	// If unmarshalling fails, assume it's a plain text message and use formatText.
 	log.Printf("Could not unmarshal message as JSON blocks, formatting as plain text: %v", err)
-	formattedBlocks := c.formatText(message)
-	_, err = c.api.PostEphemeral(channelID, userID, slack.MsgOptionBlocks(formattedBlocks...))
-	return err
+	formattedBlocks := formatText(message)
+	return c.postEphemeral(channelID, userID, slack.MsgOptionBlocks(formattedBlocks...))
 }
 
-func (c *Client) formatText(message string) []slack.Block {
+// SendEphemeralMessageInThread sends an ephemeral message to a user inside the thread started by threadTS.
+func (c *Client) SendEphemeralMessageInThread(channelID, threadTS, userID, message string) error {
+	log.Printf("Calling Slack API: chat.postEphemeral to thread %s in channel %s for user %s", threadTS, channelID, userID)
+
+	var blocks slack.Blocks
+	if err := json.Unmarshal([]byte(message), &blocks); err == nil {
+		return c.postEphemeral(channelID, userID, slack.MsgOptionTS(threadTS), slack.MsgOptionBlocks(blocks.BlockSet...))
+	}
+
+	return c.postEphemeral(channelID, userID, slack.MsgOptionTS(threadTS), slack.MsgOptionBlocks(formatText(message)...))
+}
+
+// formatText deterministically renders plain text as section blocks, one per line,
+// while staying within the Block Kit limits.
+func formatText(message string) []slack.Block {
 	var blocks []slack.Block
 	lines := strings.Split(message, "\n")
 
@@ -88,6 +187,13 @@ func (c *Client) formatText(message string) []slack.Block {
 		if line == "" {
 			continue
 		}
+		if len(blocks) == MaxBlocks-1 {
+			blocks = append(blocks, slack.NewContextBlock("", slack.NewTextBlockObject("mrkdwn", "_The rest of this message was cut to fit Slack's limits._", false, false)))
+			break
+		}
+		if runes := []rune(line); len(runes) > MaxSectionTextLength-10 {
+			line = string(runes[:MaxSectionTextLength-10]) + "…"
+		}
 
 		var textObj *slack.TextBlockObject
 
@@ -113,110 +219,345 @@ func (c *Client) formatText(message string) []slack.Block {
 	return blocks
 }
 
-// GetConversationHistory fetches the conversation history from a channel.
-func (c *Client) GetConversationHistory(channelID string, start, end time.Time) ([]slack.Message, error) {
-	log.Printf("Calling Slack API: conversations.history for channel %s", channelID)
+// GetConversationHistory fetches the conversation history of a channel between
+// start and end, following pagination up to the history cap. Slack returns the
+// newest messages first, so a truncated history keeps the most recent messages.
+func (c *Client) GetConversationHistory(ctx context.Context, channelID string, start, end time.Time) (*History, error) {
 	params := &slack.GetConversationHistoryParameters{
 		ChannelID: channelID,
 		Oldest:    strconv.FormatInt(start.Unix(), 10),
 		Latest:    strconv.FormatInt(end.Unix(), 10),
+		Limit:     pageSize,
 	}
 
-	history, err := c.api.GetConversationHistory(params)
-	if err != nil {
-		return nil, err
+	result := &History{}
+	for {
+		log.Printf("Calling Slack API: conversations.history for channel %s", channelID)
+		var page *slack.GetConversationHistoryResponse
+		err := c.call(ctx, "conversations.history", func(ctx context.Context) (err error) {
+			page, err = c.api.GetConversationHistoryContext(ctx, params)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		remaining := c.maxHistoryMessages - len(result.Messages)
+		if len(page.Messages) > remaining {
+			result.Messages = append(result.Messages, page.Messages[:remaining]...)
+			result.Truncated = true
+			break
+		}
+		result.Messages = append(result.Messages, page.Messages...)
+
+		if !page.HasMore || page.ResponseMetaData.NextCursor == "" {
+			break
+		}
+		if len(result.Messages) >= c.maxHistoryMessages {
+			result.Truncated = true
+			break
+		}
+		params.Cursor = page.ResponseMetaData.NextCursor
+	}
+	if result.Truncated {
+		log.Printf("Channel %s has more than %d messages in the window; older messages are skipped", channelID, c.maxHistoryMessages)
 	}
 
 	// Reverse the messages to be in chronological order
-	for i, j := 0, len(history.Messages)-1; i < j; i, j = i+1, j-1 {
-		history.Messages[i], history.Messages[j] = history.Messages[j], history.Messages[i]
+	for i, j := 0, len(result.Messages)-1; i < j; i, j = i+1, j-1 {
+		result.Messages[i], result.Messages[j] = result.Messages[j], result.Messages[i]
 	}
 
-	return history.Messages, nil
+	return result, nil
 }
 
-// GetUserName fetches a user's name from the cache or the API.
-func (c *Client) GetUserName(userID string) string {
-	c.cacheMutex.Lock()
-	defer c.cacheMutex.Unlock()
+// GetThreadReplies fetches the messages of a thread, starting with the parent message,
+// following pagination up to the per-thread reply cap.
+func (c *Client) GetThreadReplies(ctx context.Context, channelID, threadTS string) ([]slack.Message, error) {
+	return c.getReplies(ctx, channelID, threadTS, "", "")
+}
 
-	if userName, ok := c.userCache[userID]; ok {
-		return userName
+// ExpandThreads returns messages, in order, with the replies each thread received
+// between start and end inserted right after its parent. Threads started in the
+// thread lookback before start that received replies in the window come first,
+// each with its parent. At most the per-thread reply cap is fetched for each
+// thread. Threads that can't be read are left collapsed.
+func (c *Client) ExpandThreads(ctx context.Context, channelID string, messages []slack.Message, start, end time.Time) []slack.Message {
+	oldest := strconv.FormatInt(start.Unix(), 10)
+	latest := strconv.FormatInt(end.Unix(), 10)
+
+	listed := make(map[string]bool, len(messages))
+	for _, msg := range messages {
+		listed[msg.Timestamp] = true
 	}
 
-	user, err := c.api.GetUserInfo(userID)
-	if err != nil {
-		log.Printf("Error getting user info for %s: %v", userID, err)
-		return userID // Fallback to user ID
+	expanded := make([]slack.Message, 0, len(messages))
+	if c.threadLookback > 0 {
+		older, err := c.GetConversationHistory(ctx, channelID, start.Add(-c.threadLookback), start)
+		if err != nil {
+			log.Printf("Error fetching older threads in channel %s: %v", channelID, err)
+		} else {
+			for _, parent := range older.Messages {
+				// Only the parent's latest reply tells whether the thread continued in the window.
+				if listed[parent.Timestamp] || !isThreadParent(parent) || parent.LatestReply == "" || parent.LatestReply < oldest {
+					continue
+				}
+				parent.Channel = channelID
+				if replies := c.threadReplies(ctx, channelID, parent, oldest, latest); len(replies) > 0 {
+					expanded = append(expanded, parent)
+					expanded = append(expanded, replies...)
+				}
+			}
+		}
 	}
 
-	c.userCache[userID] = user.Name
-	return user.Name
+	for _, msg := range messages {
+		expanded = append(expanded, msg)
+		if !isThreadParent(msg) {
+			continue
+		}
+		// Skip threads whose latest reply is older than the window.
+		if msg.LatestReply != "" && msg.LatestReply < oldest {
+			continue
+		}
+		expanded = append(expanded, c.threadReplies(ctx, channelID, msg, oldest, latest)...)
+	}
+	return expanded
 }
 
-// GetChannelName fetches a channel's name from the cache or the API.
-func (c *Client) GetChannelName(channelID string) string {
-	c.cacheMutex.Lock()
-	defer c.cacheMutex.Unlock()
+// isThreadParent reports whether msg started a thread that has replies.
+func isThreadParent(msg slack.Message) bool {
+	return msg.ReplyCount > 0 && (msg.ThreadTimestamp == "" || msg.ThreadTimestamp == msg.Timestamp)
+}
 
-	if channelName, ok := c.channelCache[channelID]; ok {
-		return channelName
+// threadReplies returns the replies to parent between oldest and latest, without
+// the parent itself, or nothing if the thread can't be read.
+func (c *Client) threadReplies(ctx context.Context, channelID string, parent slack.Message, oldest, latest string) []slack.Message {
+	messages, err := c.getReplies(ctx, channelID, parent.Timestamp, oldest, latest)
+	if err != nil {
+		log.Printf("Error fetching replies to %s in channel %s: %v", parent.Timestamp, channelID, err)
+		return nil
+	}
+	var replies []slack.Message
+	for _, reply := range messages {
+		if reply.Timestamp != parent.Timestamp {
+			replies = append(replies, reply)
+		}
 	}
+	return replies
+}
 
-	channel, err := c.api.GetConversationInfo(&slack.GetConversationInfoInput{ChannelID: channelID})
-	if err != nil {
-		log.Printf("Error getting channel info for %s: %v", channelID, err)
-		return channelID // Fallback to channel ID
+// SetThreadLookback sets how long before a window ExpandThreads looks for
+// threads that continued into it. Negative values turn this off and zero keeps
+// the default of one week.
+func (c *Client) SetThreadLookback(d time.Duration) {
+	switch {
+	case d < 0:
+		c.threadLookback = 0
+	case d > 0:
+		c.threadLookback = d
 	}
+}
+
+// SetMaxHistoryMessages caps the number of messages read from a channel's history.
+// Non-positive values keep the default.
+func (c *Client) SetMaxHistoryMessages(n int) {
+	if n > 0 {
+		c.maxHistoryMessages = n
+	}
+}
 
-	c.channelCache[channelID] = channel.Name
-	return channel.Name
+// SetMaxThreadReplies caps the number of messages fetched per thread. Non-positive values keep the default.
+func (c *Client) SetMaxThreadReplies(n int) {
+	if n > 0 {
+		c.maxThreadReplies = n
+	}
 }
 
-// GetPublicChannels fetches a list of all channels the bot is a member of, using cursor pagination.
-func (c *Client) GetPublicChannels() ([]string, error) {
-	log.Println("Calling Slack API: users.conversations with pagination")
-	var allChannelIDs []string
+// getReplies pages through conversations.replies for one thread, optionally limited
+// to the oldest/latest window, until the per-thread cap is reached.
+func (c *Client) getReplies(ctx context.Context, channelID, threadTS, oldest, latest string) ([]slack.Message, error) {
+	var messages []slack.Message
+	seen := make(map[string]bool)
 	cursor := ""
 
 	for {
-		params := &slack.GetConversationsForUserParameters{
-			ExcludeArchived: true,
-			Types:           []string{"public_channel", "private_channel", "mpim", "im"}, // Fetching all types of conversations
-			Cursor:          cursor,
-			Limit:           100, // Fetch up to 100 channels per page
+		log.Printf("Calling Slack API: conversations.replies for thread %s in channel %s", threadTS, channelID)
+		var page []slack.Message
+		var hasMore bool
+		var nextCursor string
+		err := c.call(ctx, "conversations.replies", func(ctx context.Context) (err error) {
+			page, hasMore, nextCursor, err = c.api.GetConversationRepliesContext(ctx, &slack.GetConversationRepliesParameters{
+				ChannelID: channelID,
+				Timestamp: threadTS,
+				Cursor:    cursor,
+				Oldest:    oldest,
+				Latest:    latest,
+				Limit:     pageSize,
+			})
+			return err
+		})
+		if err != nil {
+			return nil, err
 		}
 
-		channels, nextCursor, err := c.api.GetConversationsForUser(params)
+		for _, msg := range page {
+			// The parent message may be repeated on every page.
+			if seen[msg.Timestamp] {
+				continue
+			}
+			seen[msg.Timestamp] = true
+			msg.Channel = channelID
+			messages = append(messages, msg)
+			if len(messages) >= c.maxThreadReplies {
+				log.Printf("Thread %s in channel %s has more than %d messages; the rest are skipped", threadTS, channelID, c.maxThreadReplies)
+				return messages, nil
+			}
+		}
+		if !hasMore || nextCursor == "" {
+			return messages, nil
+		}
+		cursor = nextCursor
+	}
+}
+
+// GetPermalink returns a link to a message.
+func (c *Client) GetPermalink(channelID, ts string) (string, error) {
+	log.Printf("Calling Slack API: chat.getPermalink for message %s in channel %s", ts, channelID)
+	var permalink string
+	err := c.call(context.Background(), "chat.getPermalink", func(ctx context.Context) (err error) {
+		permalink, err = c.api.GetPermalinkContext(ctx, &slack.PermalinkParameters{Channel: channelID, Ts: ts})
+		return err
+	})
+	return permalink, err
+}
+
+// OpenView opens a modal in response to an interaction that supplied triggerID.
+func (c *Client) OpenView(triggerID string, view slack.ModalViewRequest) (*slack.ViewResponse, error) {
+	log.Println("Calling Slack API: views.open")
+	var resp *slack.ViewResponse
+	err := c.call(context.Background(), "views.open", func(ctx context.Context) (err error) {
+		resp, err = c.api.OpenViewContext(ctx, triggerID, view)
+		return err
+	})
+	return resp, err
+}
+
+// UpdateView replaces the content of an open modal.
+func (c *Client) UpdateView(viewID string, view slack.ModalViewRequest) (*slack.ViewResponse, error) {
+	log.Printf("Calling Slack API: views.update for view %s", viewID)
+	var resp *slack.ViewResponse
+	err := c.call(context.Background(), "views.update", func(ctx context.Context) (err error) {
+		resp, err = c.api.UpdateViewContext(ctx, view, "", "", viewID)
+		return err
+	})
+	return resp, err
+}
+
+// GetUserName fetches a user's name from the cache or the API.
+func (c *Client) GetUserName(userID string) string {
+	name, err := c.users.get(userID, func() (string, error) {
+		var user *slack.User
+		err := c.call(context.Background(), "users.info", func(ctx context.Context) (err error) {
+			user, err = c.api.GetUserInfoContext(ctx, userID)
+			return err
+		})
 		if err != nil {
-			return nil, fmt.Errorf("failed to get user conversations: %w", err)
+			log.Printf("Error getting user info for %s: %v", userID, err)
+			return "", err
 		}
+		return user.Name, nil
+	})
+	if err != nil {
+		return userID // Fallback to user ID
+	}
+	return name
+}
+
+// GetUserTimezone returns the IANA time zone set in a user's Slack profile, e.g.
+// "Europe/Berlin".
+func (c *Client) GetUserTimezone(ctx context.Context, userID string) (string, error) {
+	user, err := c.getUser(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+	return user.TZ, nil
+}
+
+// GetUserEmail returns the email address in a user's Slack profile. It is empty
+// unless the app has the users:read.email scope.
+func (c *Client) GetUserEmail(ctx context.Context, userID string) (string, error) {
+	user, err := c.getUser(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+	return user.Profile.Email, nil
+}
 
-		for _, channel := range channels {
-			// With users.conversations, all returned channels are ones the bot is a member of.
-			// We can still log the name for clarity.
-			log.Printf("Bot is a member of channel: %s (%s)", channel.Name, channel.ID)
-			allChannelIDs = append(allChannelIDs, channel.ID)
+func (c *Client) getUser(ctx context.Context, userID string) (*slack.User, error) {
+	log.Printf("Calling Slack API: users.info for user %s", userID)
+	var user *slack.User
+	err := c.call(ctx, "users.info", func(ctx context.Context) (err error) {
+		user, err = c.api.GetUserInfoContext(ctx, userID)
+		return err
+	})
+	return user, err
+}
 
-			if len(allChannelIDs) >= 30 { // Limit to 30 channels
+// GetUserGroupIDs returns the IDs of the user groups (e.g. @oncall) userID
+// belongs to. It needs the usergroups:read scope.
+func (c *Client) GetUserGroupIDs(ctx context.Context, userID string) ([]string, error) {
+	log.Println("Calling Slack API: usergroups.list")
+	var groups []slack.UserGroup
+	err := c.call(ctx, "usergroups.list", func(ctx context.Context) (err error) {
+		groups, err = c.api.GetUserGroupsContext(ctx, slack.GetUserGroupsOptionIncludeUsers(true))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	for _, group := range groups {
+		for _, member := range group.Users {
+			if member == userID {
+				ids = append(ids, group.ID)
 				break
 			}
 		}
+	}
+	return ids, nil
+}
 
-		if len(allChannelIDs) >= 30 || nextCursor == "" { // Stop if 30 channels reached or no more pages
-			break
+// GetChannelName fetches a channel's name from the cache or the API.
+func (c *Client) GetChannelName(channelID string) string {
+	name, err := c.channels.get(channelID, func() (string, error) {
+		var channel *slack.Channel
+		err := c.call(context.Background(), "conversations.info", func(ctx context.Context) (err error) {
+			channel, err = c.api.GetConversationInfoContext(ctx, &slack.GetConversationInfoInput{ChannelID: channelID})
+			return err
+		})
+		if err != nil {
+			log.Printf("Error getting channel info for %s: %v", channelID, err)
+			return "", err
 		}
-		cursor = nextCursor
+		return channel.Name, nil
+	})
+	if err != nil {
+		return channelID // Fallback to channel ID
 	}
-
-	return allChannelIDs, nil
+	return name
 }
 
 // SearchMessages searches for messages matching a query.
-func (c *Client) SearchMessages(query string) (*slack.SearchMessages, error) {
+func (c *Client) SearchMessages(ctx context.Context, query string) (*slack.SearchMessages, error) {
 	log.Printf("Calling Slack API: search.messages with query '%s'", query)
 	// Note: The empty string for sorting and the default pagination parameters are used.
 	// For a more advanced implementation, these could be configurable.
-	return c.api.SearchMessages(query, slack.SearchParameters{})
+	var result *slack.SearchMessages
+	err := c.call(ctx, "search.messages", func(ctx context.Context) (err error) {
+		result, err = c.api.SearchMessagesContext(ctx, query, slack.SearchParameters{})
+		return err
+	})
+	return result, err
 }
 
@@ -0,0 +1,194 @@
+package slack
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/slack-go/slack"
+)
+
+// threadHandlers serve a channel whose history before the window from
+// 1714600000 to 1714700000 holds a thread P0 that got a reply in the window, a
+// thread P1 whose replies ended before it and a message without replies. Only
+// P0 and the window's first thread can be read.
+func threadHandlers() map[string]fakeHandler {
+	return map[string]fakeHandler{
+		"conversations.history": func(w http.ResponseWriter, r *http.Request) (int, string) {
+			if r.FormValue("latest") != "1714600000" {
+				return http.StatusOK, `{"ok":true,"messages":[]}`
+			}
+			return http.StatusOK, `{"ok":true,"messages":[
+				{"type":"message","ts":"1714530000.000000","text":"no replies"},
+				{"type":"message","ts":"1714510000.000000","thread_ts":"1714510000.000000","text":"P1","reply_count":1,"latest_reply":"1714520000.000000"},
+				{"type":"message","ts":"1714500000.000100","thread_ts":"1714500000.000100","text":"P0","reply_count":2,"latest_reply":"1714650000.000000"}
+			]}`
+		},
+		"conversations.replies": func(w http.ResponseWriter, r *http.Request) (int, string) {
+			if r.FormValue("oldest") != "1714600000" || r.FormValue("latest") != "1714700000" {
+				return http.StatusOK, `{"ok":false,"error":"unexpected_window"}`
+			}
+			switch ts := r.FormValue("ts"); ts {
+			case "1714500000.000100", "1714610000.000000":
+				reply := map[string]string{"1714500000.000100": "1714650000.000000", "1714610000.000000": "1714620000.000000"}[ts]
+				return http.StatusOK, fmt.Sprintf(`{"ok":true,"has_more":false,"messages":[
+					{"type":"message","ts":%q,"thread_ts":%q,"text":"parent"},
+					{"type":"message","ts":%q,"thread_ts":%q,"text":"reply"}
+				]}`, ts, ts, reply, ts)
+			}
+			return http.StatusOK, `{"ok":false,"error":"thread_not_found"}`
+		},
+	}
+}
+
+func TestExpandThreads(t *testing.T) {
+	parent := func(ts, latestReply string) slack.Message {
+		msg := slack.Message{}
+		msg.Channel, msg.Timestamp, msg.ThreadTimestamp, msg.ReplyCount, msg.LatestReply = "C1", ts, ts, 1, latestReply
+		return msg
+	}
+	plain := func(ts string) slack.Message {
+		msg := slack.Message{}
+		msg.Channel, msg.Timestamp = "C1", ts
+		return msg
+	}
+	window := []slack.Message{
+		parent("1714610000.000000", "1714620000.000000"),
+		plain("1714630000.000000"),
+		parent("1714640000.000000", "1714590000.000000"), // Its replies are all older than the window.
+	}
+
+	tests := []struct {
+		name        string
+		lookback    time.Duration
+		messages    []slack.Message
+		want        string
+		wantReplies int
+	}{
+		{
+			name:        "threads in the window and older threads with replies in it",
+			messages:    window,
+			want:        "1714500000.000100 1714650000.000000 1714610000.000000 1714620000.000000 1714630000.000000 1714640000.000000",
+			wantReplies: 2,
+		},
+		{
+			name:        "lookback turned off",
+			lookback:    -1,
+			messages:    window,
+			want:        "1714610000.000000 1714620000.000000 1714630000.000000 1714640000.000000",
+			wantReplies: 1,
+		},
+		{
+			name:        "older parent already listed is expanded once",
+			messages:    []slack.Message{parent("1714500000.000100", "1714650000.000000"), plain("1714630000.000000")},
+			want:        "1714500000.000100 1714650000.000000 1714630000.000000",
+			wantReplies: 1,
+		},
+		{
+			name:        "unreadable thread stays collapsed",
+			messages:    []slack.Message{parent("1714680000.000000", "1714690000.000000")},
+			want:        "1714500000.000100 1714650000.000000 1714680000.000000",
+			wantReplies: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, fake := newFakeSlack(t, threadHandlers())
+			client.SetThreadLookback(tt.lookback)
+
+			got := client.ExpandThreads(context.Background(), "C1", tt.messages, time.Unix(1714600000, 0), time.Unix(1714700000, 0))
+			var timestamps []string
+			for _, msg := range got {
+				timestamps = append(timestamps, msg.Timestamp)
+				if msg.Timestamp == "1714500000.000100" && msg.Channel != "C1" {
+					t.Errorf("older parent has channel %q, want C1", msg.Channel)
+				}
+			}
+			if strings.Join(timestamps, " ") != tt.want {
+				t.Errorf("messages = %v, want %s", timestamps, tt.want)
+			}
+			if n := fake.count("conversations.replies"); n != tt.wantReplies {
+				t.Errorf("conversations.replies called %d times, want %d", n, tt.wantReplies)
+			}
+		})
+	}
+}
+
+// historyHandlers serve a channel history of seven messages, newest first, in
+// pages of three. failAt names a cursor whose page fails.
+func historyHandlers(failAt string) map[string]fakeHandler {
+	pages := map[string]string{
+		"":   `{"ok":true,"has_more":true,"messages":[{"ts":"1714600007.000000"},{"ts":"1714600006.000000"},{"ts":"1714600005.000000"}],"response_metadata":{"next_cursor":"p2"}}`,
+		"p2": `{"ok":true,"has_more":true,"messages":[{"ts":"1714600004.000000"},{"ts":"1714600003.000000"},{"ts":"1714600002.000000"}],"response_metadata":{"next_cursor":"p3"}}`,
+		"p3": `{"ok":true,"has_more":false,"messages":[{"ts":"1714600001.000000"}],"response_metadata":{"next_cursor":""}}`,
+	}
+	return map[string]fakeHandler{
+		"conversations.history": func(w http.ResponseWriter, r *http.Request) (int, string) {
+			if r.FormValue("channel") != "C1" || r.FormValue("oldest") != "1714600000" || r.FormValue("latest") != "1714700000" || r.FormValue("limit") != "200" {
+				return http.StatusOK, `{"ok":false,"error":"invalid_arguments"}`
+			}
+			cursor := r.FormValue("cursor")
+			if failAt != "" && cursor == failAt {
+				return http.StatusOK, `{"ok":false,"error":"channel_not_found"}`
+			}
+			page, ok := pages[cursor]
+			if !ok {
+				return http.StatusOK, `{"ok":false,"error":"invalid_cursor"}`
+			}
+			return http.StatusOK, page
+		},
+	}
+}
+
+func TestGetConversationHistory(t *testing.T) {
+	tests := []struct {
+		name          string
+		max           int
+		failAt        string
+		want          string
+		wantTruncated bool
+		wantCalls     int
+		wantErr       bool
+	}{
+		{name: "all pages", want: "1 2 3 4 5 6 7", wantCalls: 3},
+		{name: "exactly the cap", max: 7, want: "1 2 3 4 5 6 7", wantCalls: 3},
+		{name: "cap at the end of a page", max: 6, want: "2 3 4 5 6 7", wantTruncated: true, wantCalls: 2},
+		{name: "cap within a page", max: 4, want: "4 5 6 7", wantTruncated: true, wantCalls: 2},
+		{name: "cap at the end of the first page", max: 3, want: "5 6 7", wantTruncated: true, wantCalls: 1},
+		{name: "a page fails", failAt: "p2", wantCalls: 2, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, fake := newFakeSlack(t, historyHandlers(tt.failAt))
+			client.SetMaxHistoryMessages(tt.max)
+
+			history, err := client.GetConversationHistory(context.Background(), "C1", time.Unix(1714600000, 0), time.Unix(1714700000, 0))
+			if n := fake.count("conversations.history"); n != tt.wantCalls {
+				t.Errorf("conversations.history called %d times, want %d", n, tt.wantCalls)
+			}
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got []string
+			for _, msg := range history.Messages {
+				got = append(got, strings.TrimSuffix(strings.TrimPrefix(msg.Timestamp, "171460000"), ".000000"))
+			}
+			if strings.Join(got, " ") != tt.want {
+				t.Errorf("messages = %v, want %s in chronological order", got, tt.want)
+			}
+			if history.Truncated != tt.wantTruncated {
+				t.Errorf("Truncated = %v, want %v", history.Truncated, tt.wantTruncated)
+			}
+		})
+	}
+}
@@ -0,0 +1,182 @@
+package storage
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"log"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// BoltStore is a Store backed by a BoltDB file, so its contents survive
+// restarts. The file is locked by the process that opens it, so replicas need
+// separate files or a shared RedisStore.
+type BoltStore struct {
+	db   *bolt.DB
+	done chan struct{}
+}
+
+// OpenBolt opens or creates the BoltDB file at path and starts removing expired
+// entries in the background.
+func OpenBolt(path string) (*BoltStore, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("could not open %s: %w", path, err)
+	}
+	s := &BoltStore{db: db, done: make(chan struct{})}
+	go s.sweep()
+	return s, nil
+}
+
+// Values are stored with an 8-byte expiry prefix: Unix nanoseconds, or 0 for none.
+const expiryLen = 8
+
+func encode(value []byte, ttl time.Duration) []byte {
+	out := make([]byte, expiryLen+len(value))
+	if ttl > 0 {
+		binary.BigEndian.PutUint64(out, uint64(time.Now().Add(ttl).UnixNano()))
+	}
+	copy(out[expiryLen:], value)
+	return out
+}
+
+// decode returns the value of a stored record, or false if it is malformed or expired.
+func decode(record []byte, now time.Time) ([]byte, bool) {
+	if len(record) < expiryLen {
+		return nil, false
+	}
+	if expires := binary.BigEndian.Uint64(record); expires != 0 && now.UnixNano() > int64(expires) {
+		return nil, false
+	}
+	return append([]byte(nil), record[expiryLen:]...), true
+}
+
+// Get implements Store.
+func (s *BoltStore) Get(ctx context.Context, bucket, key string) ([]byte, bool, error) {
+	var value []byte
+	var ok bool
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return nil
+		}
+		if record := b.Get([]byte(key)); record != nil {
+			value, ok = decode(record, time.Now())
+		}
+		return nil
+	})
+	return value, ok, err
+}
+
+// Put implements Store.
+func (s *BoltStore) Put(ctx context.Context, bucket, key string, value []byte, ttl time.Duration) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(bucket))
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(key), encode(value, ttl))
+	})
+}
+
+// Update implements Store.
+func (s *BoltStore) Update(ctx context.Context, bucket, key string, ttl time.Duration, fn func(old []byte) ([]byte, error)) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(bucket))
+		if err != nil {
+			return err
+		}
+		var old []byte
+		if record := b.Get([]byte(key)); record != nil {
+			old, _ = decode(record, time.Now())
+		}
+		value, err := fn(old)
+		if err != nil {
+			return err
+		}
+		if value == nil {
+			return b.Delete([]byte(key))
+		}
+		return b.Put([]byte(key), encode(value, ttl))
+	})
+}
+
+// Delete implements Store.
+func (s *BoltStore) Delete(ctx context.Context, bucket, key string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return nil
+		}
+		return b.Delete([]byte(key))
+	})
+}
+
+// List implements Store.
+func (s *BoltStore) List(ctx context.Context, bucket string) (map[string][]byte, error) {
+	entries := make(map[string][]byte)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return nil
+		}
+		now := time.Now()
+		return b.ForEach(func(k, record []byte) error {
+			if value, ok := decode(record, now); ok {
+				entries[string(k)] = value
+			}
+			return nil
+		})
+	})
+	return entries, err
+}
+
+// Close stops the background sweep and closes the file.
+func (s *BoltStore) Close() error {
+	close(s.done)
+	return s.db.Close()
+}
+
+// sweep deletes expired entries every sweepInterval until the store is closed.
+func (s *BoltStore) sweep() {
+	ticker := time.NewTicker(sweepInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+		}
+
+		removed := 0
+		err := s.db.Update(func(tx *bolt.Tx) error {
+			now := time.Now()
+			return tx.ForEach(func(name []byte, b *bolt.Bucket) error {
+				var expired [][]byte
+				err := b.ForEach(func(k, record []byte) error {
+					if _, ok := decode(record, now); !ok {
+						expired = append(expired, append([]byte(nil), k...))
+					}
+					return nil
+				})
+				if err != nil {
+					return err
+				}
+				for _, k := range expired {
+					if err := b.Delete(k); err != nil {
+						return err
+					}
+				}
+				removed += len(expired)
+				return nil
+			})
+		})
+		if err != nil {
+			log.Printf("Error removing expired entries: %v", err)
+		} else if removed > 0 {
+			log.Printf("Removed %d expired entries", removed)
+		}
+	}
+}
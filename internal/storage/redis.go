@@ -0,0 +1,184 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// defaultRedisPrefix namespaces the keys of a RedisStore.
+	defaultRedisPrefix = "communicator:"
+	// maxUpdateAttempts bounds how often Update retries when another client
+	// changes the key between reading and writing it.
+	maxUpdateAttempts = 50
+	// updateBackoff is the longest pause between two Update attempts; the actual
+	// pause is random so that conflicting clients don't retry in lockstep.
+	updateBackoff = 10 * time.Millisecond
+	// scanCount is the number of keys asked for per SCAN call in List.
+	scanCount = 100
+)
+
+// RedisConfig holds the connection settings of a RedisStore.
+type RedisConfig struct {
+	// Address is host:port. Empty means "localhost:6379".
+	Address  string
+	Username string
+	Password string
+	DB       int
+	// KeyPrefix namespaces every key, so several deployments can share one
+	// server. Empty means "communicator:".
+	KeyPrefix string
+}
+
+// RedisStore is a Store kept in Redis. Several replicas can share it: Update
+// is atomic across clients and expiry is handled by Redis itself.
+type RedisStore struct {
+	client *redis.Client
+	prefix string
+}
+
+// OpenRedis connects to the Redis server in cfg and checks that it answers.
+func OpenRedis(ctx context.Context, cfg RedisConfig) (*RedisStore, error) {
+	if cfg.KeyPrefix == "" {
+		cfg.KeyPrefix = defaultRedisPrefix
+	}
+	client := redis.NewClient(&redis.Options{
+		Addr:     cfg.Address,
+		Username: cfg.Username,
+		Password: cfg.Password,
+		DB:       cfg.DB,
+	})
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("could not reach redis at %s: %w", cfg.Address, err)
+	}
+	return &RedisStore{client: client, prefix: cfg.KeyPrefix}, nil
+}
+
+// key returns the Redis key of key in bucket.
+func (s *RedisStore) key(bucket, key string) string {
+	return s.prefix + bucket + ":" + key
+}
+
+// Get implements Store.
+func (s *RedisStore) Get(ctx context.Context, bucket, key string) ([]byte, bool, error) {
+	value, err := s.client.Get(ctx, s.key(bucket, key)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+// Put implements Store.
+func (s *RedisStore) Put(ctx context.Context, bucket, key string, value []byte, ttl time.Duration) error {
+	return s.client.Set(ctx, s.key(bucket, key), value, expiration(ttl)).Err()
+}
+
+// Update implements Store with optimistic locking: the key is watched while fn
+// runs, and the write is retried if another client changed it meanwhile.
+func (s *RedisStore) Update(ctx context.Context, bucket, key string, ttl time.Duration, fn func(old []byte) ([]byte, error)) error {
+	k := s.key(bucket, key)
+	update := func(tx *redis.Tx) error {
+		old, err := tx.Get(ctx, k).Bytes()
+		if errors.Is(err, redis.Nil) {
+			old = nil
+		} else if err != nil {
+			return err
+		}
+		value, err := fn(old)
+		if err != nil {
+			return err
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			if value == nil {
+				pipe.Del(ctx, k)
+			} else {
+				pipe.Set(ctx, k, value, expiration(ttl))
+			}
+			return nil
+		})
+		return err
+	}
+
+	for attempt := 0; attempt < maxUpdateAttempts; attempt++ {
+		err := s.client.Watch(ctx, update, k)
+		if !errors.Is(err, redis.TxFailedErr) {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(rand.N(updateBackoff)):
+		}
+	}
+	return fmt.Errorf("could not update %s: too much contention", k)
+}
+
+// Delete implements Store.
+func (s *RedisStore) Delete(ctx context.Context, bucket, key string) error {
+	return s.client.Del(ctx, s.key(bucket, key)).Err()
+}
+
+// List implements Store. It scans the bucket's keys, so it is meant for small
+// buckets such as digest subscriptions.
+func (s *RedisStore) List(ctx context.Context, bucket string) (map[string][]byte, error) {
+	prefix := s.key(bucket, "")
+	entries := make(map[string][]byte)
+	iter := s.client.Scan(ctx, 0, globEscape(prefix)+"*", scanCount).Iterator()
+	var keys []string
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+	}
+	if err := iter.Err(); err != nil {
+		return nil, err
+	}
+
+	for start := 0; start < len(keys); start += scanCount {
+		batch := keys[start:min(start+scanCount, len(keys))]
+		values, err := s.client.MGet(ctx, batch...).Result()
+		if err != nil {
+			return nil, err
+		}
+		for i, value := range values {
+			// Keys that expired since the scan come back as nil.
+			if str, ok := value.(string); ok {
+				entries[strings.TrimPrefix(batch[i], prefix)] = []byte(str)
+			}
+		}
+	}
+	return entries, nil
+}
+
+// Close implements Store.
+func (s *RedisStore) Close() error {
+	return s.client.Close()
+}
+
+// expiration converts a Store ttl to a Redis expiration, where 0 also means none.
+func expiration(ttl time.Duration) time.Duration {
+	if ttl <= 0 {
+		return 0
+	}
+	return ttl
+}
+
+// globEscape escapes the characters SCAN MATCH treats as patterns.
+func globEscape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune(`*?[]\^`, r) {
+			b.WriteRune('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,153 @@
+// Package storage persists small pieces of bot state, such as DM history and
+// summary context, as key-value pairs with per-key expiry.
+package storage
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Store is a bucketed key-value store. Implementations are safe for concurrent
+// use. A ttl of 0 keeps a value until it is deleted; expired values are never returned.
+type Store interface {
+	// Get returns the value of key and whether it exists.
+	Get(ctx context.Context, bucket, key string) ([]byte, bool, error)
+	// Put stores value under key for ttl.
+	Put(ctx context.Context, bucket, key string, value []byte, ttl time.Duration) error
+	// Update atomically replaces the value of key with the result of fn, which
+	// receives nil if key doesn't exist. If fn returns nil the key is deleted;
+	// if it returns an error nothing changes. fn may be called more than once
+	// when the store retries a conflicting update, so it shouldn't have side
+	// effects beyond its last call.
+	Update(ctx context.Context, bucket, key string, ttl time.Duration, fn func(old []byte) ([]byte, error)) error
+	// Delete removes key. Deleting a missing key is not an error.
+	Delete(ctx context.Context, bucket, key string) error
+	// List returns the live entries of bucket by key.
+	List(ctx context.Context, bucket string) (map[string][]byte, error)
+	// Close releases the store's resources.
+	Close() error
+}
+
+// sweepInterval is how often expired entries are removed.
+const sweepInterval = time.Minute
+
+type memoryEntry struct {
+	value   []byte
+	expires time.Time
+}
+
+func (e memoryEntry) expired(now time.Time) bool {
+	return !e.expires.IsZero() && now.After(e.expires)
+}
+
+// MemoryStore is an in-process Store. It is the default; its contents are lost
+// on restart and it can't be shared by several replicas.
+type MemoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]map[string]memoryEntry
+	lastSweep time.Time
+}
+
+// NewMemoryStore creates an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: make(map[string]map[string]memoryEntry)}
+}
+
+// Get implements Store.
+func (s *MemoryStore) Get(ctx context.Context, bucket, key string) ([]byte, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.buckets[bucket][key]
+	if !ok || entry.expired(time.Now()) {
+		return nil, false, nil
+	}
+	return append([]byte(nil), entry.value...), true, nil
+}
+
+// Put implements Store.
+func (s *MemoryStore) Put(ctx context.Context, bucket, key string, value []byte, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.put(bucket, key, value, ttl)
+	return nil
+}
+
+// Update implements Store.
+func (s *MemoryStore) Update(ctx context.Context, bucket, key string, ttl time.Duration, fn func(old []byte) ([]byte, error)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var old []byte
+	if entry, ok := s.buckets[bucket][key]; ok && !entry.expired(time.Now()) {
+		old = append([]byte(nil), entry.value...)
+	}
+	value, err := fn(old)
+	if err != nil {
+		return err
+	}
+	if value == nil {
+		delete(s.buckets[bucket], key)
+		return nil
+	}
+	s.put(bucket, key, value, ttl)
+	return nil
+}
+
+// Delete implements Store.
+func (s *MemoryStore) Delete(ctx context.Context, bucket, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.buckets[bucket], key)
+	return nil
+}
+
+// List implements Store.
+func (s *MemoryStore) List(ctx context.Context, bucket string) (map[string][]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	entries := make(map[string][]byte)
+	for key, entry := range s.buckets[bucket] {
+		if !entry.expired(now) {
+			entries[key] = append([]byte(nil), entry.value...)
+		}
+	}
+	return entries, nil
+}
+
+// Close implements Store.
+func (s *MemoryStore) Close() error {
+	return nil
+}
+
+// put stores a value and, at most once per sweepInterval, drops expired entries
+// so the maps don't grow forever. s.mu must be held.
+func (s *MemoryStore) put(bucket, key string, value []byte, ttl time.Duration) {
+	now := time.Now()
+	if now.Sub(s.lastSweep) > sweepInterval {
+		for _, entries := range s.buckets {
+			for k, entry := range entries {
+				if entry.expired(now) {
+					delete(entries, k)
+				}
+			}
+		}
+		s.lastSweep = now
+	}
+
+	entries, ok := s.buckets[bucket]
+	if !ok {
+		entries = make(map[string]memoryEntry)
+		s.buckets[bucket] = entries
+	}
+	entry := memoryEntry{value: append([]byte(nil), value...)}
+	if ttl > 0 {
+		entry.expires = now.Add(ttl)
+	}
+	entries[key] = entry
+}
@@ -0,0 +1,218 @@
+package storage
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+// testStore is a Store under test and a way to let time pass for its expiry.
+type testStore struct {
+	Store
+	advance func(d time.Duration)
+}
+
+func testStores() map[string]func(t *testing.T) testStore {
+	sleep := func(d time.Duration) { time.Sleep(d) }
+	return map[string]func(t *testing.T) testStore{
+		"memory": func(t *testing.T) testStore {
+			return testStore{NewMemoryStore(), sleep}
+		},
+		"bolt": func(t *testing.T) testStore {
+			store, err := OpenBolt(filepath.Join(t.TempDir(), "test.db"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { store.Close() })
+			return testStore{store, sleep}
+		},
+		"redis": func(t *testing.T) testStore {
+			server := miniredis.RunT(t)
+			store, err := OpenRedis(context.Background(), RedisConfig{Address: server.Addr()})
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { store.Close() })
+			// miniredis only expires keys when told that time has passed.
+			return testStore{store, server.FastForward}
+		},
+	}
+}
+
+func TestStoreGetPutDelete(t *testing.T) {
+	for name, open := range testStores() {
+		t.Run(name, func(t *testing.T) {
+			store, ctx := open(t), context.Background()
+			if _, ok, err := store.Get(ctx, "b", "k"); err != nil || ok {
+				t.Fatalf("Get of a missing key = %v, %v; want not found", ok, err)
+			}
+			if err := store.Put(ctx, "b", "k", []byte("v1"), 0); err != nil {
+				t.Fatal(err)
+			}
+			if value, ok, err := store.Get(ctx, "b", "k"); err != nil || !ok || string(value) != "v1" {
+				t.Fatalf("Get = %q, %v, %v; want v1", value, ok, err)
+			}
+			if _, ok, _ := store.Get(ctx, "other", "k"); ok {
+				t.Error("key visible in another bucket")
+			}
+			if err := store.Delete(ctx, "b", "k"); err != nil {
+				t.Fatal(err)
+			}
+			if _, ok, _ := store.Get(ctx, "b", "k"); ok {
+				t.Error("key still found after Delete")
+			}
+			if err := store.Delete(ctx, "b", "missing"); err != nil {
+				t.Errorf("Delete of a missing key = %v", err)
+			}
+		})
+	}
+}
+
+func TestStoreExpiry(t *testing.T) {
+	for name, open := range testStores() {
+		t.Run(name, func(t *testing.T) {
+			store, ctx := open(t), context.Background()
+			store.Put(ctx, "b", "short", []byte("v"), 50*time.Millisecond)
+			store.Put(ctx, "b", "forever", []byte("v"), 0)
+			store.advance(100 * time.Millisecond)
+
+			if _, ok, _ := store.Get(ctx, "b", "short"); ok {
+				t.Error("expired key still found")
+			}
+			if _, ok, _ := store.Get(ctx, "b", "forever"); !ok {
+				t.Error("key without a ttl expired")
+			}
+			entries, err := store.List(ctx, "b")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := entries["short"]; ok || len(entries) != 1 {
+				t.Errorf("List = %v, want only the live key", entries)
+			}
+		})
+	}
+}
+
+func TestStoreUpdate(t *testing.T) {
+	for name, open := range testStores() {
+		t.Run(name, func(t *testing.T) {
+			store, ctx := open(t), context.Background()
+			appendX := func(old []byte) ([]byte, error) { return append(old, 'x'), nil }
+
+			if err := store.Update(ctx, "b", "k", 0, appendX); err != nil {
+				t.Fatal(err)
+			}
+			store.Update(ctx, "b", "k", 0, appendX)
+			if value, _, _ := store.Get(ctx, "b", "k"); string(value) != "xx" {
+				t.Errorf("value = %q, want xx", value)
+			}
+
+			errAbort := errors.New("abort")
+			err := store.Update(ctx, "b", "k", 0, func(old []byte) ([]byte, error) { return nil, errAbort })
+			if !errors.Is(err, errAbort) {
+				t.Errorf("Update err = %v, want fn's error", err)
+			}
+			if value, _, _ := store.Get(ctx, "b", "k"); string(value) != "xx" {
+				t.Errorf("value = %q after a failed update, want it unchanged", value)
+			}
+
+			store.Update(ctx, "b", "k", 0, func(old []byte) ([]byte, error) { return nil, nil })
+			if _, ok, _ := store.Get(ctx, "b", "k"); ok {
+				t.Error("key still found after fn returned nil")
+			}
+		})
+	}
+}
+
+func TestStoreUpdateIsAtomic(t *testing.T) {
+	for name, open := range testStores() {
+		t.Run(name, func(t *testing.T) {
+			store, ctx := open(t), context.Background()
+			const writers = 20
+			var wg sync.WaitGroup
+			for i := 0; i < writers; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					err := store.Update(ctx, "b", "counter", 0, func(old []byte) ([]byte, error) {
+						var n uint64
+						if old != nil {
+							n = binary.BigEndian.Uint64(old)
+						}
+						return binary.BigEndian.AppendUint64(nil, n+1), nil
+					})
+					if err != nil {
+						t.Error(err)
+					}
+				}()
+			}
+			wg.Wait()
+
+			value, _, _ := store.Get(ctx, "b", "counter")
+			if n := binary.BigEndian.Uint64(value); n != writers {
+				t.Errorf("counter = %d, want %d increments", n, writers)
+			}
+		})
+	}
+}
+
+func TestStoreList(t *testing.T) {
+	for name, open := range testStores() {
+		t.Run(name, func(t *testing.T) {
+			store, ctx := open(t), context.Background()
+			store.Put(ctx, "subs", "U1/a", []byte("1"), 0)
+			store.Put(ctx, "subs", "U2/b", []byte("2"), 0)
+			store.Put(ctx, "subs*", "U3/c", []byte("3"), 0)
+			store.Put(ctx, "other", "U1/a", []byte("4"), 0)
+
+			entries, err := store.List(ctx, "subs")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != 2 || string(entries["U1/a"]) != "1" || string(entries["U2/b"]) != "2" {
+				t.Errorf("List = %q, want only the bucket's two entries", entries)
+			}
+			if entries, _ := store.List(ctx, "empty"); len(entries) != 0 {
+				t.Errorf("List of an empty bucket = %q", entries)
+			}
+		})
+	}
+}
+
+func TestRedisStoresShareState(t *testing.T) {
+	server := miniredis.RunT(t)
+	ctx := context.Background()
+	open := func(prefix string) *RedisStore {
+		store, err := OpenRedis(ctx, RedisConfig{Address: server.Addr(), KeyPrefix: prefix})
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { store.Close() })
+		return store
+	}
+	replica1, replica2, otherDeployment := open(""), open(""), open("staging:")
+
+	replica1.Put(ctx, "b", "k", []byte("v"), 0)
+	if value, ok, _ := replica2.Get(ctx, "b", "k"); !ok || string(value) != "v" {
+		t.Errorf("second replica Get = %q, %v; want the first replica's value", value, ok)
+	}
+	if _, ok, _ := otherDeployment.Get(ctx, "b", "k"); ok {
+		t.Error("value visible under another key prefix")
+	}
+}
+
+func TestOpenRedisReportsUnreachableServer(t *testing.T) {
+	server := miniredis.RunT(t)
+	addr := server.Addr()
+	server.Close()
+
+	if _, err := OpenRedis(context.Background(), RedisConfig{Address: addr}); err == nil {
+		t.Error("expected an error for a server that doesn't answer")
+	}
+}